@@ -1,20 +1,159 @@
 package main
 
 import (
+	"context"
+	"encoding/csv"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"runtime"
+	"runtime/pprof"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	cookie "github.com/mfenderov/most-active-cookie"
 	"github.com/mfenderov/most-active-cookie/src/cli"
+	"github.com/mfenderov/most-active-cookie/src/parser"
+	"golang.org/x/term"
+)
+
+const (
+	ansiBoldGreen = "\033[1;32m"
+	ansiReset     = "\033[0m"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == cli.SchemaCommand {
+		runSchema(os.Args[2:])
+		return
+	}
+
 	config := parseAndValidateFlags()
-	configureLogging(config.Verbosity)
-	cookies := processCookies(config)
-	outputResults(cookies)
+	configureLogging(config.Verbosity, config.Quiet)
+	expandDirs(config)
+	started := time.Now()
+	stopProfiling := startProfiling(config)
+	finish := func() {
+		stopProfiling()
+		logRunSummary(started)
+	}
+	if config.Manifest != "" {
+		rows, failed := runManifest(config)
+		finish()
+		out, closeOutput := openOutput(config)
+		outputManifest(out, rows)
+		closeResults(closeOutput)
+		if failed {
+			os.Exit(1)
+		}
+		return
+	}
+	if config.SortCheck {
+		err := checkSorted(config)
+		finish()
+		exitOnError(err)
+		out, closeOutput := openOutput(config)
+		outputSorted(out, config.Filename)
+		closeResults(closeOutput)
+		return
+	}
+	if config.TopPerHour {
+		hours, err := processHours(config)
+		finish()
+		exitOnError(err)
+		out, closeOutput := openOutput(config)
+		outputHours(out, hours)
+		closeResults(closeOutput)
+		return
+	}
+	if config.Compare != "" {
+		comparison, err := processComparison(config)
+		finish()
+		exitOnError(err)
+		out, closeOutput := openOutput(config)
+		outputComparison(out, comparison)
+		closeResults(closeOutput)
+		return
+	}
+	if len(config.TargetDates) > 1 || config.Format == cli.FormatTable {
+		results, err := processTargetDates(config)
+		finish()
+		exitOnError(err)
+		if config.ExplainEmpty || config.Verbosity > 0 {
+			for _, date := range config.TargetDates {
+				if len(results[date]) == 0 {
+					dateConfig := *config
+					dateConfig.TargetDate = date
+					explainEmpty(&dateConfig)
+				}
+			}
+		}
+		out, closeOutput := openOutput(config)
+		if config.Format == cli.FormatTable {
+			outputTable(out, config.TargetDates, results)
+		} else {
+			outputDates(out, config.TargetDates, results, out == os.Stdout && useColor(config))
+		}
+		closeResults(closeOutput)
+		return
+	}
+	if config.Ranked {
+		ranked, err := processRanked(config)
+		finish()
+		exitOnError(err)
+		if len(ranked) == 0 && (config.ExplainEmpty || config.Verbosity > 0) {
+			explainEmpty(config)
+		}
+		out, closeOutput := openOutput(config)
+		outputRanked(out, ranked)
+		closeResults(closeOutput)
+		return
+	}
+	if config.Format != cli.FormatText {
+		counts, err := processCounts(config)
+		finish()
+		exitOnError(err)
+		if len(counts) == 0 && (config.ExplainEmpty || config.Verbosity > 0) {
+			explainEmpty(config)
+		}
+		out, closeOutput := openOutput(config)
+		outputCounts(out, counts, config)
+		closeResults(closeOutput)
+		return
+	}
+	cookies, err := processCookies(config)
+	finish()
+	exitOnError(err)
+	if len(cookies) == 0 && (config.ExplainEmpty || config.Verbosity > 0) {
+		explainEmpty(config)
+	}
+	out, closeOutput := openOutput(config)
+	outputResults(out, cookies, config.Print0, out == os.Stdout && useColor(config))
+	closeResults(closeOutput)
+}
+
+// exitOnError fails the run with err, if any. It is called once processing is
+// over and the run finished, so profiles and the run summary are still
+// written.
+func exitOnError(err error) {
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+// closeResults finishes the results output, failing the run if the results
+// could not be fully written.
+func closeResults(closeOutput func() error) {
+	if err := closeOutput(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write results: %v\n", err)
+		os.Exit(1)
+	}
 }
 
 func parseAndValidateFlags() *cli.Config {
@@ -27,38 +166,466 @@ func parseAndValidateFlags() *cli.Config {
 	return config
 }
 
-func processCookies(config *cli.Config) []string {
-	slog.Info("starting cookie processing", "filename", config.Filename, "targetDate", config.TargetDate)
+// expandDirs replaces directory inputs with the CSV files they contain. It
+// runs once logging is configured so skipped files show up with -vv.
+func expandDirs(config *cli.Config) {
+	filenames, err := cli.ExpandDirs(config.Filenames, config.Recursive)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+	config.Filenames = filenames
+	if len(filenames) > 0 {
+		config.Filename = filenames[0]
+	}
+}
 
-	// Use the library API instead of direct internal imports
-	cookies, err := cookie.FindMostActiveCookies(config.Filename, config.TargetDate)
+// runSchema prints the detected layout of a file without analyzing it.
+func runSchema(args []string) {
+	config, err := cli.ParseSchemaFlags(args)
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	schema, err := cookie.DetectSchema(config.Filename, config.Rows)
 	if err != nil {
-		slog.Error("processing failed", "error", err, "filename", config.Filename)
 		fmt.Fprintf(os.Stderr, "%v\n", err)
 		os.Exit(1)
 	}
 
+	columns := make([]string, len(schema.Columns))
+	for i, column := range schema.Columns {
+		columns[i] = fmt.Sprintf("%s (%s)", column.Name, column.Role)
+	}
+
+	fmt.Printf("delimiter: %q\n", schema.Delimiter)
+	fmt.Printf("bom: %t\n", schema.HasBOM)
+	fmt.Printf("header accepted: %t\n", schema.Accepted)
+	fmt.Printf("columns: %s\n", strings.Join(columns, ", "))
+	fmt.Printf("timestamp layout: %s\n", schema.TimestampLayout)
+	fmt.Printf("sampled rows: %d\n", schema.SampledRows)
+}
+
+func processCookies(config *cli.Config) ([]string, error) {
+	slog.Info("starting cookie processing", "filenames", config.Filenames, "targetDate", config.TargetDate)
+
+	// Use the library API instead of direct internal imports
+	var cookies []string
+	var err error
+	switch {
+	case config.Summary:
+		counts, err := processCounts(config)
+		return winnersOf(counts), err
+	case config.WinnerOnly:
+		cookies, err = findWinner(config)
+	case len(config.Filenames) > 1:
+		cookies, err = cookie.FindMostActiveCookiesInFiles(config.Filenames, config.TargetDate, libraryOptions(config)...)
+	case config.State != "":
+		cookies, err = cookie.FindMostActiveCookiesAccumulated(config.State, config.Filename, config.TargetDate, libraryOptions(config)...)
+	default:
+		opts := append(libraryOptions(config), cookie.WithTopN(config.Top))
+		cookies, err = cookie.FindMostActiveCookiesWithOptions(config.Filename, config.TargetDate, opts...)
+	}
+	if err != nil {
+		slog.Error("processing failed", "error", err, "filename", config.Filename)
+		return nil, err
+	}
+
 	slog.Info("cookie processing completed successfully", "cookieCount", len(cookies))
+	return cookies, nil
+}
+
+// processTargetDates is processCounts for several -d dates, answered in one
+// pass over the file.
+func processTargetDates(config *cli.Config) (map[string][]cookie.CookieCount, error) {
+	slog.Info("starting cookie processing", "filename", config.Filename, "targetDates", config.TargetDates)
+
+	results, err := cookie.FindMostActiveCookiesWithCountsByDate(config.Filename, config.TargetDates, libraryOptions(config)...)
+	if err != nil {
+		slog.Error("processing failed", "error", err, "filename", config.Filename)
+		return nil, err
+	}
+
+	slog.Info("cookie processing completed successfully", "dateCount", len(results))
+	return results, nil
+}
+
+// processComparison is processCookies for -compare, counting both dates in
+// one pass over the file.
+func processComparison(config *cli.Config) (cookie.Comparison, error) {
+	slog.Info("starting cookie processing", "filename", config.Filename, "targetDate", config.TargetDate, "compare", config.Compare)
+
+	comparison, err := cookie.CompareDates(config.Filename, config.TargetDate, config.Compare, libraryOptions(config)...)
+	if err != nil {
+		slog.Error("processing failed", "error", err, "filename", config.Filename)
+		return cookie.Comparison{}, err
+	}
+
+	slog.Info("cookie processing completed successfully", "changeCount", len(comparison.Changes))
+	return comparison, nil
+}
+
+// processRanked is processCookies for -ranked, keeping each cookie's count and
+// rank.
+func processRanked(config *cli.Config) ([]cookie.RankedCookie, error) {
+	slog.Info("starting cookie processing", "filename", config.Filename, "targetDate", config.TargetDate)
+
+	ranked, err := cookie.FindRankedCookies(config.Filename, config.TargetDate, config.Top, libraryOptions(config)...)
+	if err != nil {
+		slog.Error("processing failed", "error", err, "filename", config.Filename)
+		return nil, err
+	}
+
+	slog.Info("cookie processing completed successfully", "cookieCount", len(ranked))
+	return ranked, nil
+}
+
+// processCounts is processCookies for the -format values other than text,
+// keeping the winners' count.
+func processCounts(config *cli.Config) ([]cookie.CookieCount, error) {
+	slog.Info("starting cookie processing", "filename", config.Filename, "targetDate", config.TargetDate)
+
+	var counts []cookie.CookieCount
+	var summary cookie.Summary
+	var err error
+	if config.Summary {
+		counts, summary, err = cookie.FindMostActiveCookiesWithSummary(config.Filename, config.TargetDate, libraryOptions(config)...)
+	} else {
+		counts, err = cookie.FindMostActiveCookiesWithCounts(config.Filename, config.TargetDate, libraryOptions(config)...)
+	}
+	if err != nil {
+		slog.Error("processing failed", "error", err, "filename", config.Filename)
+		return nil, err
+	}
+
+	slog.Info("cookie processing completed successfully", "cookieCount", len(counts))
+	if config.Summary {
+		printSummary(os.Stderr, summary, counts)
+	}
+	return counts, nil
+}
+
+// printSummary writes the -summary footer, a comment line kept off stdout so
+// piped and JSON output stay clean.
+func printSummary(w io.Writer, summary cookie.Summary, winners []cookie.CookieCount) {
+	result := "no winner"
+	switch {
+	case len(winners) == 1:
+		result = fmt.Sprintf("winner had %d hits", winners[0].Count)
+	case len(winners) > 1:
+		result = fmt.Sprintf("%d tied winners had %d hits", len(winners), winners[0].Count)
+	}
+	fmt.Fprintf(w, "# scanned %d lines, %d distinct cookies, %s\n", summary.Lines, summary.Distinct, result)
+}
+
+// winnersOf drops the counts from winners.
+func winnersOf(winners []cookie.CookieCount) []string {
+	cookies := make([]string, len(winners))
+	for i, winner := range winners {
+		cookies[i] = winner.Cookie
+	}
 	return cookies
 }
 
-func outputResults(cookies []string) {
+// findWinner returns the unique winner, if any, as a one-element slice so it
+// flows through the normal output path. Ties are returned as errors.
+func findWinner(config *cli.Config) ([]string, error) {
+	winner, err := cookie.FindWinner(config.Filename, config.TargetDate, libraryOptions(config)...)
+	if err != nil || winner == "" {
+		return []string{}, err
+	}
+	return []string{winner}, nil
+}
+
+func libraryOptions(config *cli.Config) []cookie.Option {
+	opts := []cookie.Option{cookie.WithMaxLines(config.MaxLines)}
+	if config.AssumeTZ != nil {
+		opts = append(opts, cookie.WithAssumedLocation(config.AssumeTZ))
+	}
+	if config.TimeLayout != "" {
+		opts = append(opts, cookie.WithTimestampLayouts(config.TimeLayout))
+	}
+	if config.AssumeSorted {
+		opts = append(opts, cookie.WithSorted(true))
+	}
+	if config.Sort == cli.SortFirstSeen {
+		opts = append(opts, cookie.WithFirstSeenOrder())
+	}
+	return opts
+}
+
+// explainEmpty tells the user on stderr why no cookie was printed, comparing the
+// target date against the dates the file actually covers.
+func explainEmpty(config *cli.Config) {
+	if len(config.Filenames) > 1 {
+		fmt.Fprintf(os.Stderr, "No cookies found: no entries in the %d input files fall on %s\n", len(config.Filenames), config.TargetDate)
+		return
+	}
+	if config.Filename == parser.Stdin {
+		// Stdin has been consumed, so its date range cannot be read again.
+		fmt.Fprintf(os.Stderr, "No cookies found: no entries on stdin fall on %s\n", config.TargetDate)
+		return
+	}
+
+	dates, err := cookie.FileDateRange(config.Filename, libraryOptions(config)...)
+	if err != nil {
+		slog.Warn("could not determine the file's date range", "error", err, "filename", config.Filename)
+		return
+	}
+
+	switch {
+	case dates.Entries == 0:
+		fmt.Fprintf(os.Stderr, "No cookies found: %s contains no entries\n", config.Filename)
+	case dates.Contains(config.TargetDate):
+		fmt.Fprintf(os.Stderr, "No cookies found: the file's range %s..%s includes %s, but no entries fall on that date\n",
+			dates.First, dates.Last, config.TargetDate)
+	default:
+		fmt.Fprintf(os.Stderr, "No cookies found: your date %s is outside the file's range %s..%s\n",
+			config.TargetDate, dates.First, dates.Last)
+	}
+}
+
+// runManifest runs every job listed in the manifest and returns its
+// file,date,winner,error rows, with an empty winner when nothing matched and
+// an empty error unless the job failed. Failed jobs are also reported on
+// stderr and, unless -fail-fast is set, the remaining jobs still run. It
+// reports whether any job failed.
+func runManifest(config *cli.Config) ([][]string, bool) {
+	jobs, err := cli.ReadManifest(config.Manifest)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		return nil, true
+	}
+
+	var rows [][]string
+	failed := false
+	for _, job := range jobs {
+		slog.Info("running manifest job", "filename", job.Filename, "targetDate", job.TargetDate)
+
+		cookies, err := cookie.FindMostActiveCookiesWithOptions(job.Filename, job.TargetDate, libraryOptions(config)...)
+		if err != nil {
+			failed = true
+			rows = append(rows, []string{job.Filename, job.TargetDate, "", err.Error()})
+			fmt.Fprintf(os.Stderr, "%s,%s: %v\n", job.Filename, job.TargetDate, err)
+			if config.FailFast {
+				break
+			}
+			continue
+		}
+
+		if len(cookies) == 0 {
+			cookies = []string{""}
+		}
+		for _, c := range cookies {
+			rows = append(rows, []string{job.Filename, job.TargetDate, c, ""})
+		}
+	}
+	return rows, failed
+}
+
+// outputManifest writes the manifest rows as CSV.
+func outputManifest(w io.Writer, rows [][]string) {
+	if err := csv.NewWriter(w).WriteAll(rows); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write results: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// checkSorted reports whether the file is sorted by date. The error names the
+// first out-of-order line.
+func checkSorted(config *cli.Config) error {
+	slog.Info("checking date order", "filename", config.Filename)
+
+	return cookie.CheckSorted(config.Filename, libraryOptions(config)...)
+}
+
+// outputSorted writes the -sort-check confirmation for filename.
+func outputSorted(w io.Writer, filename string) {
+	if _, err := fmt.Fprintf(w, "%s is sorted by date\n", filename); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write results: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func processHours(config *cli.Config) ([]cookie.HourResult, error) {
+	slog.Info("starting per-hour processing", "filename", config.Filename, "targetDate", config.TargetDate)
+
+	hours, err := cookie.TopCookiePerHour(config.Filename, config.TargetDate, libraryOptions(config)...)
+	if err != nil {
+		slog.Error("processing failed", "error", err, "filename", config.Filename)
+		return nil, err
+	}
+
+	return hours, nil
+}
+
+func outputHours(w io.Writer, hours []cookie.HourResult) {
+	for _, h := range hours {
+		winners := "-"
+		if len(h.Cookies) > 0 {
+			winners = strings.Join(h.Cookies, ",")
+		}
+		if _, err := fmt.Fprintf(w, "%02d:00 %s %d\n", h.Hour, winners, h.Count); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write results: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// outputRanked writes one "rank. cookie (count)" line per cookie.
+func outputRanked(w io.Writer, ranked []cookie.RankedCookie) {
+	for _, r := range ranked {
+		if _, err := fmt.Fprintf(w, "%d. %s (%d)\n", r.Rank, r.Cookie, r.Count); err != nil {
+			fmt.Fprintf(os.Stderr, "failed to write results: %v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+func outputResults(w io.Writer, cookies []string, print0, color bool) {
 	if len(cookies) == 0 {
 		slog.Debug("no cookies found for target date - exiting quietly")
 		os.Exit(0)
 	}
 
-	for _, c := range cookies {
-		fmt.Println(c)
+	format := cookie.FormatText
+	if print0 {
+		format = cookie.FormatNullDelimited
+		color = false
+	}
+
+	if color {
+		cookies = highlight(cookies)
+	}
+
+	if err := cookie.WriteCookies(w, cookies, format); err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
 	}
 }
 
-func configureLogging(verbosity int) {
+// outputDates writes each date's winners under a "date:" heading, in the order
+// the dates were given. A date without winners gets a bare heading.
+func outputDates(w io.Writer, dates []string, results map[string][]cookie.CookieCount, color bool) {
+	for _, date := range dates {
+		cookies := winnersOf(results[date])
+		if color {
+			cookies = highlight(cookies)
+		}
+
+		_, err := fmt.Fprintf(w, "%s:\n", date)
+		if err == nil {
+			err = cookie.WriteCookies(w, cookies, cookie.FormatText)
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v\n", err)
+			os.Exit(1)
+		}
+	}
+}
+
+// outputTable writes one aligned row per date, newest first, with the date's
+// winners comma-joined and their shared count. A date without winners shows
+// "-" and 0.
+func outputTable(w io.Writer, dates []string, results map[string][]cookie.CookieCount) {
+	dates = slices.Clone(dates)
+	slices.SortFunc(dates, func(a, b string) int { return strings.Compare(b, a) })
+
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(table, "date\twinners\tcount")
+	for _, date := range dates {
+		winners := results[date]
+		cell, count := "-", 0
+		if len(winners) > 0 {
+			cell, count = strings.Join(winnersOf(winners), ", "), winners[0].Count
+		}
+		fmt.Fprintf(table, "%s\t%s\t%d\n", date, cell, count)
+	}
+	if err := table.Flush(); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write results: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// outputComparison writes each date's winners with their count, then one
+// "rose" or "fell" line per cookie whose count changed, biggest rise first.
+func outputComparison(w io.Writer, comparison cookie.Comparison) {
+	err := writeDateWinners(w, comparison.Before, comparison.BeforeWinners)
+	if err == nil {
+		err = writeDateWinners(w, comparison.After, comparison.AfterWinners)
+	}
+	for _, change := range comparison.Changes {
+		if err != nil {
+			break
+		}
+		direction := "rose"
+		if change.Delta() < 0 {
+			direction = "fell"
+		}
+		_, err = fmt.Fprintf(w, "%s %s: %d -> %d (%+d)\n", direction, change.Cookie, change.Before, change.After, change.Delta())
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write results: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// writeDateWinners writes a "date: winners (count)" line, or "date: none"
+// when the date has no entries.
+func writeDateWinners(w io.Writer, date string, winners []cookie.CookieCount) error {
+	if len(winners) == 0 {
+		_, err := fmt.Fprintf(w, "%s: none\n", date)
+		return err
+	}
+	_, err := fmt.Fprintf(w, "%s: %s (%d)\n", date, strings.Join(winnersOf(winners), ", "), winners[0].Count)
+	return err
+}
+
+func highlight(cookies []string) []string {
+	highlighted := make([]string, len(cookies))
+	for i, c := range cookies {
+		highlighted[i] = ansiBoldGreen + c + ansiReset
+	}
+	return highlighted
+}
+
+// outputCounts writes the winners with their counts through the formatter
+// registered for -format.
+func outputCounts(w io.Writer, counts []cookie.CookieCount, config *cli.Config) {
+	formatter, err := cookie.Format(config.Format).Formatter()
+	if err == nil {
+		if headered, ok := formatter.(cookie.HeaderedFormatter); ok && config.NoHeader {
+			formatter = headered.WithoutHeader()
+		}
+		err = formatter.Format(w, counts)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%v\n", err)
+		os.Exit(1)
+	}
+}
+
+// useColor reports whether output should be colorized: only when stdout is a
+// terminal and neither -no-color nor the NO_COLOR environment variable is set.
+func useColor(config *cli.Config) bool {
+	if config.NoColor || os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	return term.IsTerminal(int(os.Stdout.Fd())) //nolint:gosec
+}
+
+func configureLogging(verbosity int, quiet bool) {
 	var level slog.Level
-	switch verbosity {
-	case 0:
-		level = slog.LevelWarn // Default: quiet
-	case 1:
+	switch {
+	case quiet:
+		level = slog.LevelError + 1 // -q: silent; fatal errors are still printed directly
+	case verbosity == 0:
+		level = slog.LevelWarn // Default: warnings only
+	case verbosity == 1:
 		level = slog.LevelInfo // -v: verbose
 	default:
 		level = slog.LevelDebug // -vv: debug
@@ -69,3 +636,62 @@ func configureLogging(verbosity int) {
 	}))
 	slog.SetDefault(logger)
 }
+
+// logRunSummary logs the wall time and memory footprint of the run at INFO
+// level, i.e. only for -v and -vv.
+func logRunSummary(started time.Time) {
+	if !slog.Default().Enabled(context.Background(), slog.LevelInfo) {
+		return
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	slog.Info("run summary",
+		"duration", time.Since(started).Round(time.Millisecond),
+		"allocatedMB", fmt.Sprintf("%.2f", float64(mem.TotalAlloc)/1024/1024),
+		"peakMemoryMB", fmt.Sprintf("%.2f", float64(mem.Sys)/1024/1024))
+}
+
+// startProfiling starts the CPU profile if requested and returns a function that
+// stops it and writes the heap profile. Both profiles are no-ops when unset.
+func startProfiling(config *cli.Config) func() {
+	var cpuFile *os.File
+	if config.CPUProfile != "" {
+		f, err := os.Create(config.CPUProfile) //nolint:gosec
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: could not create CPU profile: %v\n", err)
+			os.Exit(1)
+		}
+		if err := pprof.StartCPUProfile(f); err != nil {
+			f.Close()
+			fmt.Fprintf(os.Stderr, "Error: could not start CPU profile: %v\n", err)
+			os.Exit(1)
+		}
+		cpuFile = f
+		slog.Debug("CPU profiling enabled", "path", config.CPUProfile)
+	}
+
+	return func() {
+		if cpuFile != nil {
+			pprof.StopCPUProfile()
+			cpuFile.Close()
+		}
+		if config.MemProfile != "" {
+			writeMemProfile(config.MemProfile)
+		}
+	}
+}
+
+func writeMemProfile(path string) {
+	f, err := os.Create(path) //nolint:gosec
+	if err != nil {
+		slog.Warn("could not create memory profile", "error", err, "path", path)
+		return
+	}
+	defer f.Close()
+
+	runtime.GC()
+	if err := pprof.WriteHeapProfile(f); err != nil {
+		slog.Warn("could not write memory profile", "error", err, "path", path)
+	}
+}
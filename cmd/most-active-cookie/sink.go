@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+
+	"github.com/mfenderov/most-active-cookie/src/cli"
+)
+
+// openOutput returns where results are written: the -o file, created or
+// truncated, when set, and the -sink destination otherwise. The returned close
+// must be checked, as it reports errors finishing the file or closing the
+// syslog connection.
+func openOutput(config *cli.Config) (io.Writer, func() error) {
+	if config.Output == "" {
+		return openSink(config.Sink)
+	}
+
+	file, err := os.Create(config.Output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to create output file: %v\n", err)
+		os.Exit(1)
+	}
+	return file, file.Close
+}
+
+// openSink returns where results are written, and how to close it: stdout by
+// default, or the system logger for -sink syslog. An unavailable sink falls
+// back to stdout with a warning so results are never lost.
+func openSink(name string) (io.Writer, func() error) {
+	if name != cli.SinkSyslog {
+		return os.Stdout, func() error { return nil }
+	}
+
+	sink, err := newSyslogSink()
+	if err != nil {
+		slog.Warn("syslog sink unavailable, writing results to stdout", "error", err)
+		return os.Stdout, func() error { return nil }
+	}
+	return sink, sink.Close
+}
@@ -0,0 +1,13 @@
+//go:build windows || plan9
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"runtime"
+)
+
+func newSyslogSink() (io.WriteCloser, error) {
+	return nil, fmt.Errorf("syslog is not supported on %s", runtime.GOOS)
+}
@@ -3,6 +3,12 @@
 package cookie
 
 import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+	"time"
+
 	"github.com/mfenderov/most-active-cookie/src/cookie"
 	"github.com/mfenderov/most-active-cookie/src/parser"
 )
@@ -16,6 +22,8 @@ import (
 //	AtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00
 //	SAZuXPGUrfbcn5UA,2018-12-09T10:13:00+00:00
 //
+// A filename of "-" reads the log from standard input instead.
+//
 // The targetDate parameter should be in YYYY-MM-DD format (UTC timezone).
 //
 // Returns a sorted slice of cookie names that appeared most frequently on the target date.
@@ -32,7 +40,559 @@ import (
 //	    fmt.Println(cookie)
 //	}
 func FindMostActiveCookies(filename, targetDate string) ([]string, error) {
-	csvParser := parser.NewCSVParser()
-	processor := cookie.NewProcessor(csvParser)
+	return FindMostActiveCookiesWithOptions(filename, targetDate)
+}
+
+// Option customizes how FindMostActiveCookiesWithOptions reads and analyzes the log.
+type Option func(*options)
+
+type options struct {
+	parserOpts    []parser.Option
+	processorOpts []cookie.Option
+	topN          int
+}
+
+// newOptions applies opts in order.
+func newOptions(opts []Option) *options {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// rejectTopN fails entry points other than those returning a ranked cookie
+// list when WithTopN is set, rather than silently ignoring it.
+func (o *options) rejectTopN(function string) error {
+	if o.topN != 0 {
+		return fmt.Errorf("WithTopN is not supported by %s", function)
+	}
+	return nil
+}
+
+// newProcessor builds the CSV parser and processor every file-based entry
+// point runs on, returning the applied options for those handled outside them.
+func newProcessor(opts []Option) (*cookie.Processor, *options) {
+	o := newOptions(opts)
+	csvParser := parser.NewCSVParser(o.parserOpts...)
+	return cookie.NewProcessor(csvParser, o.processorOpts...), o
+}
+
+// WithMaxLines aborts the analysis with an error once more than n lines have
+// been read, guarding against runaway inputs. Zero means unlimited.
+func WithMaxLines(n int) Option {
+	return func(o *options) {
+		o.parserOpts = append(o.parserOpts, parser.WithMaxLines(n))
+	}
+}
+
+// WithAssumedLocation treats timestamps as offset-less local times in loc,
+// converting them to UTC before bucketing by date. Timestamps that include an
+// offset are rejected.
+func WithAssumedLocation(loc *time.Location) Option {
+	return func(o *options) {
+		o.parserOpts = append(o.parserOpts, parser.WithAssumedLocation(loc))
+	}
+}
+
+// WithTimestampLayouts accepts timestamps in any of the given Go time layouts,
+// such as "2006-01-02 15:04:05", instead of only RFC3339. Dates are taken from
+// the parsed times; layouts without a zone are read as UTC.
+func WithTimestampLayouts(layouts ...string) Option {
+	return func(o *options) {
+		o.parserOpts = append(o.parserOpts, parser.WithTimestampLayouts(layouts...))
+	}
+}
+
+// WithSorted declares whether the log is sorted by date in ascending order, as
+// CheckSorted verifies. Queries on a sorted log stop reading at the first entry
+// past the target date; by default the whole file is read, which is slower but
+// correct for logs in any order.
+func WithSorted(sorted bool) Option {
+	return func(o *options) {
+		o.processorOpts = append(o.processorOpts, cookie.WithSorted(sorted))
+	}
+}
+
+// WithFirstSeenOrder lists tied winners in the order they first appear in the
+// file on the target date instead of alphabetically.
+func WithFirstSeenOrder() Option {
+	return func(o *options) {
+		o.processorOpts = append(o.processorOpts, cookie.WithWinnerOrder(cookie.OrderFirstSeen))
+	}
+}
+
+// WithDuplicateTracking counts target-date entries that repeat a cookie and
+// timestamp already seen, reported as ScanStats.Duplicates by
+// FindMostActiveCookiesWithStats. Every distinct cookie/timestamp pair of the
+// target date is held in memory for the scan, so it is off by default.
+func WithDuplicateTracking() Option {
+	return func(o *options) {
+		o.processorOpts = append(o.processorOpts, cookie.WithDuplicateTracking())
+	}
+}
+
+// WithTimeSpans sets FirstSeen and LastSeen on the winners returned by
+// FindMostActiveCookiesWithCounts and the other CookieCount results, the
+// earliest and latest timestamp each winner appeared at on the target date.
+func WithTimeSpans() Option {
+	return func(o *options) {
+		o.processorOpts = append(o.processorOpts, cookie.WithTimeSpans())
+	}
+}
+
+// WithEndExclusive makes FindMostActiveCookiesInRange exclude its end date,
+// counting the half-open range from midnight of from to midnight of to.
+func WithEndExclusive() Option {
+	return func(o *options) {
+		o.processorOpts = append(o.processorOpts, cookie.WithEndExclusive())
+	}
+}
+
+// WithWeightColumn reads an optional weight column with the given header name
+// (e.g. cookie,timestamp,weight) so each row adds its weight to the cookie's
+// count instead of one. Rows without a valid positive weight count once, unless
+// WithStrictUTF8 is set.
+func WithWeightColumn(name string) Option {
+	return func(o *options) {
+		o.parserOpts = append(o.parserOpts, parser.WithWeightColumn(name))
+	}
+}
+
+// WithCommentPrefix skips lines starting with prefix, such as '#', including
+// any above the header.
+func WithCommentPrefix(prefix byte) Option {
+	return func(o *options) {
+		o.parserOpts = append(o.parserOpts, parser.WithCommentPrefix(prefix))
+	}
+}
+
+// WithoutHeader reads logs that have no header row, treating the first line as
+// an entry.
+func WithoutHeader() Option {
+	return func(o *options) {
+		o.parserOpts = append(o.parserOpts, parser.WithoutHeader())
+	}
+}
+
+// WithSkipInvalidLines skips lines that fail to parse instead of aborting the
+// analysis, calling onInvalidLine, if not nil, for each one skipped.
+func WithSkipInvalidLines(onInvalidLine func(lineNum int, err error)) Option {
+	return func(o *options) {
+		o.parserOpts = append(o.parserOpts, parser.WithSkipInvalidLines(onInvalidLine))
+	}
+}
+
+// WithStrictUTF8 rejects lines whose cookie ID is not valid UTF-8 instead of
+// counting the raw bytes, and lines whose weight is not a positive integer
+// instead of counting them once.
+func WithStrictUTF8() Option {
+	return func(o *options) {
+		o.parserOpts = append(o.parserOpts, parser.WithStrictUTF8())
+	}
+}
+
+// WithTopN has FindMostActiveCookiesWithOptions, FindMostActive and
+// FindMostActiveCookiesFromReader return up to n cookies, most active first and
+// alphabetical among equal counts, instead of only those tied for the highest
+// count. FindWinner ignores it; other entry points reject it with an error.
+// Zero keeps the default.
+func WithTopN(n int) Option {
+	return func(o *options) {
+		o.topN = n
+	}
+}
+
+// WithLocation treats targetDate as a calendar day in loc rather than in UTC,
+// bucketing each entry by its local date. A nil loc means UTC.
+func WithLocation(loc *time.Location) Option {
+	return func(o *options) {
+		o.processorOpts = append(o.processorOpts, cookie.WithLocation(loc))
+	}
+}
+
+// WithCaseInsensitive counts cookie IDs that differ only in case as one
+// cookie, reported in lower case.
+func WithCaseInsensitive() Option {
+	return func(o *options) {
+		o.parserOpts = append(o.parserOpts, parser.WithCaseInsensitiveCookies())
+	}
+}
+
+// FindMostActiveCookiesWithOptions is FindMostActiveCookies with additional
+// behavior configured through opts.
+func FindMostActiveCookiesWithOptions(filename, targetDate string, opts ...Option) ([]string, error) {
+	processor, o := newProcessor(opts)
+	if o.topN != 0 {
+		return processor.FindTopCookies(filename, targetDate, o.topN)
+	}
 	return processor.FindMostActiveCookies(filename, targetDate)
 }
+
+// FindMostActiveCookiesInFiles is FindMostActiveCookiesWithOptions over the
+// combined counts of several files, such as the shards of one day's log. Each
+// file must have its own header; an error names the file that caused it.
+func FindMostActiveCookiesInFiles(filenames []string, targetDate string, opts ...Option) ([]string, error) {
+	processor, o := newProcessor(opts)
+	if err := o.rejectTopN("FindMostActiveCookiesInFiles"); err != nil {
+		return nil, err
+	}
+	return processor.FindMostActiveCookiesInFiles(filenames, targetDate)
+}
+
+// ScanStats summarizes what was read from a log file: lines, accepted entries,
+// skipped blank, comment and invalid lines, whether reading stopped early at
+// the first entry past the target date, and the warnings raised on the way.
+type ScanStats = cookie.ScanStats
+
+// Warning is a non-fatal finding of a scan, with a code and a message.
+type Warning = cookie.Warning
+
+// WarningCode identifies the kind of a Warning.
+type WarningCode = cookie.WarningCode
+
+// The codes of the warnings a scan can raise.
+const (
+	WarningInvalidLine      = cookie.WarningInvalidLine
+	WarningMixedLineEndings = cookie.WarningMixedLineEndings
+	WarningByteOrderMark    = cookie.WarningByteOrderMark
+	WarningStoppedEarly     = cookie.WarningStoppedEarly
+)
+
+// FindMostActiveCookiesWithStats is FindMostActiveCookiesWithOptions also
+// returning statistics about the scan, to check that a file was fully read.
+func FindMostActiveCookiesWithStats(filename, targetDate string, opts ...Option) ([]string, ScanStats, error) {
+	processor, o := newProcessor(opts)
+	if err := o.rejectTopN("FindMostActiveCookiesWithStats"); err != nil {
+		return nil, ScanStats{}, err
+	}
+	return processor.FindMostActiveCookiesWithStats(filename, targetDate)
+}
+
+// FindMostActiveCookiesInLocation is FindMostActiveCookies with targetDate
+// meaning a calendar day in loc rather than in UTC.
+func FindMostActiveCookiesInLocation(filename, targetDate string, loc *time.Location) ([]string, error) {
+	return FindMostActiveCookiesWithOptions(filename, targetDate, WithLocation(loc))
+}
+
+// FindMostActiveCookiesFromReader is FindMostActiveCookiesWithOptions for a log
+// read from r instead of a file, such as an in-memory buffer or a network
+// stream.
+func FindMostActiveCookiesFromReader(r io.Reader, targetDate string, opts ...Option) ([]string, error) {
+	o := newOptions(opts)
+	csvParser := readerParser{r: r, parser: parser.NewCSVParser(o.parserOpts...)}
+	processor := cookie.NewProcessor(csvParser, o.processorOpts...)
+	if o.topN != 0 {
+		return processor.FindTopCookies(readerInput, targetDate, o.topN)
+	}
+	return processor.FindMostActiveCookies(readerInput, targetDate)
+}
+
+// readerInput is the filename handed to the processor for reader input;
+// readerParser ignores it.
+const readerInput = "<reader>"
+
+// readerParser adapts a CSVParser to cookie.FileParser for a single reader.
+type readerParser struct {
+	r      io.Reader
+	parser *parser.CSVParser
+}
+
+func (rp readerParser) StreamFile(_ string, processor cookie.EntryProcessor) error {
+	return rp.parser.StreamReader(rp.r, processor)
+}
+
+// FindMostActive is FindMostActiveCookiesWithOptions with an explicit found
+// flag: found is false when the file was analyzed successfully but nothing
+// matched the target date, and true when winners holds at least one cookie.
+// On error found is false and winners is nil.
+func FindMostActive(filename, targetDate string, opts ...Option) (found bool, winners []string, err error) {
+	winners, err = FindMostActiveCookiesWithOptions(filename, targetDate, opts...)
+	if err != nil {
+		return false, nil, err
+	}
+	return len(winners) > 0, winners, nil
+}
+
+// FindMostActiveCookiesAccumulated keeps a running aggregate across runs in the
+// JSON state file at statePath: filename's counts are added to the saved
+// per-date counts, unless the same file content was added before, and the
+// most active cookie(s) for targetDate across all accumulated files are
+// returned. The state file is created on first use. Only local files are
+// supported.
+func FindMostActiveCookiesAccumulated(statePath, filename, targetDate string, opts ...Option) ([]string, error) {
+	processor, o := newProcessor(opts)
+	if err := o.rejectTopN("FindMostActiveCookiesAccumulated"); err != nil {
+		return nil, err
+	}
+
+	state, err := cookie.LoadState(statePath)
+	if err != nil {
+		return nil, err
+	}
+
+	cookies, err := processor.FindMostActiveAccumulated(filename, targetDate, state)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := state.Save(statePath); err != nil {
+		return nil, err
+	}
+	return cookies, nil
+}
+
+// CookieCount pairs a cookie with its number of occurrences.
+type CookieCount = cookie.CookieCount
+
+// Standings holds a date's winner(s), their count and, for a unique winner,
+// the runners-up behind it.
+type Standings = cookie.Standings
+
+// FindStandings returns the most active cookie(s) for targetDate plus, when a
+// single cookie wins outright, the cookies in the next depth count tiers
+// behind it. Tied winners get no runners-up.
+func FindStandings(filename, targetDate string, depth int, opts ...Option) (Standings, error) {
+	processor, o := newProcessor(opts)
+	if err := o.rejectTopN("FindStandings"); err != nil {
+		return Standings{}, err
+	}
+	return processor.FindStandings(filename, targetDate, depth)
+}
+
+// FindMostActiveCookiesWithCounts returns the most active cookie(s) for
+// targetDate, in the same order as FindMostActiveCookiesWithOptions, each with
+// the count they share.
+func FindMostActiveCookiesWithCounts(filename, targetDate string, opts ...Option) ([]CookieCount, error) {
+	processor, o := newProcessor(opts)
+	if err := o.rejectTopN("FindMostActiveCookiesWithCounts"); err != nil {
+		return nil, err
+	}
+	return processor.FindMostActiveCookiesWithCounts(filename, targetDate)
+}
+
+// RankedCookie is a cookie with its count and competition rank: equal counts
+// share a rank and the next rank skips past them, as in 1, 1, 3.
+type RankedCookie = cookie.RankedCookie
+
+// FindRankedCookies returns up to n cookies for targetDate, most active first
+// and alphabetical among equal counts, each with its count and rank, e.g. for
+// a leaderboard.
+func FindRankedCookies(filename, targetDate string, n int, opts ...Option) ([]RankedCookie, error) {
+	processor, o := newProcessor(opts)
+	if err := o.rejectTopN("FindRankedCookies"); err != nil {
+		return nil, err
+	}
+	return processor.FindRankedCookies(filename, targetDate, n)
+}
+
+// Summary describes the scan behind a single-date result: the parser's
+// statistics and the number of distinct cookies on the date.
+type Summary = cookie.Summary
+
+// FindMostActiveCookiesWithSummary is FindMostActiveCookiesWithCounts also
+// summarizing the scan, e.g. for a footer after human-readable output.
+func FindMostActiveCookiesWithSummary(filename, targetDate string, opts ...Option) ([]CookieCount, Summary, error) {
+	processor, o := newProcessor(opts)
+	if err := o.rejectTopN("FindMostActiveCookiesWithSummary"); err != nil {
+		return nil, Summary{}, err
+	}
+	return processor.FindMostActiveCookiesWithSummary(filename, targetDate)
+}
+
+// FindLeastActiveCookies returns the cookie(s) with the fewest occurrences on
+// targetDate, alphabetically, or an empty slice when the date has no entries.
+func FindLeastActiveCookies(filename, targetDate string, opts ...Option) ([]string, error) {
+	processor, o := newProcessor(opts)
+	if err := o.rejectTopN("FindLeastActiveCookies"); err != nil {
+		return nil, err
+	}
+	return processor.FindLeastActiveCookies(filename, targetDate)
+}
+
+// FindCookiesAboveThreshold returns every cookie with at least minCount
+// occurrences on targetDate and its count, most active first and alphabetical
+// among equal counts.
+func FindCookiesAboveThreshold(filename, targetDate string, minCount int, opts ...Option) ([]CookieCount, error) {
+	processor, o := newProcessor(opts)
+	if err := o.rejectTopN("FindCookiesAboveThreshold"); err != nil {
+		return nil, err
+	}
+	return processor.FindCookiesAboveThreshold(filename, targetDate, minCount)
+}
+
+// FindMostActiveCookiesByDate returns the most active cookie(s) for each of
+// targetDates, keyed by date, reading the file only once.
+func FindMostActiveCookiesByDate(filename string, targetDates []string, opts ...Option) (map[string][]string, error) {
+	processor, o := newProcessor(opts)
+	if err := o.rejectTopN("FindMostActiveCookiesByDate"); err != nil {
+		return nil, err
+	}
+	return processor.FindMostActiveCookiesByDate(filename, targetDates)
+}
+
+// FindMostActiveCookiesWithCountsByDate returns the most active cookie(s) for
+// each of targetDates with their counts, keyed by date, reading the file only
+// once.
+func FindMostActiveCookiesWithCountsByDate(filename string, targetDates []string, opts ...Option) (map[string][]CookieCount, error) {
+	processor, o := newProcessor(opts)
+	if err := o.rejectTopN("FindMostActiveCookiesWithCountsByDate"); err != nil {
+		return nil, err
+	}
+	return processor.FindMostActiveCookiesWithCountsByDate(filename, targetDates)
+}
+
+// CookieChange is a cookie whose count differs between two compared dates.
+type CookieChange = cookie.CookieChange
+
+// Comparison holds the winners of two dates and the cookies whose counts rose
+// or fell between them.
+type Comparison = cookie.Comparison
+
+// CompareDates returns the winners of before and after, both YYYY-MM-DD, and
+// how each cookie's count moved from one to the other, reading the file only
+// once.
+func CompareDates(filename, before, after string, opts ...Option) (Comparison, error) {
+	processor, o := newProcessor(opts)
+	if err := o.rejectTopN("CompareDates"); err != nil {
+		return Comparison{}, err
+	}
+	return processor.CompareDates(filename, before, after)
+}
+
+// FindMostActiveCookiesInRange returns the most active cookie(s) counted over
+// every date from from to to, both YYYY-MM-DD and inclusive unless
+// WithEndExclusive is given. It is an error for from to be after to.
+func FindMostActiveCookiesInRange(filename, from, to string, opts ...Option) ([]string, error) {
+	processor, o := newProcessor(opts)
+	if err := o.rejectTopN("FindMostActiveCookiesInRange"); err != nil {
+		return nil, err
+	}
+	return processor.FindMostActiveCookiesInRange(filename, from, to)
+}
+
+// DatesBetween returns each YYYY-MM-DD date from start to end inclusive, for
+// expanding a date range into individual per-date queries.
+func DatesBetween(start, end string) ([]string, error) {
+	return cookie.DatesBetween(start, end)
+}
+
+// ErrUnsorted reports that a file's entries are not in ascending date order.
+var ErrUnsorted = cookie.ErrUnsorted
+
+// CheckSorted verifies that the file's entries are in ascending date order,
+// which date queries rely on to stop reading early. It returns an error
+// wrapping ErrUnsorted that names the first offending line otherwise.
+func CheckSorted(filename string, opts ...Option) error {
+	processor, o := newProcessor(opts)
+	if err := o.rejectTopN("CheckSorted"); err != nil {
+		return err
+	}
+	return processor.CheckSorted(filename)
+}
+
+// ParseError reports the line, and where possible the column, of a log line
+// that could not be parsed. Use errors.As to retrieve it from an error.
+type ParseError = parser.ParseError
+
+// TieError reports that several cookies share the highest count where exactly
+// one winner was required.
+type TieError struct {
+	Cookies []string
+}
+
+func (e *TieError) Error() string {
+	return fmt.Sprintf("no unique winner: %d cookies tied: %s", len(e.Cookies), strings.Join(e.Cookies, ", "))
+}
+
+// FindWinner returns the single most active cookie for targetDate, for callers
+// that require exactly one winner. A tie is returned as a *TieError listing the
+// tied cookies; an empty string with a nil error means nothing matched.
+func FindWinner(filename, targetDate string, opts ...Option) (string, error) {
+	cookies, err := FindMostActiveCookiesWithOptions(filename, targetDate, append(slices.Clip(opts), WithTopN(0))...)
+	if err != nil {
+		return "", err
+	}
+
+	switch len(cookies) {
+	case 0:
+		return "", nil
+	case 1:
+		return cookies[0], nil
+	default:
+		return "", &TieError{Cookies: cookies}
+	}
+}
+
+// DistinctValuesForWinner counts the distinct non-empty values of column, a
+// header column such as region or user_agent, among the target-date entries
+// of the single most active cookie, e.g. how many regions it spanned. It
+// returns 0 when nothing matched and an error when the top cookies tie.
+func DistinctValuesForWinner(filename, targetDate, column string, opts ...Option) (int, error) {
+	processor, o := newProcessor(opts)
+	if err := o.rejectTopN("DistinctValuesForWinner"); err != nil {
+		return 0, err
+	}
+	return processor.DistinctValuesForWinner(filename, targetDate, column)
+}
+
+// FindCookieActiveOnMostDays returns the cookie(s) seen on the most distinct
+// dates anywhere in the file, alphabetically when tied, for finding the most
+// persistent visitors rather than the busiest ones.
+func FindCookieActiveOnMostDays(filename string, opts ...Option) ([]string, error) {
+	processor, o := newProcessor(opts)
+	if err := o.rejectTopN("FindCookieActiveOnMostDays"); err != nil {
+		return nil, err
+	}
+	return processor.FindCookieActiveOnMostDays(filename)
+}
+
+// CookieDailyCounts returns the number of hits for cookieID on each date in
+// the file, keyed by YYYY-MM-DD, for plotting one cookie's activity over time.
+func CookieDailyCounts(filename, cookieID string, opts ...Option) (map[string]int, error) {
+	processor, o := newProcessor(opts)
+	if err := o.rejectTopN("CookieDailyCounts"); err != nil {
+		return nil, err
+	}
+	return processor.CookieDailyCounts(filename, cookieID)
+}
+
+// DateRange is the span of entry dates found in a log file.
+type DateRange = cookie.DateRange
+
+// FileDateRange returns the earliest and latest entry dates in the file. It is
+// useful for explaining why a query returned nothing, e.g. because the target
+// date lies outside the file.
+func FileDateRange(filename string, opts ...Option) (DateRange, error) {
+	processor, o := newProcessor(opts)
+	if err := o.rejectTopN("FileDateRange"); err != nil {
+		return DateRange{}, err
+	}
+	return processor.DateRange(filename)
+}
+
+// Schema describes the detected layout of a log file.
+type Schema = parser.Schema
+
+// DetectSchema inspects the header and up to sampleRows data rows of a log file
+// and reports its delimiter, columns, timestamp layout and byte order mark,
+// without analyzing the rest of the file. Schema.Accepted tells whether the
+// other functions, given the same opts, would accept the header.
+func DetectSchema(filename string, sampleRows int, opts ...Option) (Schema, error) {
+	o := newOptions(opts)
+	return parser.NewCSVParser(o.parserOpts...).DetectSchema(filename, sampleRows)
+}
+
+// HourResult holds the most active cookie(s) within one hour of a day.
+type HourResult = cookie.HourResult
+
+// TopCookiePerHour returns the most active cookie(s) for each of the 24 hours of
+// the target date. Hours without entries are included with no cookies and a
+// zero count, so the result always has 24 rows.
+func TopCookiePerHour(filename, targetDate string, opts ...Option) ([]HourResult, error) {
+	processor, o := newProcessor(opts)
+	if err := o.rejectTopN("TopCookiePerHour"); err != nil {
+		return nil, err
+	}
+	return processor.TopCookiePerHour(filename, targetDate)
+}
@@ -0,0 +1,158 @@
+package cookie_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	cookie "github.com/mfenderov/most-active-cookie"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const sampleLog = "./integration-tests/test-data/sample_cookie_log.csv"
+
+// writeLog writes content to a log file in a temporary directory.
+func writeLog(t *testing.T, content string) string {
+	filename := filepath.Join(t.TempDir(), "cookie_log.csv")
+	require.NoError(t, os.WriteFile(filename, []byte(content), 0o600), "failed to write log")
+	return filename
+}
+
+func TestFindMostActiveCookiesWithOptions_TopN(t *testing.T) {
+	tests := []struct {
+		name          string
+		n             int
+		expected      []string
+		errorContains string
+	}{
+		{
+			name:     "leader first, then alphabetical",
+			n:        3,
+			expected: []string{"AtY0laUfhglK3lC7", "5UAVanZf6UtGyKVS", "SAZuXPGUrfbcn5UA"},
+		},
+		{
+			name:     "fewer cookies than n",
+			n:        10,
+			expected: []string{"AtY0laUfhglK3lC7", "5UAVanZf6UtGyKVS", "SAZuXPGUrfbcn5UA"},
+		},
+		{
+			name:     "zero keeps the winners",
+			expected: []string{"AtY0laUfhglK3lC7"},
+		},
+		{
+			name:          "negative n",
+			n:             -1,
+			errorContains: "n must be at least 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cookies, err := cookie.FindMostActiveCookiesWithOptions(sampleLog, "2018-12-09", cookie.WithTopN(tt.n))
+
+			if tt.errorContains != "" {
+				assert.ErrorContains(t, err, tt.errorContains, "error mismatch")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, cookies, "result mismatch")
+		})
+	}
+}
+
+func TestWithTopN_OtherEntryPoints(t *testing.T) {
+	t.Run("reader", func(t *testing.T) {
+		log, err := os.ReadFile(sampleLog)
+		require.NoError(t, err, "failed to read sample log")
+
+		cookies, err := cookie.FindMostActiveCookiesFromReader(strings.NewReader(string(log)), "2018-12-09", cookie.WithTopN(2))
+
+		assert.NoError(t, err, "unexpected error")
+		assert.Equal(t, []string{"AtY0laUfhglK3lC7", "5UAVanZf6UtGyKVS"}, cookies, "result mismatch")
+	})
+
+	t.Run("winner ignores it", func(t *testing.T) {
+		winner, err := cookie.FindWinner(sampleLog, "2018-12-09", cookie.WithTopN(3))
+
+		assert.NoError(t, err, "a clear leader is not a tie")
+		assert.Equal(t, "AtY0laUfhglK3lC7", winner, "winner mismatch")
+	})
+
+	t.Run("rejected where unsupported", func(t *testing.T) {
+		_, err := cookie.FindMostActiveCookiesInFiles([]string{sampleLog}, "2018-12-09", cookie.WithTopN(3))
+		assert.ErrorContains(t, err, "WithTopN is not supported by FindMostActiveCookiesInFiles", "files")
+
+		_, err = cookie.FindMostActiveCookiesByDate(sampleLog, []string{"2018-12-09"}, cookie.WithTopN(3))
+		assert.ErrorContains(t, err, "WithTopN is not supported by FindMostActiveCookiesByDate", "by date")
+	})
+}
+
+func TestFindMostActiveCookiesWithOptions_Location(t *testing.T) {
+	filename := writeLog(t, "cookie,timestamp\n"+
+		"Evening,2018-12-09T02:00:00+00:00\n"+
+		"Evening,2018-12-09T03:00:00+00:00\n"+
+		"Morning,2018-12-09T14:00:00+00:00\n")
+	newYork, err := time.LoadLocation("America/New_York")
+	require.NoError(t, err, "failed to load location")
+
+	tests := []struct {
+		name       string
+		opts       []cookie.Option
+		targetDate string
+		expected   []string
+	}{
+		{
+			name:       "UTC by default",
+			targetDate: "2018-12-09",
+			expected:   []string{"Evening"},
+		},
+		{
+			name:       "local day",
+			opts:       []cookie.Option{cookie.WithLocation(newYork)},
+			targetDate: "2018-12-09",
+			expected:   []string{"Morning"},
+		},
+		{
+			name:       "previous local day",
+			opts:       []cookie.Option{cookie.WithLocation(newYork)},
+			targetDate: "2018-12-08",
+			expected:   []string{"Evening"},
+		},
+		{
+			name:       "nil means UTC",
+			opts:       []cookie.Option{cookie.WithLocation(nil)},
+			targetDate: "2018-12-09",
+			expected:   []string{"Evening"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cookies, err := cookie.FindMostActiveCookiesWithOptions(filename, tt.targetDate, tt.opts...)
+
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, cookies, "result mismatch")
+		})
+	}
+}
+
+func TestFindMostActiveCookiesWithOptions_CaseInsensitive(t *testing.T) {
+	filename := writeLog(t, "cookie,timestamp\n"+
+		"CookieA,2018-12-09T10:00:00+00:00\n"+
+		"cookiea,2018-12-09T11:00:00+00:00\n"+
+		"CookieB,2018-12-09T12:00:00+00:00\n"+
+		"CookieB,2018-12-09T13:00:00+00:00\n"+
+		"COOKIEA,2018-12-09T14:00:00+00:00\n")
+
+	cookies, err := cookie.FindMostActiveCookiesWithOptions(filename, "2018-12-09")
+	assert.NoError(t, err, "unexpected error")
+	assert.Equal(t, []string{"CookieB"}, cookies, "case-sensitive by default")
+
+	cookies, err = cookie.FindMostActiveCookiesWithOptions(filename, "2018-12-09", cookie.WithCaseInsensitive())
+	assert.NoError(t, err, "unexpected error")
+	assert.Equal(t, []string{"cookiea"}, cookies, "case variants should count as one cookie")
+}
@@ -0,0 +1,133 @@
+package cookie
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Format names the registered Formatter that WriteMostActive, WriteCookies and
+// WriteCookieCounts render results with: one of the built-in formats below or
+// a name passed to RegisterFormatter.
+type Format string
+
+const (
+	// FormatText writes one cookie per line.
+	FormatText Format = "text"
+	// FormatNullDelimited separates cookies with NUL bytes, for xargs -0.
+	FormatNullDelimited Format = "nul"
+	// FormatJSON writes a single JSON array followed by a newline.
+	FormatJSON Format = "json"
+	// FormatCSV writes a "cookie,count" header, then one RFC 4180 row per
+	// cookie, quoting cookie IDs as needed.
+	FormatCSV Format = "csv"
+	// FormatTSV writes a "cookie\tcount" header, then one tab-separated row
+	// per cookie. Cookie IDs are written as is, so they must not contain tabs.
+	FormatTSV Format = "tsv"
+)
+
+// Formatter returns the formatter registered under f. Formats with a header
+// row implement HeaderedFormatter to leave it out.
+func (f Format) Formatter() (Formatter, error) {
+	formatter, ok := LookupFormatter(string(f))
+	if !ok {
+		return nil, fmt.Errorf("unknown output format %q", f)
+	}
+	return formatter, nil
+}
+
+// WriteMostActive finds the most active cookie(s) for targetDate with the
+// given options and writes them to w in the given format, with their count
+// for the formats that show it. Nothing is written when no cookie matches,
+// except the empty JSON array and the TSV or CSV header.
+func WriteMostActive(w io.Writer, filename, targetDate string, format Format, opts ...Option) error {
+	formatter, err := format.Formatter()
+	if err != nil {
+		return err
+	}
+
+	processor, o := newProcessor(opts)
+	var counts []CookieCount
+	if o.topN != 0 {
+		var ranked []RankedCookie
+		ranked, err = processor.FindRankedCookies(filename, targetDate, o.topN)
+		counts = make([]CookieCount, len(ranked))
+		for i, r := range ranked {
+			counts[i] = CookieCount{Cookie: r.Cookie, Count: r.Count}
+		}
+	} else {
+		counts, err = processor.FindMostActiveCookiesWithCounts(filename, targetDate)
+	}
+	if err != nil {
+		return err
+	}
+	return formatter.Format(w, counts)
+}
+
+// WriteCookies writes already computed results to w in the given format.
+// FormatJSON writes them as an array of strings. Formats that show counts,
+// such as TSV and CSV, need them; use WriteCookieCounts.
+func WriteCookies(w io.Writer, cookies []string, format Format) error {
+	formatter, err := format.Formatter()
+	if err != nil {
+		return err
+	}
+	plain, ok := formatter.(cookiesFormatter)
+	if !ok {
+		return fmt.Errorf("output format %q needs counts: use WriteCookieCounts", format)
+	}
+	return plain.formatCookies(w, cookies)
+}
+
+// WriteCookieCounts writes cookies with their counts to w with the formatter
+// registered under format. FormatJSON writes an array of cookie/count objects;
+// FormatText and FormatNullDelimited write only the cookies.
+func WriteCookieCounts(w io.Writer, counts []CookieCount, format Format) error {
+	formatter, err := format.Formatter()
+	if err != nil {
+		return err
+	}
+	return formatter.Format(w, counts)
+}
+
+// cookiesFormatter is a Formatter that can also write cookies without counts,
+// for WriteCookies.
+type cookiesFormatter interface {
+	Formatter
+	formatCookies(w io.Writer, cookies []string) error
+}
+
+// writeDelimited writes each cookie followed by separator.
+func writeDelimited(w io.Writer, cookies []string, separator byte) error {
+	buffered := bufio.NewWriter(w)
+	for _, c := range cookies {
+		buffered.WriteString(c)
+		buffered.WriteByte(separator)
+	}
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("failed to write results: %w", err)
+	}
+	return nil
+}
+
+func writeTSV(w io.Writer, counts []CookieCount, header bool) error {
+	buffered := bufio.NewWriter(w)
+	if header {
+		buffered.WriteString("cookie\tcount\n")
+	}
+	for _, c := range counts {
+		fmt.Fprintf(buffered, "%s\t%d\n", c.Cookie, c.Count)
+	}
+	if err := buffered.Flush(); err != nil {
+		return fmt.Errorf("failed to write results: %w", err)
+	}
+	return nil
+}
+
+func writeJSON(w io.Writer, v any) error {
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		return fmt.Errorf("failed to write results: %w", err)
+	}
+	return nil
+}
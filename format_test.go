@@ -0,0 +1,85 @@
+package cookie_test
+
+import (
+	"bytes"
+	"testing"
+
+	cookie "github.com/mfenderov/most-active-cookie"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteCookieCounts(t *testing.T) {
+	counts := []cookie.CookieCount{{Cookie: "CookieA", Count: 2}, {Cookie: "CookieB", Count: 2}}
+
+	tests := []struct {
+		name     string
+		counts   []cookie.CookieCount
+		format   cookie.Format
+		expected string
+	}{
+		{
+			name:     "json",
+			counts:   counts,
+			format:   cookie.FormatJSON,
+			expected: `[{"cookie":"CookieA","count":2},{"cookie":"CookieB","count":2}]` + "\n",
+		},
+		{
+			name:     "json without winners",
+			format:   cookie.FormatJSON,
+			expected: "[]\n",
+		},
+		{
+			name:     "tsv",
+			counts:   counts,
+			format:   cookie.FormatTSV,
+			expected: "cookie\tcount\nCookieA\t2\nCookieB\t2\n",
+		},
+		{
+			name:     "csv",
+			counts:   []cookie.CookieCount{{Cookie: "Cookie,A", Count: 2}},
+			format:   cookie.FormatCSV,
+			expected: "cookie,count\n\"Cookie,A\",2\n",
+		},
+		{
+			name:     "null-delimited writes only the cookies",
+			counts:   counts,
+			format:   cookie.FormatNullDelimited,
+			expected: "CookieA\x00CookieB\x00",
+		},
+		{
+			name:     "text writes only the cookies",
+			counts:   counts,
+			format:   cookie.FormatText,
+			expected: "CookieA\nCookieB\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			err := cookie.WriteCookieCounts(&out, tt.counts, tt.format)
+
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, out.String(), "output mismatch")
+		})
+	}
+}
+
+func TestWriteCookies_JSON(t *testing.T) {
+	var out bytes.Buffer
+
+	err := cookie.WriteCookies(&out, []string{"CookieA", "CookieB"}, cookie.FormatJSON)
+
+	assert.NoError(t, err, "unexpected error")
+	assert.Equal(t, `["CookieA","CookieB"]`+"\n", out.String(), "cookies should be a JSON array of strings")
+}
+
+func TestWriteCookies_Errors(t *testing.T) {
+	var out bytes.Buffer
+
+	assert.ErrorContains(t, cookie.WriteCookies(&out, []string{"CookieA"}, cookie.FormatTSV), "needs counts", "tsv should need counts")
+	assert.ErrorContains(t, cookie.WriteCookies(&out, []string{"CookieA"}, "yaml"), `unknown output format "yaml"`, "unregistered names should be rejected")
+	assert.Empty(t, out.String(), "nothing should be written on error")
+}
@@ -0,0 +1,159 @@
+package cookie
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"sync"
+)
+
+// Formatter renders winners with their counts, e.g. for one -format value of
+// the CLI. Implement it to add an output format of your own.
+type Formatter interface {
+	Format(w io.Writer, results []CookieCount) error
+}
+
+// HeaderedFormatter is a Formatter that writes a header row which can be left
+// out.
+type HeaderedFormatter interface {
+	Formatter
+	WithoutHeader() Formatter
+}
+
+// PlainFormatter writes one cookie per line, without counts.
+type PlainFormatter struct{}
+
+func (f PlainFormatter) Format(w io.Writer, results []CookieCount) error {
+	return f.formatCookies(w, cookiesOf(results))
+}
+
+func (PlainFormatter) formatCookies(w io.Writer, cookies []string) error {
+	return writeDelimited(w, cookies, '\n')
+}
+
+// NullFormatter separates cookies with NUL bytes, without counts, for
+// xargs -0.
+type NullFormatter struct{}
+
+func (f NullFormatter) Format(w io.Writer, results []CookieCount) error {
+	return f.formatCookies(w, cookiesOf(results))
+}
+
+func (NullFormatter) formatCookies(w io.Writer, cookies []string) error {
+	return writeDelimited(w, cookies, 0)
+}
+
+// JSONFormatter writes a JSON array of cookie/count objects, empty without
+// results.
+type JSONFormatter struct{}
+
+func (JSONFormatter) Format(w io.Writer, results []CookieCount) error {
+	if results == nil {
+		results = []CookieCount{}
+	}
+	return writeJSON(w, results)
+}
+
+// formatCookies writes a JSON array of strings.
+func (JSONFormatter) formatCookies(w io.Writer, cookies []string) error {
+	if cookies == nil {
+		cookies = []string{}
+	}
+	return writeJSON(w, cookies)
+}
+
+// cookiesOf drops the counts from results.
+func cookiesOf(results []CookieCount) []string {
+	cookies := make([]string, len(results))
+	for i, c := range results {
+		cookies[i] = c.Cookie
+	}
+	return cookies
+}
+
+// CSVFormatter writes a cookie,count header, unless NoHeader is set, and one
+// RFC 4180 row per cookie.
+type CSVFormatter struct {
+	NoHeader bool
+}
+
+func (f CSVFormatter) Format(w io.Writer, results []CookieCount) error {
+	writer := csv.NewWriter(w)
+	if !f.NoHeader {
+		writer.Write([]string{"cookie", "count"})
+	}
+	for _, c := range results {
+		writer.Write([]string{c.Cookie, strconv.Itoa(c.Count)})
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("failed to write results: %w", err)
+	}
+	return nil
+}
+
+func (f CSVFormatter) WithoutHeader() Formatter {
+	return CSVFormatter{NoHeader: true}
+}
+
+// TSVFormatter writes a cookie\tcount header, unless NoHeader is set, and one
+// tab-separated row per cookie.
+type TSVFormatter struct {
+	NoHeader bool
+}
+
+func (f TSVFormatter) Format(w io.Writer, results []CookieCount) error {
+	return writeTSV(w, results, !f.NoHeader)
+}
+
+func (f TSVFormatter) WithoutHeader() Formatter {
+	return TSVFormatter{NoHeader: true}
+}
+
+var (
+	formattersMu sync.RWMutex
+	formatters   = map[string]Formatter{
+		string(FormatText):          PlainFormatter{},
+		string(FormatNullDelimited): NullFormatter{},
+		string(FormatJSON):          JSONFormatter{},
+		string(FormatCSV):           CSVFormatter{},
+		string(FormatTSV):           TSVFormatter{},
+	}
+)
+
+// RegisterFormatter makes formatter available under name, such as a -format
+// value. It panics if name is already taken or formatter is nil.
+func RegisterFormatter(name string, formatter Formatter) {
+	formattersMu.Lock()
+	defer formattersMu.Unlock()
+	if formatter == nil {
+		panic("cookie: RegisterFormatter formatter is nil")
+	}
+	if _, dup := formatters[name]; dup {
+		panic("cookie: RegisterFormatter called twice for " + name)
+	}
+	formatters[name] = formatter
+}
+
+// LookupFormatter returns the formatter registered under name: text, nul,
+// json, csv, tsv or one added with RegisterFormatter.
+func LookupFormatter(name string) (Formatter, bool) {
+	formattersMu.RLock()
+	defer formattersMu.RUnlock()
+	formatter, ok := formatters[name]
+	return formatter, ok
+}
+
+// FormatterNames returns the names of the registered formatters, sorted.
+func FormatterNames() []string {
+	formattersMu.RLock()
+	defer formattersMu.RUnlock()
+	names := make([]string, 0, len(formatters))
+	for name := range formatters {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
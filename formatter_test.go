@@ -0,0 +1,107 @@
+package cookie_test
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	cookie "github.com/mfenderov/most-active-cookie"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatters(t *testing.T) {
+	results := []cookie.CookieCount{{Cookie: "CookieA", Count: 2}, {Cookie: "Cookie,B", Count: 2}}
+
+	tests := []struct {
+		name      string
+		formatter cookie.Formatter
+		expected  string
+	}{
+		{
+			name:      "plain",
+			formatter: cookie.PlainFormatter{},
+			expected:  "CookieA\nCookie,B\n",
+		},
+		{
+			name:      "null-delimited",
+			formatter: cookie.NullFormatter{},
+			expected:  "CookieA\x00Cookie,B\x00",
+		},
+		{
+			name:      "json",
+			formatter: cookie.JSONFormatter{},
+			expected:  `[{"cookie":"CookieA","count":2},{"cookie":"Cookie,B","count":2}]` + "\n",
+		},
+		{
+			name:      "csv quotes fields as needed",
+			formatter: cookie.CSVFormatter{},
+			expected:  "cookie,count\nCookieA,2\n\"Cookie,B\",2\n",
+		},
+		{
+			name:      "csv without header",
+			formatter: cookie.CSVFormatter{}.WithoutHeader(),
+			expected:  "CookieA,2\n\"Cookie,B\",2\n",
+		},
+		{
+			name:      "tsv",
+			formatter: cookie.TSVFormatter{},
+			expected:  "cookie\tcount\nCookieA\t2\nCookie,B\t2\n",
+		},
+		{
+			name:      "tsv without header",
+			formatter: cookie.TSVFormatter{NoHeader: true},
+			expected:  "CookieA\t2\nCookie,B\t2\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			err := tt.formatter.Format(&out, results)
+
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, out.String(), "output mismatch")
+		})
+	}
+}
+
+// markdownFormatter renders results as a Markdown list.
+type markdownFormatter struct{}
+
+func (markdownFormatter) Format(w io.Writer, results []cookie.CookieCount) error {
+	for _, c := range results {
+		if _, err := fmt.Fprintf(w, "- %s (%d)\n", c.Cookie, c.Count); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestRegisterFormatter(t *testing.T) {
+	for _, name := range []string{"text", "nul", "json", "csv", "tsv"} {
+		_, ok := cookie.LookupFormatter(name)
+		assert.True(t, ok, "%s should be built in", name)
+	}
+
+	// The registry is global, so register only once when run with -count.
+	if _, ok := cookie.LookupFormatter("markdown"); !ok {
+		cookie.RegisterFormatter("markdown", markdownFormatter{})
+	}
+
+	formatter, ok := cookie.LookupFormatter("markdown")
+	require.True(t, ok, "a registered formatter should be found")
+	var out bytes.Buffer
+	require.NoError(t, formatter.Format(&out, []cookie.CookieCount{{Cookie: "CookieA", Count: 2}}), "unexpected error")
+	assert.Equal(t, "- CookieA (2)\n", out.String(), "the registered formatter should be used")
+	assert.Equal(t, []string{"csv", "json", "markdown", "nul", "text", "tsv"}, cookie.FormatterNames(), "names mismatch")
+
+	assert.Panics(t, func() { cookie.RegisterFormatter("json", markdownFormatter{}) }, "a name should only be registered once")
+	assert.Panics(t, func() { cookie.RegisterFormatter("nil", nil) }, "a nil formatter should be rejected")
+
+	_, ok = cookie.LookupFormatter("yaml")
+	assert.False(t, ok, "unknown names should not be found")
+}
@@ -0,0 +1,504 @@
+package integration_test
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	cliBinary   string
+	cliBuildErr error
+	cliBuild    sync.Once
+)
+
+func TestMain(m *testing.M) {
+	code := m.Run()
+	if cliBinary != "" {
+		os.RemoveAll(filepath.Dir(cliBinary))
+	}
+	os.Exit(code)
+}
+
+// buildCLI compiles the most-active-cookie binary once per test run.
+func buildCLI(t *testing.T) string {
+	t.Helper()
+
+	cliBuild.Do(func() {
+		dir, err := os.MkdirTemp("", "most-active-cookie-e2e-*")
+		if err != nil {
+			cliBuildErr = err
+			return
+		}
+		cliBinary = filepath.Join(dir, "most-active-cookie")
+		if runtime.GOOS == "windows" {
+			cliBinary += ".exe"
+		}
+
+		cmd := exec.Command("go", "build", "-o", cliBinary, "../cmd/most-active-cookie")
+		if output, err := cmd.CombinedOutput(); err != nil {
+			cliBuildErr = errors.New(string(output))
+		}
+	})
+
+	require.NoError(t, cliBuildErr, "failed to build CLI binary")
+	return cliBinary
+}
+
+// runCLI runs the compiled binary with args and returns its stdout, stderr and
+// exit code.
+func runCLI(t *testing.T, args ...string) (string, string, int) {
+	t.Helper()
+	return runCLIWithStdin(t, "", args...)
+}
+
+// runCLIWithStdin is runCLI with stdin fed to the binary.
+func runCLIWithStdin(t *testing.T, stdin string, args ...string) (string, string, int) {
+	t.Helper()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(buildCLI(t), args...)
+	cmd.Stdin = strings.NewReader(stdin)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	err := cmd.Run()
+	exitCode := 0
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	} else {
+		require.NoError(t, err, "failed to run CLI binary")
+	}
+
+	return stdout.String(), stderr.String(), exitCode
+}
+
+// TestCLIEndToEnd exercises flag parsing, exit codes and the stdout/stderr split
+// of the compiled binary.
+func TestCLIEndToEnd(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end CLI test in short mode")
+	}
+
+	tests := []struct {
+		name             string
+		args             []string
+		stdin            string
+		expectedStdout   string
+		expectedExitCode int
+		stderrContains   string
+	}{
+		{
+			name:             "valid invocation",
+			args:             []string{"-f", "./test-data/sample_cookie_log.csv", "-d", "2018-12-09"},
+			expectedStdout:   "AtY0laUfhglK3lC7\n",
+			expectedExitCode: 0,
+		},
+		{
+			name:             "tied cookies",
+			args:             []string{"-f", "./test-data/tied_cookies.csv", "-d", "2018-12-09"},
+			expectedStdout:   "CookieA\nCookieB\n",
+			expectedExitCode: 0,
+		},
+		{
+			name:             "winner-only with a unique winner",
+			args:             []string{"-f", "./test-data/sample_cookie_log.csv", "-d", "2018-12-09", "-winner-only"},
+			expectedStdout:   "AtY0laUfhglK3lC7\n",
+			expectedExitCode: 0,
+		},
+		{
+			name:             "winner-only rejects ties",
+			args:             []string{"-f", "./test-data/tied_cookies.csv", "-d", "2018-12-09", "-winner-only"},
+			expectedStdout:   "",
+			expectedExitCode: 1,
+			stderrContains:   "no unique winner: 2 cookies tied: CookieA, CookieB",
+		},
+		{
+			name:             "log read from stdin",
+			args:             []string{"-f", "-", "-d", "2018-12-09"},
+			stdin:            "cookie,timestamp\nAtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00\nSAZuXPGUrfbcn5UA,2018-12-09T10:13:00+00:00\nAtY0laUfhglK3lC7,2018-12-09T06:19:00+00:00\n",
+			expectedStdout:   "AtY0laUfhglK3lC7\n",
+			expectedExitCode: 0,
+		},
+		{
+			name:             "custom timestamp layout",
+			args:             []string{"-f", "-", "-d", "2018-12-09", "-time-layout", "2006-01-02 15:04:05"},
+			stdin:            "cookie,timestamp\nSAZuXPGUrfbcn5UA,2018-12-08 23:59:00\nAtY0laUfhglK3lC7,2018-12-09 14:19:00\nSAZuXPGUrfbcn5UA,2018-12-09 10:13:00\nAtY0laUfhglK3lC7,2018-12-09 06:19:00\n",
+			expectedStdout:   "AtY0laUfhglK3lC7\n",
+			expectedExitCode: 0,
+		},
+		{
+			name:             "explain empty result on stdin",
+			args:             []string{"-f", "-", "-d", "2020-01-01", "-explain-empty"},
+			stdin:            "cookie,timestamp\nAtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00\n",
+			expectedStdout:   "",
+			expectedExitCode: 0,
+			stderrContains:   "no entries on stdin fall on 2020-01-01",
+		},
+		{
+			name:             "json output with counts",
+			args:             []string{"-f", "./test-data/tied_cookies.csv", "-d", "2018-12-09", "-format", "json"},
+			expectedStdout:   `[{"cookie":"CookieA","count":2},{"cookie":"CookieB","count":2}]` + "\n",
+			expectedExitCode: 0,
+		},
+		{
+			name:             "json output in first-seen order",
+			args:             []string{"-f", "-", "-d", "2018-12-09", "-format", "json", "-sort", "first-seen"},
+			stdin:            "cookie,timestamp\nCookieB,2018-12-09T10:00:00+00:00\nCookieA,2018-12-09T11:00:00+00:00\nCookieA,2018-12-09T12:00:00+00:00\nCookieB,2018-12-09T13:00:00+00:00\n",
+			expectedStdout:   `[{"cookie":"CookieB","count":2},{"cookie":"CookieA","count":2}]` + "\n",
+			expectedExitCode: 0,
+		},
+		{
+			name:             "summary footer on stderr",
+			args:             []string{"-f", "./test-data/sample_cookie_log.csv", "-d", "2018-12-09", "-format", "json", "-summary"},
+			expectedStdout:   `[{"cookie":"AtY0laUfhglK3lC7","count":2}]` + "\n",
+			expectedExitCode: 0,
+			stderrContains:   "# scanned 9 lines, 3 distinct cookies, winner had 2 hits",
+		},
+		{
+			name:             "tsv output",
+			args:             []string{"-f", "./test-data/tied_cookies.csv", "-d", "2018-12-09", "-format", "tsv"},
+			expectedStdout:   "cookie\tcount\nCookieA\t2\nCookieB\t2\n",
+			expectedExitCode: 0,
+		},
+		{
+			name:             "csv output",
+			args:             []string{"-f", "./test-data/tied_cookies.csv", "-d", "2018-12-09", "-format", "csv"},
+			expectedStdout:   "cookie,count\nCookieA,2\nCookieB,2\n",
+			expectedExitCode: 0,
+		},
+		{
+			name:             "csv output without header",
+			args:             []string{"-f", "./test-data/tied_cookies.csv", "-d", "2018-12-09", "-format", "csv", "-no-header"},
+			expectedStdout:   "CookieA,2\nCookieB,2\n",
+			expectedExitCode: 0,
+		},
+		{
+			name:             "tsv output without header",
+			args:             []string{"-f", "./test-data/tied_cookies.csv", "-d", "2018-12-09", "-format", "tsv", "-no-header"},
+			expectedStdout:   "CookieA\t2\nCookieB\t2\n",
+			expectedExitCode: 0,
+		},
+		{
+			name:             "ranked top cookies share ranks on ties",
+			args:             []string{"-f", "./test-data/sample_cookie_log.csv", "-d", "2018-12-09", "-top", "3", "-ranked"},
+			expectedStdout:   "1. AtY0laUfhglK3lC7 (2)\n2. 5UAVanZf6UtGyKVS (1)\n2. SAZuXPGUrfbcn5UA (1)\n",
+			expectedExitCode: 0,
+		},
+		{
+			name:             "top without ranks",
+			args:             []string{"-f", "./test-data/sample_cookie_log.csv", "-d", "2018-12-09", "-top", "2"},
+			expectedStdout:   "AtY0laUfhglK3lC7\n5UAVanZf6UtGyKVS\n",
+			expectedExitCode: 0,
+		},
+		{
+			name:             "json output without matches",
+			args:             []string{"-f", "./test-data/sample_cookie_log.csv", "-d", "2020-01-01", "-format", "json"},
+			expectedStdout:   "[]\n",
+			expectedExitCode: 0,
+		},
+		{
+			name:             "several dates in one pass",
+			args:             []string{"-f", "./test-data/sample_cookie_log.csv", "-d", "2018-12-09", "-d", "2018-12-08", "-d", "2020-01-01"},
+			expectedStdout:   "2018-12-09:\nAtY0laUfhglK3lC7\n2018-12-08:\n4sMM2LxV07bPJzwf\nSAZuXPGUrfbcn5UA\nfbcn5UAVanZf6UtG\n2020-01-01:\n",
+			expectedExitCode: 0,
+		},
+		{
+			name: "several dates as a table, newest first",
+			args: []string{"-f", "./test-data/sample_cookie_log.csv", "-d", "2018-12-08", "-d", "2018-12-09", "-d", "2020-01-01", "-format", "table"},
+			expectedStdout: "date        winners                                               count\n" +
+				"2020-01-01  -                                                     0\n" +
+				"2018-12-09  AtY0laUfhglK3lC7                                      2\n" +
+				"2018-12-08  4sMM2LxV07bPJzwf, SAZuXPGUrfbcn5UA, fbcn5UAVanZf6UtG  1\n",
+			expectedExitCode: 0,
+		},
+		{
+			name: "compare two dates",
+			args: []string{"-f", "./test-data/sample_cookie_log.csv", "-d", "2018-12-09", "-compare", "2018-12-08"},
+			expectedStdout: "2018-12-09: AtY0laUfhglK3lC7 (2)\n" +
+				"2018-12-08: 4sMM2LxV07bPJzwf, SAZuXPGUrfbcn5UA, fbcn5UAVanZf6UtG (1)\n" +
+				"rose 4sMM2LxV07bPJzwf: 0 -> 1 (+1)\n" +
+				"rose fbcn5UAVanZf6UtG: 0 -> 1 (+1)\n" +
+				"fell 5UAVanZf6UtGyKVS: 1 -> 0 (-1)\n" +
+				"fell AtY0laUfhglK3lC7: 2 -> 0 (-2)\n",
+			expectedExitCode: 0,
+		},
+		{
+			name:             "several dates in first-seen order",
+			args:             []string{"-f", "-", "-d", "2018-12-09", "-d", "2018-12-08", "-sort", "first-seen"},
+			stdin:            "cookie,timestamp\nCookieB,2018-12-09T10:00:00+00:00\nCookieA,2018-12-09T11:00:00+00:00\nCookieC,2018-12-08T13:00:00+00:00\n",
+			expectedStdout:   "2018-12-09:\nCookieB\nCookieA\n2018-12-08:\nCookieC\n",
+			expectedExitCode: 0,
+		},
+		{
+			name:             "several files combined",
+			args:             []string{"-f", "./test-data/tied_cookies.csv", "-f", "./test-data/sample_cookie_log.csv", "-d", "2018-12-09"},
+			expectedStdout:   "AtY0laUfhglK3lC7\nCookieA\nCookieB\n",
+			expectedExitCode: 0,
+		},
+		{
+			name:             "glob without matches",
+			args:             []string{"-f", "./test-data/missing-*.csv", "-d", "2018-12-09"},
+			expectedStdout:   "",
+			expectedExitCode: 1,
+			stderrContains:   "no files match -f pattern",
+		},
+		{
+			name:             "no matching date",
+			args:             []string{"-f", "./test-data/sample_cookie_log.csv", "-d", "2020-01-01"},
+			expectedStdout:   "",
+			expectedExitCode: 0,
+		},
+		{
+			name:             "explain empty result outside the file's range",
+			args:             []string{"-f", "./test-data/sample_cookie_log.csv", "-d", "2020-01-01", "-explain-empty"},
+			expectedStdout:   "",
+			expectedExitCode: 0,
+			stderrContains:   "your date 2020-01-01 is outside the file's range 2018-12-07..2018-12-09",
+		},
+		{
+			name:             "line limit exceeded",
+			args:             []string{"-f", "./test-data/sample_cookie_log.csv", "-d", "2018-12-09", "-max-lines", "3"},
+			expectedStdout:   "",
+			expectedExitCode: 1,
+			stderrContains:   "exceeded maximum of 3 lines",
+		},
+		{
+			name:             "quiet mode suppresses logs but keeps results",
+			args:             []string{"-f", "./test-data/sample_cookie_log.csv", "-d", "2018-12-09", "-q"},
+			expectedStdout:   "AtY0laUfhglK3lC7\n",
+			expectedExitCode: 0,
+		},
+		{
+			name:             "quiet mode still reports fatal errors",
+			args:             []string{"-f", "./test-data/sample_cookie_log.csv", "-d", "2018-12-09", "-q", "-max-lines", "3"},
+			expectedStdout:   "",
+			expectedExitCode: 1,
+			stderrContains:   "exceeded maximum of 3 lines",
+		},
+		{
+			name:             "sort check on sorted file",
+			args:             []string{"-f", "./test-data/sample_cookie_log.csv", "-sort-check"},
+			expectedStdout:   "./test-data/sample_cookie_log.csv is sorted by date\n",
+			expectedExitCode: 0,
+		},
+		{
+			name:             "schema subcommand",
+			args:             []string{"schema", "-f", "./test-data/sample_cookie_log.csv", "-rows", "2"},
+			expectedStdout:   "delimiter: ','\nbom: false\nheader accepted: true\ncolumns: cookie (cookie), timestamp (timestamp)\ntimestamp layout: RFC3339\nsampled rows: 2\n",
+			expectedExitCode: 0,
+		},
+		{
+			name:             "missing flags",
+			args:             []string{},
+			expectedStdout:   "",
+			expectedExitCode: 1,
+			stderrContains:   "a filename is required",
+		},
+		{
+			name:             "invalid date",
+			args:             []string{"-f", "./test-data/sample_cookie_log.csv", "-d", "12/09/2018"},
+			expectedStdout:   "",
+			expectedExitCode: 1,
+			stderrContains:   "invalid target date",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stdout, stderr, exitCode := runCLIWithStdin(t, tt.stdin, tt.args...)
+
+			assert.Equal(t, tt.expectedExitCode, exitCode, "exit code mismatch (stderr: %s)", stderr)
+			assert.Equal(t, tt.expectedStdout, stdout, "stdout mismatch")
+			if tt.stderrContains != "" {
+				assert.Contains(t, stderr, tt.stderrContains, "stderr should explain the failure")
+			}
+		})
+	}
+}
+
+// TestCLIOutputFile checks that -o moves the results, and only the results, to a file.
+func TestCLIOutputFile(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end CLI test in short mode")
+	}
+
+	output := filepath.Join(t.TempDir(), "results.txt")
+	require.NoError(t, os.WriteFile(output, []byte("stale results\n"), 0o600), "failed to seed output file")
+
+	stdout, stderr, exitCode := runCLI(t, "-f", "./test-data/tied_cookies.csv", "-d", "2018-12-09", "-o", output, "-v")
+
+	assert.Equal(t, 0, exitCode, "exit code mismatch (stderr: %s)", stderr)
+	assert.Empty(t, stdout, "results should not go to stdout")
+	assert.Contains(t, stderr, "cookie processing completed", "logs should stay on stderr")
+	written, err := os.ReadFile(output)
+	require.NoError(t, err, "failed to read output file")
+	assert.Equal(t, "CookieA\nCookieB\n", string(written), "output file should be truncated and hold the results")
+
+	t.Run("sort check", func(t *testing.T) {
+		stdout, stderr, exitCode := runCLI(t, "-f", "./test-data/sample_cookie_log.csv", "-sort-check", "-o", output)
+
+		assert.Equal(t, 0, exitCode, "exit code mismatch (stderr: %s)", stderr)
+		assert.Empty(t, stdout, "the confirmation should not go to stdout")
+		written, err := os.ReadFile(output)
+		require.NoError(t, err, "failed to read output file")
+		assert.Equal(t, "./test-data/sample_cookie_log.csv is sorted by date\n", string(written), "output file should hold the confirmation")
+	})
+}
+
+// TestCLIProfilesOnFailure checks that a failing run still writes the
+// requested profiles.
+func TestCLIProfilesOnFailure(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end CLI test in short mode")
+	}
+
+	dir := t.TempDir()
+	cpuProfile := filepath.Join(dir, "cpu.prof")
+	memProfile := filepath.Join(dir, "mem.prof")
+
+	_, stderr, exitCode := runCLI(t, "-f", "./test-data/sample_cookie_log.csv", "-d", "2018-12-09", "-max-lines", "3",
+		"-cpuprofile", cpuProfile, "-memprofile", memProfile)
+
+	assert.Equal(t, 1, exitCode, "exit code mismatch (stderr: %s)", stderr)
+	assert.FileExists(t, cpuProfile, "the CPU profile should be written")
+	assert.FileExists(t, memProfile, "the heap profile should be written")
+}
+
+// TestCLIDirectoryInput combines the CSV files of a directory given to -f.
+func TestCLIDirectoryInput(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end CLI test in short mode")
+	}
+
+	dir := t.TempDir()
+	shards := map[string]string{
+		"part-1.csv":       "cookie,timestamp\nCookieA,2018-12-09T10:00:00+00:00\nCookieB,2018-12-09T11:00:00+00:00\n",
+		"README.txt":       "not a log\n",
+		"older/part-2.csv": "cookie,timestamp\nCookieB,2018-12-09T12:00:00+00:00\n",
+		"older/part-3.csv": "cookie,timestamp\nCookieB,2018-12-09T13:00:00+00:00\n",
+		"part-4.csv":       "cookie,timestamp\nCookieA,2018-12-09T14:00:00+00:00\nCookieA,2018-12-10T09:00:00+00:00\n",
+	}
+	for name, content := range shards {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o750), "failed to create directory")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600), "failed to write shard")
+	}
+
+	t.Run("top level", func(t *testing.T) {
+		stdout, stderr, exitCode := runCLI(t, "-f", dir, "-d", "2018-12-09")
+
+		assert.Equal(t, 0, exitCode, "exit code mismatch (stderr: %s)", stderr)
+		assert.Equal(t, "CookieA\n", stdout, "only top-level CSV files should be counted")
+	})
+
+	t.Run("recursive", func(t *testing.T) {
+		stdout, stderr, exitCode := runCLI(t, "-f", dir, "-r", "-d", "2018-12-09")
+
+		assert.Equal(t, 0, exitCode, "exit code mismatch (stderr: %s)", stderr)
+		assert.Equal(t, "CookieB\n", stdout, "subdirectories should be counted with -r")
+	})
+}
+
+// TestCLIUnsortedInput reads a file whose dates go back and forth, which is
+// only counted in full without -assume-sorted.
+func TestCLIUnsortedInput(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end CLI test in short mode")
+	}
+
+	filename := filepath.Join(t.TempDir(), "unsorted.csv")
+	content := "cookie,timestamp\n" +
+		"CookieA,2018-12-09T10:00:00+00:00\n" +
+		"CookieB,2018-12-10T09:00:00+00:00\n" +
+		"CookieB,2018-12-09T11:00:00+00:00\n" +
+		"CookieB,2018-12-09T12:00:00+00:00\n"
+	require.NoError(t, os.WriteFile(filename, []byte(content), 0o600), "failed to write log")
+
+	t.Run("whole file by default", func(t *testing.T) {
+		stdout, stderr, exitCode := runCLI(t, "-f", filename, "-d", "2018-12-09")
+
+		assert.Equal(t, 0, exitCode, "exit code mismatch (stderr: %s)", stderr)
+		assert.Equal(t, "CookieB\n", stdout, "entries after a later date should be counted")
+	})
+
+	t.Run("assume sorted", func(t *testing.T) {
+		stdout, stderr, exitCode := runCLI(t, "-f", filename, "-d", "2018-12-09", "-assume-sorted")
+
+		assert.Equal(t, 0, exitCode, "exit code mismatch (stderr: %s)", stderr)
+		assert.Equal(t, "CookieA\n", stdout, "reading should stop at the first entry past the date")
+	})
+}
+
+// TestCLITopPerHourOptions checks that -top-per-hour reads the log with the
+// same options as the plain query.
+func TestCLITopPerHourOptions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end CLI test in short mode")
+	}
+
+	t.Run("line limit", func(t *testing.T) {
+		stdout, stderr, exitCode := runCLI(t, "-f", "./test-data/sample_cookie_log.csv", "-d", "2018-12-09", "-top-per-hour", "-max-lines", "3")
+
+		assert.Equal(t, 1, exitCode, "exit code mismatch (stdout: %s)", stdout)
+		assert.Contains(t, stderr, "exceeded maximum of 3 lines", "the line limit should apply")
+	})
+
+	t.Run("timestamp layout", func(t *testing.T) {
+		stdin := "cookie,timestamp\nAtY0laUfhglK3lC7,2018-12-09 06:19:00\nSAZuXPGUrfbcn5UA,2018-12-09 10:13:00\n"
+		stdout, stderr, exitCode := runCLIWithStdin(t, stdin, "-f", "-", "-d", "2018-12-09", "-top-per-hour", "-time-layout", "2006-01-02 15:04:05")
+
+		assert.Equal(t, 0, exitCode, "exit code mismatch (stderr: %s)", stderr)
+		assert.Contains(t, stdout, "06:00 AtY0laUfhglK3lC7 1\n", "hour 6 should hold its cookie")
+		assert.Contains(t, stdout, "10:00 SAZuXPGUrfbcn5UA 1\n", "hour 10 should hold its cookie")
+	})
+}
+
+// TestCLIManifest runs a batch of jobs, including a failing one, through -manifest.
+func TestCLIManifest(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping end-to-end CLI test in short mode")
+	}
+
+	manifest := filepath.Join(t.TempDir(), "jobs.csv")
+	jobs := "file,date\n" +
+		"./test-data/sample_cookie_log.csv,2018-12-09\n" +
+		"./test-data/missing.csv,2018-12-09\n" +
+		"./test-data/tied_cookies.csv,2018-12-09\n" +
+		"./test-data/sample_cookie_log.csv,2020-01-01\n"
+	require.NoError(t, os.WriteFile(manifest, []byte(jobs), 0o600), "failed to write manifest")
+
+	t.Run("continues past failures", func(t *testing.T) {
+		stdout, stderr, exitCode := runCLI(t, "-manifest", manifest)
+
+		assert.Equal(t, 1, exitCode, "a failed job should fail the run")
+		lines := strings.Split(stdout, "\n")
+		require.Len(t, lines, 6, "one row per winner, failure and empty job expected")
+		assert.Equal(t, "./test-data/sample_cookie_log.csv,2018-12-09,AtY0laUfhglK3lC7,", lines[0], "first job mismatch")
+		assert.True(t, strings.HasPrefix(lines[1], "./test-data/missing.csv,2018-12-09,,"), "the failed job should have a row")
+		assert.Contains(t, lines[1], "missing.csv", "the failed job's row should carry its error")
+		assert.Equal(t, "./test-data/tied_cookies.csv,2018-12-09,CookieA,\n"+
+			"./test-data/tied_cookies.csv,2018-12-09,CookieB,\n"+
+			"./test-data/sample_cookie_log.csv,2020-01-01,,\n", strings.Join(lines[2:], "\n"), "later jobs mismatch")
+		assert.Contains(t, stderr, "./test-data/missing.csv,2018-12-09:", "stderr should name the failed job")
+	})
+
+	t.Run("fail fast", func(t *testing.T) {
+		stdout, _, exitCode := runCLI(t, "-manifest", manifest, "-fail-fast")
+
+		assert.Equal(t, 1, exitCode, "a failed job should fail the run")
+		assert.Equal(t, 2, strings.Count(stdout, "\n"), "jobs after the failure should not run")
+		assert.True(t, strings.HasPrefix(stdout, "./test-data/sample_cookie_log.csv,2018-12-09,AtY0laUfhglK3lC7,\n./test-data/missing.csv,2018-12-09,,"),
+			"the failure should be the last row")
+	})
+}
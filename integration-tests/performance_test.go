@@ -138,6 +138,7 @@ func BenchmarkStreamingThroughput(b *testing.B) {
 	csvParser := parser.NewCSVParser()
 	processor := cookie.NewProcessor(csvParser)
 
+	b.ReportAllocs()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		_, err := processor.FindMostActiveCookies(filename, targetDate)
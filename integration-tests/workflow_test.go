@@ -1,8 +1,11 @@
 package integration_test
 
 import (
+	"bytes"
+	"os"
 	"testing"
 
+	mostactive "github.com/mfenderov/most-active-cookie"
 	"github.com/mfenderov/most-active-cookie/src/cookie"
 	"github.com/mfenderov/most-active-cookie/src/parser"
 
@@ -35,6 +38,12 @@ func TestWorkflowScenarios(t *testing.T) {
 			targetDate: "2020-01-01",
 			expected:   []string{},
 		},
+		{
+			name:       "all data after target date workflow",
+			filename:   "./test-data/sample_cookie_log.csv",
+			targetDate: "2018-12-01",
+			expected:   []string{},
+		},
 		{
 			name:       "multiple top cookies workflow",
 			filename:   "./test-data/tied_cookies.csv",
@@ -73,3 +82,84 @@ func TestErrorHandlingWorkflow(t *testing.T) {
 		assert.Error(t, err, "Should return error for invalid date format")
 	})
 }
+
+// TestFindMostActiveWorkflow tests the found flag separating empty results from winners
+func TestFindMostActiveWorkflow(t *testing.T) {
+	t.Run("Found", func(t *testing.T) {
+		found, winners, err := mostactive.FindMostActive("./test-data/tied_cookies.csv", "2018-12-09")
+		assert.NoError(t, err, "Processing should succeed")
+		assert.True(t, found, "Winners should be reported as found")
+		assert.Equal(t, []string{"CookieA", "CookieB"}, winners, "Winners should match expected values")
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		found, winners, err := mostactive.FindMostActive("./test-data/sample_cookie_log.csv", "2020-01-01")
+		assert.NoError(t, err, "Processing should succeed")
+		assert.False(t, found, "A date without entries should not be reported as found")
+		assert.Empty(t, winners, "No winners should be returned")
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		found, winners, err := mostactive.FindMostActive("nonexistent.csv", "2018-12-09")
+		assert.Error(t, err, "Should return error for non-existent file")
+		assert.False(t, found, "Errors should not be reported as found")
+		assert.Nil(t, winners, "Errors should not return winners")
+	})
+}
+
+// TestFindMostActiveCookiesFromReaderWorkflow tests analyzing a log held in memory
+func TestFindMostActiveCookiesFromReaderWorkflow(t *testing.T) {
+	data, err := os.ReadFile("./test-data/tied_cookies.csv")
+	assert.NoError(t, err, "Test data should be readable")
+
+	cookies, err := mostactive.FindMostActiveCookiesFromReader(bytes.NewReader(data), "2018-12-09")
+	assert.NoError(t, err, "Processing should succeed")
+	assert.Equal(t, []string{"CookieA", "CookieB"}, cookies, "Results should match the file-based API")
+}
+
+// TestWriteMostActiveWorkflow tests the library writing formatted results directly
+func TestWriteMostActiveWorkflow(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   mostactive.Format
+		opts     []mostactive.Option
+		expected string
+	}{
+		{
+			name:     "text format",
+			format:   mostactive.FormatText,
+			expected: "CookieA\nCookieB\n",
+		},
+		{
+			name:     "null-delimited format",
+			format:   mostactive.FormatNullDelimited,
+			expected: "CookieA\x00CookieB\x00",
+		},
+		{
+			name:     "json format",
+			format:   mostactive.FormatJSON,
+			expected: `[{"cookie":"CookieA","count":2},{"cookie":"CookieB","count":2}]` + "\n",
+		},
+		{
+			name:     "csv format",
+			format:   mostactive.FormatCSV,
+			expected: "cookie,count\nCookieA,2\nCookieB,2\n",
+		},
+		{
+			name:     "options",
+			format:   mostactive.FormatText,
+			opts:     []mostactive.Option{mostactive.WithTopN(1)},
+			expected: "CookieA\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			err := mostactive.WriteMostActive(&out, "./test-data/tied_cookies.csv", "2018-12-09", tt.format, tt.opts...)
+
+			assert.NoError(t, err, "Writing results should succeed")
+			assert.Equal(t, tt.expected, out.String(), "Written output should match the format")
+		})
+	}
+}
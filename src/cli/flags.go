@@ -4,24 +4,123 @@ import (
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	cookie "github.com/mfenderov/most-active-cookie"
+	"github.com/mfenderov/most-active-cookie/src/parser"
 )
 
 type Config struct {
-	Filename   string
-	TargetDate string
-	Verbosity  int // 0=WARN, 1=INFO, 2=DEBUG
+	Filename     string   // the first input file
+	Filenames    []string // every -f, with glob patterns expanded
+	Recursive    bool     // read directory inputs' subdirectories too
+	TargetDate   string   // the first -d
+	TargetDates  []string // every -d, in the order given
+	Verbosity    int      // 0=WARN, 1=INFO, 2=DEBUG
+	Quiet        bool
+	CPUProfile   string
+	MemProfile   string
+	NoColor      bool
+	TopPerHour   bool
+	Print0       bool
+	MaxLines     int
+	AssumeTZ     *time.Location // nil unless timestamps lack offsets
+	AssumeSorted bool           // stop reading at the first entry past the target date
+	TimeLayout   string         // Go layout of the timestamps; empty means RFC3339
+	Sort         string         // "alpha" or "first-seen"
+	ExplainEmpty bool
+	State        string // accumulate counts across runs in this JSON file
+	SortCheck    bool
+	Manifest     string // file,date jobs to run instead of -f/-d
+	FailFast     bool
+	Sink         string // "stdout" or "syslog"
+	WinnerOnly   bool
+	Format       string // a registered formatter name, such as "text" or "json", or "table"
+	NoHeader     bool   // omit the -format csv or tsv header row
+	Output       string // write results to this file instead of stdout
+	Summary      bool   // print a "# scanned ..." footer to stderr
+	Top          int    // print the N most active cookies; 0 prints the winners
+	Ranked       bool   // print -top as "rank. cookie (count)" lines
+	Compare      string // compare the -d date with this date
+}
+
+const (
+	SortAlphabetical = "alpha"
+	SortFirstSeen    = "first-seen"
+)
+
+const (
+	SinkStdout = "stdout"
+	SinkSyslog = "syslog"
+)
+
+// The -format values are the names of the registered formatters, such as
+// text, json, csv or tsv, plus table, which only the CLI's multi-date output
+// renders.
+const (
+	FormatText  = string(cookie.FormatText)
+	FormatTable = "table"
+)
+
+// formats returns the valid -format values.
+func formats() []string {
+	return append(cookie.FormatterNames(), FormatTable)
+}
+
+// SchemaCommand is the subcommand that reports a file's detected layout
+// instead of running the analysis.
+const SchemaCommand = "schema"
+
+// SchemaConfig holds the options of the schema subcommand.
+type SchemaConfig struct {
+	Filename string
+	Rows     int // data rows sampled for timestamp detection
 }
 
 func ParseFlags() (*Config, error) {
 	var config Config
 
-	flag.StringVar(&config.Filename, "f", "", "Cookie log file to process (required)")
-	flag.StringVar(&config.TargetDate, "d", "", "Target date in YYYY-MM-DD format (required)")
+	var filenames stringList
+	flag.Var(&filenames, "f", "Cookie log file, directory of .csv files, glob pattern, HTTP(S) URL or - for stdin to process (required; repeat to combine files)")
+	flag.BoolVar(&config.Recursive, "r", false, "With a directory -f, also read the .csv files in its subdirectories")
+	var targetDates stringList
+	flag.Var(&targetDates, "d", "Target date in YYYY-MM-DD format (required; repeat for several dates in one pass)")
 
 	var verbose bool
 	var veryVerbose bool
 	flag.BoolVar(&verbose, "v", false, "Verbose output (INFO level)")
 	flag.BoolVar(&veryVerbose, "vv", false, "Very verbose output (DEBUG level)")
+	flag.BoolVar(&config.Quiet, "q", false, "Quiet mode: suppress all log output, including warnings")
+
+	flag.StringVar(&config.Manifest, "manifest", "", "Run every file,date job listed in this CSV and print file,date,winner,error rows (replaces -f and -d)")
+	flag.BoolVar(&config.FailFast, "fail-fast", false, "With -manifest, stop at the first failing job")
+	flag.BoolVar(&config.SortCheck, "sort-check", false, "Only check that the file is sorted by date, as -assume-sorted requires (-d not needed)")
+	flag.BoolVar(&config.TopPerHour, "top-per-hour", false, "Report the most active cookie for each hour of the target date")
+	var assumeTZ string
+	flag.StringVar(&assumeTZ, "assume-tz", "", "Treat offset-less timestamps as local time in this IANA zone (e.g. Europe/Berlin)")
+	flag.BoolVar(&config.AssumeSorted, "assume-sorted", false, "Stop reading at the first entry past the target date; only correct for files sorted by date (see -sort-check)")
+	flag.StringVar(&config.TimeLayout, "time-layout", "", "Go time layout of the timestamps, e.g. \"2006-01-02 15:04:05\" (default RFC3339)")
+	flag.BoolVar(&config.WinnerOnly, "winner-only", false, "Print exactly one winner; a tie is an error listing the tied cookies")
+	flag.StringVar(&config.Sort, "sort", SortAlphabetical, "Order of tied winners: alpha or first-seen (file order)")
+	flag.StringVar(&config.State, "state", "", "Accumulate per-date counts across runs in this JSON file; each file is counted once")
+	flag.IntVar(&config.MaxLines, "max-lines", 0, "Abort if the input has more than this many lines (0 = unlimited)")
+	flag.StringVar(&config.Format, "format", FormatText, "Output format: text (one cookie per line), nul (NUL-separated, like -print0), json, csv or tsv (cookies with their counts), or table (one row per -d date, newest first)")
+	flag.BoolVar(&config.NoHeader, "no-header", false, "With -format csv or tsv, omit the header row")
+	flag.IntVar(&config.Top, "top", 0, "Print the N most active cookies, most active first, instead of only the winners (0 = off)")
+	flag.BoolVar(&config.Ranked, "ranked", false, "With -top, print \"rank. cookie (count)\" lines; equal counts share a rank")
+	flag.StringVar(&config.Compare, "compare", "", "Compare the -d date with this date (YYYY-MM-DD): both dates' winners and the cookies whose counts rose or fell")
+	flag.BoolVar(&config.Print0, "print0", false, "Separate output cookies with NUL bytes instead of newlines (for xargs -0)")
+	flag.BoolVar(&config.ExplainEmpty, "explain-empty", false, "Explain on stderr why nothing was printed (also enabled by -v)")
+	flag.BoolVar(&config.Summary, "summary", false, "Print a summary of the scan (lines, distinct cookies, winning count) to stderr")
+	flag.StringVar(&config.Output, "o", "", "Write results to this file, created or truncated, instead of stdout")
+	flag.StringVar(&config.Sink, "sink", SinkStdout, "Where to write the winners: stdout or syslog (falls back to stdout if unavailable)")
+	flag.BoolVar(&config.NoColor, "no-color", false, "Disable colored output (also honors NO_COLOR)")
+
+	flag.StringVar(&config.CPUProfile, "cpuprofile", "", "Write a CPU profile to this file")
+	flag.StringVar(&config.MemProfile, "memprofile", "", "Write a heap profile to this file")
 
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "Usage: %s -f <filename> -d <date> [options]\n", os.Args[0])
@@ -31,11 +130,29 @@ func ParseFlags() (*Config, error) {
 		fmt.Fprintf(os.Stderr, "\nExamples:\n")
 		fmt.Fprintf(os.Stderr, "  %s -f cookie_log.csv -d 2018-12-09\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s -f cookie_log.csv -d 2018-12-09 -v      # verbose output\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -f cookie_log.csv -d 2018-12-08 -d 2018-12-09  # several dates, one pass\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "  %s -f cookie_log.csv -d 2018-12-09 -vv     # debug output\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s -f cookie_log.csv -d 2018-12-09 -cpuprofile cpu.out  # profile the run\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  zcat cookie_log.csv.gz | %s -f - -d 2018-12-09  # read from stdin\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "  %s %s -f cookie_log.csv                  # inspect the file's layout\n", os.Args[0], SchemaCommand)
 	}
 
 	flag.Parse()
 
+	expanded, err := expandGlobs(filenames)
+	if err != nil {
+		return nil, err
+	}
+	config.Filenames = expanded
+	if len(expanded) > 0 {
+		config.Filename = expanded[0]
+	}
+
+	config.TargetDates = targetDates
+	if len(targetDates) > 0 {
+		config.TargetDate = targetDates[0]
+	}
+
 	if veryVerbose {
 		config.Verbosity = 2
 	} else if verbose {
@@ -44,6 +161,18 @@ func ParseFlags() (*Config, error) {
 		config.Verbosity = 0
 	}
 
+	if assumeTZ != "" {
+		loc, err := time.LoadLocation(assumeTZ)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -assume-tz timezone %q: %w", assumeTZ, err)
+		}
+		config.AssumeTZ = loc
+	}
+
+	if config.Quiet && config.Verbosity > 0 {
+		return nil, fmt.Errorf("-q cannot be combined with -v or -vv")
+	}
+
 	if err := validateConfig(&config); err != nil {
 		return nil, err
 	}
@@ -52,17 +181,408 @@ func ParseFlags() (*Config, error) {
 }
 
 func validateConfig(config *Config) error {
+	if config.Sort != SortAlphabetical && config.Sort != SortFirstSeen {
+		return fmt.Errorf("invalid -sort value %q: expected %s or %s", config.Sort, SortAlphabetical, SortFirstSeen)
+	}
+
+	if config.MaxLines < 0 {
+		return fmt.Errorf("-max-lines cannot be negative: %d", config.MaxLines)
+	}
+
+	if config.Sink != SinkStdout && config.Sink != SinkSyslog {
+		return fmt.Errorf("invalid -sink value %q: expected %s or %s", config.Sink, SinkStdout, SinkSyslog)
+	}
+
+	if config.Output != "" && config.Sink != SinkStdout {
+		return fmt.Errorf("-o cannot be combined with -sink %s", config.Sink)
+	}
+
+	if !slices.Contains(formats(), config.Format) {
+		return fmt.Errorf("invalid -format value %q: expected one of %s", config.Format, strings.Join(formats(), ", "))
+	}
+	if config.Format != FormatText {
+		if conflict := countsConflict(config); conflict != "" {
+			return fmt.Errorf("-format %s cannot be combined with %s", config.Format, conflict)
+		}
+	}
+	if config.Format == FormatTable && config.Summary {
+		return fmt.Errorf("-format %s cannot be combined with -summary", FormatTable)
+	}
+	if config.NoHeader {
+		if formatter, _ := cookie.LookupFormatter(config.Format); !isHeadered(formatter) {
+			return fmt.Errorf("-no-header requires a -format with a header row, such as %s or %s", cookie.FormatCSV, cookie.FormatTSV)
+		}
+	}
+
+	if config.TopPerHour {
+		if conflict := topPerHourConflict(config); conflict != "" {
+			return fmt.Errorf("-top-per-hour cannot be combined with %s", conflict)
+		}
+	}
+
+	if config.Summary {
+		if conflict := summaryConflict(config); conflict != "" {
+			return fmt.Errorf("-summary cannot be combined with %s", conflict)
+		}
+	}
+
+	if config.Top < 0 {
+		return fmt.Errorf("-top cannot be negative: %d", config.Top)
+	}
+	if config.Ranked && config.Top == 0 {
+		return fmt.Errorf("-ranked requires -top")
+	}
+	if config.Ranked && config.Print0 {
+		return fmt.Errorf("-ranked cannot be combined with -print0")
+	}
+	if config.Top > 0 {
+		if conflict := topConflict(config); conflict != "" {
+			return fmt.Errorf("-top cannot be combined with %s", conflict)
+		}
+	}
+
+	if config.Compare != "" {
+		if conflict := compareConflict(config); conflict != "" {
+			return fmt.Errorf("-compare cannot be combined with %s", conflict)
+		}
+		if config.Compare == config.TargetDate {
+			return fmt.Errorf("-compare %s is the -d date", config.Compare)
+		}
+	}
+
+	if config.WinnerOnly && config.State != "" {
+		return fmt.Errorf("-winner-only cannot be combined with -state")
+	}
+	if config.State != "" {
+		if conflict := stateConflict(config); conflict != "" {
+			return fmt.Errorf("-state cannot be combined with %s", conflict)
+		}
+	}
+
+	if config.Manifest != "" {
+		if config.Filename != "" || config.TargetDate != "" {
+			return fmt.Errorf("-manifest cannot be combined with -f or -d")
+		}
+		if conflict := manifestConflict(config); conflict != "" {
+			return fmt.Errorf("-manifest cannot be combined with %s", conflict)
+		}
+		return validateInput(config.Manifest)
+	}
+
 	if config.Filename == "" {
 		return fmt.Errorf("a filename is required (use -f flag)")
 	}
 
-	if config.TargetDate == "" {
+	if config.TargetDate == "" && !config.SortCheck {
 		return fmt.Errorf("a target date is required (use -d flag)")
 	}
 
-	if _, err := os.Stat(config.Filename); os.IsNotExist(err) {
-		return fmt.Errorf("file does not exist: %s", config.Filename)
+	for i, date := range config.TargetDates {
+		if slices.Contains(config.TargetDates[:i], date) {
+			return fmt.Errorf("-d %s given more than once", date)
+		}
+	}
+	if len(config.TargetDates) > 1 {
+		if conflict := multiDateConflict(config); conflict != "" {
+			return fmt.Errorf("several -d dates cannot be combined with %s", conflict)
+		}
 	}
 
+	if len(config.Filenames) > 1 || slices.ContainsFunc(config.Filenames, isDir) {
+		if conflict := multiFileConflict(config); conflict != "" {
+			return fmt.Errorf("several input files or directories cannot be combined with %s", conflict)
+		}
+	}
+
+	if config.State != "" && (parser.IsURL(config.Filename) || config.Filename == parser.Stdin) {
+		return fmt.Errorf("-state requires a local file, not a URL or stdin")
+	}
+
+	for _, filename := range config.Filenames {
+		if err := validateInput(filename); err != nil {
+			return err
+		}
+	}
 	return nil
 }
+
+// expandGlobs replaces each glob pattern among filenames with the files it
+// matches, in lexical order. URLs, stdin and plain names are kept as given.
+func expandGlobs(filenames []string) ([]string, error) {
+	expanded := make([]string, 0, len(filenames))
+	for _, filename := range filenames {
+		if parser.IsURL(filename) || !strings.ContainsAny(filename, "*?[") {
+			expanded = append(expanded, filename)
+			continue
+		}
+		matches, err := filepath.Glob(filename)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -f pattern %q: %w", filename, err)
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match -f pattern %q", filename)
+		}
+		expanded = append(expanded, matches...)
+	}
+	return expanded, nil
+}
+
+// countsConflict names the first flag whose output the formats with counts,
+// every -format but text, cannot carry, or returns "" when there is none.
+func countsConflict(config *Config) string {
+	switch {
+	case config.Print0:
+		return "-print0"
+	case config.TopPerHour:
+		return "-top-per-hour"
+	case config.SortCheck:
+		return "-sort-check"
+	case config.Manifest != "":
+		return "-manifest"
+	case config.State != "":
+		return "-state"
+	case config.WinnerOnly:
+		return "-winner-only"
+	}
+	return ""
+}
+
+// topPerHourConflict names the first flag that the hourly table does not
+// honour, or returns "" when there is none.
+func topPerHourConflict(config *Config) string {
+	switch {
+	case config.Manifest != "":
+		return "-manifest"
+	case config.WinnerOnly:
+		return "-winner-only"
+	case config.Print0:
+		return "-print0"
+	case config.Sort == SortFirstSeen:
+		return "-sort " + SortFirstSeen
+	}
+	return ""
+}
+
+// stateConflict names the first flag that -state cannot honour from saved
+// counts or that would skip writing the state, or returns "" when there is
+// none.
+func stateConflict(config *Config) string {
+	switch {
+	case config.Manifest != "":
+		return "-manifest"
+	case config.TopPerHour:
+		return "-top-per-hour"
+	case config.SortCheck:
+		return "-sort-check"
+	case config.Sort == SortFirstSeen:
+		return "-sort " + SortFirstSeen
+	}
+	return ""
+}
+
+// manifestConflict names the first flag that the manifest's jobs do not
+// honour, or returns "" when there is none.
+func manifestConflict(config *Config) string {
+	switch {
+	case config.WinnerOnly:
+		return "-winner-only"
+	case config.SortCheck:
+		return "-sort-check"
+	case config.Print0:
+		return "-print0"
+	case config.ExplainEmpty:
+		return "-explain-empty"
+	}
+	return ""
+}
+
+// summaryConflict names the first flag whose run -summary cannot describe,
+// or returns "" when there is none.
+func summaryConflict(config *Config) string {
+	switch {
+	case config.Manifest != "":
+		return "-manifest"
+	case config.TopPerHour:
+		return "-top-per-hour"
+	case config.SortCheck:
+		return "-sort-check"
+	case config.State != "":
+		return "-state"
+	case config.WinnerOnly:
+		return "-winner-only"
+	}
+	return ""
+}
+
+// topConflict names the first flag whose output -top does not replace or
+// honour, or returns "" when there is none.
+func topConflict(config *Config) string {
+	switch {
+	case config.Manifest != "":
+		return "-manifest"
+	case config.TopPerHour:
+		return "-top-per-hour"
+	case config.SortCheck:
+		return "-sort-check"
+	case config.State != "":
+		return "-state"
+	case config.WinnerOnly:
+		return "-winner-only"
+	case config.Format != FormatText:
+		return "-format " + config.Format
+	case config.Sort == SortFirstSeen:
+		return "-sort " + SortFirstSeen
+	case config.Summary:
+		return "-summary"
+	}
+	return ""
+}
+
+// multiDateConflict names the first flag that only supports a single -d, or
+// returns "" when there is none.
+func multiDateConflict(config *Config) string {
+	switch {
+	case config.Print0:
+		return "-print0"
+	case config.TopPerHour:
+		return "-top-per-hour"
+	case config.SortCheck:
+		return "-sort-check"
+	case config.State != "":
+		return "-state"
+	case config.WinnerOnly:
+		return "-winner-only"
+	case config.Format != FormatText && config.Format != FormatTable:
+		return "-format " + config.Format
+	case config.Summary:
+		return "-summary"
+	case config.Top > 0:
+		return "-top"
+	}
+	return ""
+}
+
+// compareConflict names the first flag that the two-date comparison does not
+// honour, or returns "" when there is none.
+func compareConflict(config *Config) string {
+	switch {
+	case config.Manifest != "":
+		return "-manifest"
+	case len(config.TargetDates) > 1:
+		return "several -d dates"
+	case config.Print0:
+		return "-print0"
+	case config.TopPerHour:
+		return "-top-per-hour"
+	case config.SortCheck:
+		return "-sort-check"
+	case config.State != "":
+		return "-state"
+	case config.WinnerOnly:
+		return "-winner-only"
+	case config.Format != FormatText:
+		return "-format " + config.Format
+	case config.Summary:
+		return "-summary"
+	case config.Top > 0:
+		return "-top"
+	}
+	return ""
+}
+
+// multiFileConflict names the first flag that only supports a single input
+// file, or returns "" when there is none.
+func multiFileConflict(config *Config) string {
+	switch {
+	case len(config.TargetDates) > 1:
+		return "several -d dates"
+	case config.Compare != "":
+		return "-compare"
+	case config.TopPerHour:
+		return "-top-per-hour"
+	case config.SortCheck:
+		return "-sort-check"
+	case config.State != "":
+		return "-state"
+	case config.WinnerOnly:
+		return "-winner-only"
+	case config.Format != FormatText:
+		return "-format " + config.Format
+	case config.Sort == SortFirstSeen:
+		return "-sort " + SortFirstSeen
+	case config.Summary:
+		return "-summary"
+	case config.Top > 0:
+		return "-top"
+	}
+	return ""
+}
+
+// stringList collects a repeatable flag such as -f or -d.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// ParseSchemaFlags parses the arguments that follow the schema subcommand.
+func ParseSchemaFlags(args []string) (*SchemaConfig, error) {
+	var config SchemaConfig
+
+	flags := flag.NewFlagSet(SchemaCommand, flag.ContinueOnError)
+	flags.StringVar(&config.Filename, "f", "", "Cookie log file or HTTP(S) URL to inspect (required)")
+	flags.IntVar(&config.Rows, "rows", 5, "Number of data rows to sample for timestamp detection")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: %s %s -f <filename> [options]\n", os.Args[0], SchemaCommand)
+		fmt.Fprintf(flags.Output(), "\nReport the detected delimiter, columns and timestamp layout of a log file.\n\n")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if config.Filename == "" {
+		return nil, fmt.Errorf("a filename is required (use -f flag)")
+	}
+	if config.Rows < 1 {
+		return nil, fmt.Errorf("-rows must be at least 1: %d", config.Rows)
+	}
+	if err := validateInput(config.Filename); err != nil {
+		return nil, err
+	}
+
+	return &config, nil
+}
+
+// validateInput checks that a local input file exists and is readable. URLs
+// are only checked when fetched and stdin is always accepted.
+func validateInput(filename string) error {
+	if parser.IsURL(filename) || filename == parser.Stdin {
+		return nil
+	}
+
+	if _, err := os.Stat(filename); os.IsNotExist(err) {
+		return fmt.Errorf("file does not exist: %s", filename)
+	}
+
+	file, err := os.Open(filename) //nolint:gosec
+	if err != nil {
+		return fmt.Errorf("file is not readable: %s: %w", filename, err)
+	}
+	file.Close()
+
+	return nil
+}
+
+// isHeadered reports whether formatter writes a header row -no-header can
+// leave out.
+func isHeadered(formatter cookie.Formatter) bool {
+	_, ok := formatter.(cookie.HeaderedFormatter)
+	return ok
+}
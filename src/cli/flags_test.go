@@ -3,6 +3,7 @@ package cli_test
 import (
 	"flag"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/mfenderov/most-active-cookie/src/cli"
@@ -29,11 +30,136 @@ func TestParseFlags(t *testing.T) {
 			name: "valid arguments",
 			args: []string{"-f", tmpFile.Name(), "-d", "2018-12-09"},
 			expected: &cli.Config{
-				Filename:   tmpFile.Name(),
-				TargetDate: "2018-12-09",
+				Filename:    tmpFile.Name(),
+				TargetDate:  "2018-12-09",
+				TargetDates: []string{"2018-12-09"},
 			},
 			expectError: false,
 		},
+		{
+			name: "profiling flags",
+			args: []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-cpuprofile", "cpu.out", "-memprofile", "mem.out"},
+			expected: &cli.Config{
+				Filename:    tmpFile.Name(),
+				TargetDate:  "2018-12-09",
+				TargetDates: []string{"2018-12-09"},
+				CPUProfile:  "cpu.out",
+				MemProfile:  "mem.out",
+			},
+			expectError: false,
+		},
+		{
+			name: "URL filename skips existence check",
+			args: []string{"-f", "https://example.com/cookie_log.csv", "-d", "2018-12-09"},
+			expected: &cli.Config{
+				Filename:    "https://example.com/cookie_log.csv",
+				TargetDate:  "2018-12-09",
+				TargetDates: []string{"2018-12-09"},
+			},
+			expectError: false,
+		},
+		{
+			name: "several dates",
+			args: []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-d", "2018-12-08"},
+			expected: &cli.Config{
+				Filename:    tmpFile.Name(),
+				TargetDate:  "2018-12-09",
+				TargetDates: []string{"2018-12-09", "2018-12-08"},
+			},
+			expectError: false,
+		},
+		{
+			name: "output file",
+			args: []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-o", "results.txt"},
+			expected: &cli.Config{
+				Filename:    tmpFile.Name(),
+				TargetDate:  "2018-12-09",
+				TargetDates: []string{"2018-12-09"},
+				Output:      "results.txt",
+			},
+			expectError: false,
+		},
+		{
+			name: "stdin skips existence check",
+			args: []string{"-f", "-", "-d", "2018-12-09"},
+			expected: &cli.Config{
+				Filename:    "-",
+				TargetDate:  "2018-12-09",
+				TargetDates: []string{"2018-12-09"},
+			},
+			expectError: false,
+		},
+		{
+			name: "NUL-delimited output",
+			args: []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-print0"},
+			expected: &cli.Config{
+				Filename:    tmpFile.Name(),
+				TargetDate:  "2018-12-09",
+				TargetDates: []string{"2018-12-09"},
+				Print0:      true,
+			},
+			expectError: false,
+		},
+		{
+			name: "sort check without date",
+			args: []string{"-f", tmpFile.Name(), "-sort-check"},
+			expected: &cli.Config{
+				Filename:  tmpFile.Name(),
+				SortCheck: true,
+			},
+			expectError: false,
+		},
+		{
+			name: "manifest without file and date",
+			args: []string{"-manifest", tmpFile.Name(), "-fail-fast"},
+			expected: &cli.Config{
+				Manifest: tmpFile.Name(),
+				FailFast: true,
+			},
+			expectError: false,
+		},
+		{
+			name:          "manifest combined with file",
+			args:          []string{"-manifest", tmpFile.Name(), "-f", tmpFile.Name()},
+			expectError:   true,
+			errorContains: "-manifest cannot be combined",
+		},
+		{
+			name:          "manifest with state",
+			args:          []string{"-manifest", tmpFile.Name(), "-state", "state.json"},
+			expectError:   true,
+			errorContains: "-state cannot be combined with -manifest",
+		},
+		{
+			name:          "manifest with top-per-hour",
+			args:          []string{"-manifest", tmpFile.Name(), "-top-per-hour"},
+			expectError:   true,
+			errorContains: "-top-per-hour cannot be combined with -manifest",
+		},
+		{
+			name:          "manifest with winner-only",
+			args:          []string{"-manifest", tmpFile.Name(), "-winner-only"},
+			expectError:   true,
+			errorContains: "-manifest cannot be combined with -winner-only",
+		},
+		{
+			name:          "manifest with sort-check",
+			args:          []string{"-manifest", tmpFile.Name(), "-sort-check"},
+			expectError:   true,
+			errorContains: "-manifest cannot be combined with -sort-check",
+		},
+		{
+			name:          "manifest with print0",
+			args:          []string{"-manifest", tmpFile.Name(), "-print0"},
+			expectError:   true,
+			errorContains: "-manifest cannot be combined with -print0",
+		},
+		{
+			name:          "manifest with explain-empty",
+			args:          []string{"-manifest", tmpFile.Name(), "-explain-empty"},
+			expectError:   true,
+			errorContains: "-manifest cannot be combined with -explain-empty",
+		},
 		{
 			name:          "missing filename",
 			args:          []string{"-d", "2018-12-09"},
@@ -52,6 +178,219 @@ func TestParseFlags(t *testing.T) {
 			expectError:   true,
 			errorContains: "file does not exist",
 		},
+		{
+			name:          "negative max lines",
+			args:          []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-max-lines", "-1"},
+			expectError:   true,
+			errorContains: "-max-lines cannot be negative",
+		},
+		{
+			name:          "unknown timezone",
+			args:          []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-assume-tz", "Mars/Olympus_Mons"},
+			expectError:   true,
+			errorContains: "invalid -assume-tz timezone",
+		},
+		{
+			name:          "quiet combined with verbose",
+			args:          []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-q", "-v"},
+			expectError:   true,
+			errorContains: "-q cannot be combined",
+		},
+		{
+			name:          "unknown sort order",
+			args:          []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-sort", "random"},
+			expectError:   true,
+			errorContains: "invalid -sort value",
+		},
+		{
+			name:          "state with URL input",
+			args:          []string{"-f", "https://example.com/cookie_log.csv", "-d", "2018-12-09", "-state", "state.json"},
+			expectError:   true,
+			errorContains: "-state requires a local file",
+		},
+		{
+			name:          "state with stdin input",
+			args:          []string{"-f", "-", "-d", "2018-12-09", "-state", "state.json"},
+			expectError:   true,
+			errorContains: "-state requires a local file",
+		},
+		{
+			name:          "winner-only with state",
+			args:          []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-winner-only", "-state", "state.json"},
+			expectError:   true,
+			errorContains: "-winner-only cannot be combined with -state",
+		},
+		{
+			name:          "unknown sink",
+			args:          []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-sink", "kafka"},
+			expectError:   true,
+			errorContains: "invalid -sink value",
+		},
+		{
+			name:          "output file with syslog sink",
+			args:          []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-o", "results.txt", "-sink", "syslog"},
+			expectError:   true,
+			errorContains: "-o cannot be combined with -sink syslog",
+		},
+		{
+			name:          "repeated date",
+			args:          []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-d", "2018-12-09"},
+			expectError:   true,
+			errorContains: "-d 2018-12-09 given more than once",
+		},
+		{
+			name:          "several dates with winner-only",
+			args:          []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-d", "2018-12-08", "-winner-only"},
+			expectError:   true,
+			errorContains: "several -d dates cannot be combined with -winner-only",
+		},
+		{
+			name:          "unknown format",
+			args:          []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-format", "xml"},
+			expectError:   true,
+			errorContains: "invalid -format value",
+		},
+		{
+			name:          "json with print0",
+			args:          []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-format", "json", "-print0"},
+			expectError:   true,
+			errorContains: "-format json cannot be combined with -print0",
+		},
+		{
+			name:          "tsv with print0",
+			args:          []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-format", "tsv", "-print0"},
+			expectError:   true,
+			errorContains: "-format tsv cannot be combined with -print0",
+		},
+		{
+			name:          "no-header without tsv",
+			args:          []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-format", "json", "-no-header"},
+			expectError:   true,
+			errorContains: "-no-header requires a -format with a header row",
+		},
+		{
+			name:          "json with state",
+			args:          []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-format", "json", "-state", "state.json"},
+			expectError:   true,
+			errorContains: "-format json cannot be combined with -state",
+		},
+		{
+			name:          "summary with top per hour",
+			args:          []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-summary", "-top-per-hour"},
+			expectError:   true,
+			errorContains: "-summary cannot be combined with -top-per-hour",
+		},
+		{
+			name:          "summary with several dates",
+			args:          []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-d", "2018-12-08", "-summary"},
+			expectError:   true,
+			errorContains: "several -d dates cannot be combined with -summary",
+		},
+		{
+			name: "summary with json",
+			args: []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-summary", "-format", "json"},
+			expected: &cli.Config{
+				Filename:    tmpFile.Name(),
+				TargetDate:  "2018-12-09",
+				TargetDates: []string{"2018-12-09"},
+				Summary:     true,
+			},
+			expectError: false,
+		},
+		{
+			name: "table with several dates",
+			args: []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-d", "2018-12-08", "-format", "table"},
+			expected: &cli.Config{
+				Filename:    tmpFile.Name(),
+				TargetDate:  "2018-12-09",
+				TargetDates: []string{"2018-12-09", "2018-12-08"},
+			},
+			expectError: false,
+		},
+		{
+			name:          "table with summary",
+			args:          []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-format", "table", "-summary"},
+			expectError:   true,
+			errorContains: "-format table cannot be combined with -summary",
+		},
+		{
+			name:          "compare with the -d date",
+			args:          []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-compare", "2018-12-09"},
+			expectError:   true,
+			errorContains: "-compare 2018-12-09 is the -d date",
+		},
+		{
+			name:          "compare with several dates",
+			args:          []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-d", "2018-12-08", "-compare", "2018-12-10"},
+			expectError:   true,
+			errorContains: "-compare cannot be combined with several -d dates",
+		},
+		{
+			name:          "compare with json",
+			args:          []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-compare", "2018-12-10", "-format", "json"},
+			expectError:   true,
+			errorContains: "-compare cannot be combined with -format json",
+		},
+		{
+			name:          "ranked without top",
+			args:          []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-ranked"},
+			expectError:   true,
+			errorContains: "-ranked requires -top",
+		},
+		{
+			name:          "top with json",
+			args:          []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-top", "3", "-format", "json"},
+			expectError:   true,
+			errorContains: "-top cannot be combined with -format json",
+		},
+		{
+			name:          "top with several dates",
+			args:          []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-d", "2018-12-08", "-top", "3"},
+			expectError:   true,
+			errorContains: "several -d dates cannot be combined with -top",
+		},
+		{
+			name:          "negative top",
+			args:          []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-top", "-1"},
+			expectError:   true,
+			errorContains: "-top cannot be negative",
+		},
+		{
+			name:          "top per hour with winner-only",
+			args:          []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-top-per-hour", "-winner-only"},
+			expectError:   true,
+			errorContains: "-top-per-hour cannot be combined with -winner-only",
+		},
+		{
+			name:          "top per hour with print0",
+			args:          []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-top-per-hour", "-print0"},
+			expectError:   true,
+			errorContains: "-top-per-hour cannot be combined with -print0",
+		},
+		{
+			name:          "top per hour with first-seen order",
+			args:          []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-top-per-hour", "-sort", "first-seen"},
+			expectError:   true,
+			errorContains: "-top-per-hour cannot be combined with -sort first-seen",
+		},
+		{
+			name:          "state with first-seen order",
+			args:          []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-state", "state.json", "-sort", "first-seen"},
+			expectError:   true,
+			errorContains: "-state cannot be combined with -sort first-seen",
+		},
+		{
+			name:          "state with top per hour",
+			args:          []string{"-f", tmpFile.Name(), "-d", "2018-12-09", "-state", "state.json", "-top-per-hour"},
+			expectError:   true,
+			errorContains: "-state cannot be combined with -top-per-hour",
+		},
+		{
+			name:          "state with sort check",
+			args:          []string{"-f", tmpFile.Name(), "-state", "state.json", "-sort-check"},
+			expectError:   true,
+			errorContains: "-state cannot be combined with -sort-check",
+		},
 		{
 			name:          "no arguments",
 			args:          []string{},
@@ -85,6 +424,162 @@ func TestParseFlags(t *testing.T) {
 			assert.NoError(t, err, "unexpected error")
 			assert.Equal(t, tt.expected.Filename, config.Filename, "filename mismatch")
 			assert.Equal(t, tt.expected.TargetDate, config.TargetDate, "target date mismatch")
+			assert.Equal(t, tt.expected.TargetDates, config.TargetDates, "target dates mismatch")
+			assert.Equal(t, tt.expected.CPUProfile, config.CPUProfile, "CPU profile mismatch")
+			assert.Equal(t, tt.expected.MemProfile, config.MemProfile, "memory profile mismatch")
+			assert.Equal(t, tt.expected.Print0, config.Print0, "print0 mismatch")
+			assert.Equal(t, tt.expected.SortCheck, config.SortCheck, "sort check mismatch")
+			assert.Equal(t, tt.expected.Manifest, config.Manifest, "manifest mismatch")
+			assert.Equal(t, tt.expected.FailFast, config.FailFast, "fail-fast mismatch")
+			assert.Equal(t, tt.expected.Output, config.Output, "output mismatch")
+			assert.Equal(t, tt.expected.Summary, config.Summary, "summary mismatch")
+		})
+	}
+}
+
+func TestParseFlags_MultipleFiles(t *testing.T) {
+	dir := t.TempDir()
+	var shards []string
+	for _, name := range []string{"part-2.csv", "part-1.csv"} {
+		shard := filepath.Join(dir, name)
+		if err := os.WriteFile(shard, []byte("cookie,timestamp\n"), 0o600); err != nil {
+			t.Fatalf("failed to create shard: %v", err)
+		}
+		shards = append(shards, shard)
+	}
+	partOne, partTwo := shards[1], shards[0]
+
+	tests := []struct {
+		name              string
+		args              []string
+		expectedFilenames []string
+		errorContains     string
+	}{
+		{
+			name:              "repeated -f",
+			args:              []string{"-f", partTwo, "-f", partOne, "-d", "2018-12-09"},
+			expectedFilenames: []string{partTwo, partOne},
+		},
+		{
+			name:              "glob expanded in lexical order",
+			args:              []string{"-f", filepath.Join(dir, "part-*.csv"), "-d", "2018-12-09"},
+			expectedFilenames: []string{partOne, partTwo},
+		},
+		{
+			name:          "glob without matches",
+			args:          []string{"-f", filepath.Join(dir, "missing-*.csv"), "-d", "2018-12-09"},
+			errorContains: "no files match -f pattern",
+		},
+		{
+			name:          "missing file among several",
+			args:          []string{"-f", partOne, "-f", filepath.Join(dir, "missing.csv"), "-d", "2018-12-09"},
+			errorContains: "file does not exist: " + filepath.Join(dir, "missing.csv"),
+		},
+		{
+			name:          "several files with state",
+			args:          []string{"-f", partOne, "-f", partTwo, "-d", "2018-12-09", "-state", "state.json"},
+			errorContains: "several input files or directories cannot be combined with -state",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+			originalArgs := os.Args
+			defer func() { os.Args = originalArgs }()
+			os.Args = append([]string{"test"}, tt.args...)
+
+			config, err := cli.ParseFlags()
+
+			if tt.errorContains != "" {
+				assert.ErrorContains(t, err, tt.errorContains, "error should contain expected substring")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expectedFilenames, config.Filenames, "filenames mismatch")
+			assert.Equal(t, tt.expectedFilenames[0], config.Filename, "filename should be the first input")
+		})
+	}
+}
+
+func TestParseFlags_UnreadableFile(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("file permissions are not enforced for root")
+	}
+
+	tmpFile, err := os.CreateTemp("", "test_*.csv")
+	if err != nil {
+		t.Fatalf("failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	tmpFile.Close()
+
+	if err := os.Chmod(tmpFile.Name(), 0o000); err != nil {
+		t.Fatalf("failed to change file mode: %v", err)
+	}
+
+	flag.CommandLine = flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	originalArgs := os.Args
+	defer func() { os.Args = originalArgs }()
+	os.Args = []string{"test", "-f", tmpFile.Name(), "-d", "2018-12-09"}
+
+	_, err = cli.ParseFlags()
+
+	assert.Error(t, err, "expected error for unreadable file")
+	assert.Contains(t, err.Error(), "file is not readable", "error should mention readability")
+}
+
+func TestParseSchemaFlags(t *testing.T) {
+	tmpFile, err := os.CreateTemp("", "test_*.csv")
+	if err != nil {
+		t.Fatalf("failed to create temp file: %v", err)
+	}
+	defer os.Remove(tmpFile.Name())
+	tmpFile.Close()
+
+	tests := []struct {
+		name          string
+		args          []string
+		expected      *cli.SchemaConfig
+		errorContains string
+	}{
+		{
+			name:     "defaults",
+			args:     []string{"-f", tmpFile.Name()},
+			expected: &cli.SchemaConfig{Filename: tmpFile.Name(), Rows: 5},
+		},
+		{
+			name:     "custom sample size",
+			args:     []string{"-f", tmpFile.Name(), "-rows", "20"},
+			expected: &cli.SchemaConfig{Filename: tmpFile.Name(), Rows: 20},
+		},
+		{
+			name:          "missing filename",
+			args:          []string{},
+			errorContains: "filename is required",
+		},
+		{
+			name:          "non-positive sample size",
+			args:          []string{"-f", tmpFile.Name(), "-rows", "0"},
+			errorContains: "-rows must be at least 1",
+		},
+		{
+			name:          "non-existent file",
+			args:          []string{"-f", "nonexistent.csv"},
+			errorContains: "file does not exist",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := cli.ParseSchemaFlags(tt.args)
+
+			if tt.errorContains != "" {
+				assert.ErrorContains(t, err, tt.errorContains, "error should contain expected substring")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, config, "config mismatch")
 		})
 	}
 }
@@ -0,0 +1,79 @@
+package cli
+
+import (
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// csvExtensions are the file name suffixes read from a directory input.
+// Gzipped logs are decompressed by the parser.
+var csvExtensions = []string{".csv", ".csv.gz"}
+
+// ExpandDirs replaces each directory among filenames with the CSV files it
+// contains, in lexical order, descending into subdirectories when recursive is
+// set. Other files in a directory are skipped and logged at debug level. It is
+// an error for a directory to contain no CSV files.
+func ExpandDirs(filenames []string, recursive bool) ([]string, error) {
+	expanded := make([]string, 0, len(filenames))
+	for _, filename := range filenames {
+		if !isDir(filename) {
+			expanded = append(expanded, filename)
+			continue
+		}
+
+		files, err := csvFilesIn(filename, recursive)
+		if err != nil {
+			return nil, err
+		}
+		if len(files) == 0 {
+			return nil, fmt.Errorf("no CSV files found in directory %s", filename)
+		}
+		expanded = append(expanded, files...)
+	}
+	return expanded, nil
+}
+
+func csvFilesIn(dir string, recursive bool) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			if path != dir && !recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !isCSV(entry.Name()) {
+			slog.Debug("skipping non-CSV file", "path", path)
+			return nil
+		}
+		files = append(files, path)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+	return files, nil
+}
+
+func isCSV(name string) bool {
+	name = strings.ToLower(name)
+	for _, extension := range csvExtensions {
+		if strings.HasSuffix(name, extension) {
+			return true
+		}
+	}
+	return false
+}
+
+// isDir reports whether filename names an existing local directory.
+func isDir(filename string) bool {
+	info, err := os.Stat(filename)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,64 @@
+package cli_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mfenderov/most-active-cookie/src/cli"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandDirs(t *testing.T) {
+	dir := t.TempDir()
+	empty := t.TempDir()
+	for _, name := range []string{"a.csv", "b.txt", "d.CSV.gz", filepath.Join("sub", "c.csv")} {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o750), "failed to create directory")
+		require.NoError(t, os.WriteFile(path, []byte("cookie,timestamp\n"), 0o600), "failed to create file")
+	}
+
+	tests := []struct {
+		name          string
+		filenames     []string
+		recursive     bool
+		expected      []string
+		errorContains string
+	}{
+		{
+			name:      "top level only",
+			filenames: []string{dir},
+			expected:  []string{filepath.Join(dir, "a.csv"), filepath.Join(dir, "d.CSV.gz")},
+		},
+		{
+			name:      "recursive",
+			filenames: []string{dir},
+			recursive: true,
+			expected:  []string{filepath.Join(dir, "a.csv"), filepath.Join(dir, "d.CSV.gz"), filepath.Join(dir, "sub", "c.csv")},
+		},
+		{
+			name:      "files and other inputs kept as given",
+			filenames: []string{"cookie_log.csv", "-", filepath.Join(dir, "sub")},
+			expected:  []string{"cookie_log.csv", "-", filepath.Join(dir, "sub", "c.csv")},
+		},
+		{
+			name:          "directory without CSV files",
+			filenames:     []string{empty},
+			errorContains: "no CSV files found in directory",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filenames, err := cli.ExpandDirs(tt.filenames, tt.recursive)
+
+			if tt.errorContains != "" {
+				assert.ErrorContains(t, err, tt.errorContains, "error should contain expected substring")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, filenames, "filenames mismatch")
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Job is one (file, date) query listed in a manifest.
+type Job struct {
+	Filename   string
+	TargetDate string
+}
+
+// ReadManifest reads the file,date rows of the manifest at path. An optional
+// leading "file,date" header row is skipped.
+func ReadManifest(path string) ([]Job, error) {
+	file, err := os.Open(path) //nolint:gosec
+	if err != nil {
+		return nil, fmt.Errorf("failed to open manifest %s: %w", path, err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = 2
+	reader.TrimLeadingSpace = true
+
+	var jobs []Job
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid manifest %s: %w", path, err)
+		}
+
+		if len(jobs) == 0 && strings.EqualFold(record[0], "file") && strings.EqualFold(record[1], "date") {
+			continue
+		}
+		jobs = append(jobs, Job{Filename: record[0], TargetDate: record[1]})
+	}
+
+	if len(jobs) == 0 {
+		return nil, fmt.Errorf("manifest %s lists no jobs", path)
+	}
+	return jobs, nil
+}
@@ -0,0 +1,63 @@
+package cli_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mfenderov/most-active-cookie/src/cli"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadManifest(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		expected      []cli.Job
+		errorContains string
+	}{
+		{
+			name:    "with header",
+			content: "file,date\nmonday.csv,2018-12-09\n\"logs, old/tuesday.csv\",2018-12-10\n",
+			expected: []cli.Job{
+				{Filename: "monday.csv", TargetDate: "2018-12-09"},
+				{Filename: "logs, old/tuesday.csv", TargetDate: "2018-12-10"},
+			},
+		},
+		{
+			name:    "without header",
+			content: "monday.csv, 2018-12-09\n",
+			expected: []cli.Job{
+				{Filename: "monday.csv", TargetDate: "2018-12-09"},
+			},
+		},
+		{
+			name:          "wrong column count",
+			content:       "monday.csv,2018-12-09,extra\n",
+			errorContains: "invalid manifest",
+		},
+		{
+			name:          "header only",
+			content:       "file,date\n",
+			errorContains: "lists no jobs",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "jobs.csv")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("failed to write manifest: %v", err)
+			}
+
+			jobs, err := cli.ReadManifest(path)
+
+			if tt.errorContains != "" {
+				assert.ErrorContains(t, err, tt.errorContains, "error should contain expected substring")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, jobs, "jobs mismatch")
+		})
+	}
+}
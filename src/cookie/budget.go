@@ -0,0 +1,47 @@
+package cookie
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrMemoryBudgetExceeded is returned when the estimated size of the counts
+// grows past the budget set with WithMemoryBudget.
+var ErrMemoryBudgetExceeded = errors.New("memory budget exceeded")
+
+const (
+	// approxBytesPerCookie estimates what one distinct cookie costs in a count
+	// map: the string header and int value, a typical 16-byte cookie ID and the
+	// map's slot and load-factor overhead.
+	approxBytesPerCookie = 64
+	// budgetCheckInterval is how many entries pass between budget checks, so
+	// the estimate stays off the per-entry hot path.
+	budgetCheckInterval = 1024
+)
+
+// enforceBudget wraps next so that every budgetCheckInterval entries the
+// number of distinct cookies reported by distinct is converted into an
+// estimated byte size and compared against the budget.
+func (p *Processor) enforceBudget(distinct func() int, next EntryProcessor) EntryProcessor {
+	if p.memoryBudget <= 0 {
+		return next
+	}
+
+	seen := 0
+	return func(entry LogEntry) error {
+		if err := next(entry); err != nil {
+			return err
+		}
+
+		seen++
+		if seen%budgetCheckInterval != 0 {
+			return nil
+		}
+		cookies := distinct()
+		if estimate := int64(cookies) * approxBytesPerCookie; estimate > p.memoryBudget {
+			return fmt.Errorf("%w: about %d bytes for %d distinct cookies, budget is %d bytes",
+				ErrMemoryBudgetExceeded, estimate, cookies, p.memoryBudget)
+		}
+		return nil
+	}
+}
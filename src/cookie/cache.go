@@ -0,0 +1,84 @@
+package cookie
+
+import (
+	"container/list"
+	"os"
+	"sync"
+	"time"
+)
+
+// cacheKey identifies a query result. Including the file's modification time
+// and size invalidates entries when the file changes.
+type cacheKey struct {
+	filename   string
+	targetDate string
+	modTime    time.Time
+	size       int64
+}
+
+type cacheItem struct {
+	key     cacheKey
+	cookies []string
+}
+
+// resultCache is a bounded, concurrency-safe LRU cache of query results.
+type resultCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    *list.List
+	items    map[cacheKey]*list.Element
+}
+
+func newResultCache(capacity int) *resultCache {
+	return &resultCache{
+		capacity: capacity,
+		order:    list.New(),
+		items:    make(map[cacheKey]*list.Element),
+	}
+}
+
+// keyFor builds the cache key for a query. It returns false when the file
+// cannot be stat'ed, in which case the query bypasses the cache.
+func keyFor(filename, targetDate string) (cacheKey, bool) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return cacheKey{}, false
+	}
+	return cacheKey{
+		filename:   filename,
+		targetDate: targetDate,
+		modTime:    info.ModTime(),
+		size:       info.Size(),
+	}, true
+}
+
+func (c *resultCache) get(key cacheKey) ([]string, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	item := elem.Value.(*cacheItem)
+	return append([]string{}, item.cookies...), true
+}
+
+func (c *resultCache) put(key cacheKey, cookies []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.items[key]; ok {
+		c.order.MoveToFront(elem)
+		elem.Value.(*cacheItem).cookies = append([]string{}, cookies...)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&cacheItem{key: key, cookies: append([]string{}, cookies...)})
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheItem).key)
+	}
+}
@@ -0,0 +1,68 @@
+package cookie_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mfenderov/most-active-cookie/src/cookie"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestProcessor_ResultCache(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "cookie_log.csv")
+	err := os.WriteFile(filename, []byte("cookie,timestamp\n"), 0o600)
+	assert.NoError(t, err, "failed to write log file")
+
+	entries := []cookie.LogEntry{
+		{Cookie: "A", Timestamp: "2018-12-09T14:19:00+00:00"},
+	}
+	streamEntries := func(_ string, processor cookie.EntryProcessor) {
+		for _, entry := range entries {
+			processor(entry)
+		}
+	}
+
+	mockParser := cookie.NewMockFileParser(t)
+	mockParser.EXPECT().StreamFile(filename, mock.AnythingOfType("cookie.EntryProcessor")).Run(streamEntries).Return(nil).Once()
+	processor := cookie.NewProcessor(mockParser, cookie.WithResultCache(8))
+
+	first, err := processor.FindMostActiveCookies(filename, "2018-12-09")
+	assert.NoError(t, err, "unexpected error")
+	second, err := processor.FindMostActiveCookies(filename, "2018-12-09")
+	assert.NoError(t, err, "unexpected error")
+	assert.Equal(t, first, second, "cached result should match the scanned result")
+
+	// Changing the file must invalidate the cached result.
+	err = os.WriteFile(filename, []byte("cookie,timestamp\nB,2018-12-09T10:13:00+00:00\n"), 0o600)
+	assert.NoError(t, err, "failed to rewrite log file")
+	err = os.Chtimes(filename, time.Now(), time.Now().Add(time.Minute))
+	assert.NoError(t, err, "failed to bump modification time")
+
+	entries = []cookie.LogEntry{
+		{Cookie: "B", Timestamp: "2018-12-09T10:13:00+00:00"},
+	}
+	mockParser.EXPECT().StreamFile(filename, mock.AnythingOfType("cookie.EntryProcessor")).Run(streamEntries).Return(nil).Once()
+
+	third, err := processor.FindMostActiveCookies(filename, "2018-12-09")
+	assert.NoError(t, err, "unexpected error")
+	assert.Equal(t, []string{"B"}, third, "modified file should be rescanned")
+}
+
+func TestProcessor_ResultCache_Eviction(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "cookie_log.csv")
+	err := os.WriteFile(filename, []byte("cookie,timestamp\n"), 0o600)
+	assert.NoError(t, err, "failed to write log file")
+
+	mockParser := cookie.NewMockFileParser(t)
+	processor := cookie.NewProcessor(mockParser, cookie.WithResultCache(1))
+
+	// With room for a single result, alternating dates always miss the cache.
+	mockParser.EXPECT().StreamFile(filename, mock.AnythingOfType("cookie.EntryProcessor")).Return(nil).Times(3)
+	for _, date := range []string{"2018-12-09", "2018-12-08", "2018-12-09"} {
+		_, err := processor.FindMostActiveCookies(filename, date)
+		assert.NoError(t, err, "unexpected error")
+	}
+}
@@ -0,0 +1,70 @@
+package cookie
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+)
+
+// CookieChange is a cookie whose count differs between two compared dates.
+type CookieChange struct {
+	Cookie string `json:"cookie"`
+	Before int    `json:"before"`
+	After  int    `json:"after"`
+}
+
+// Delta is After minus Before: positive when the cookie rose, negative when it
+// fell.
+func (c CookieChange) Delta() int {
+	return c.After - c.Before
+}
+
+// Comparison holds the winners of two dates and every cookie whose count
+// changed from the first date to the second.
+type Comparison struct {
+	Before        string
+	After         string
+	BeforeWinners []CookieCount
+	AfterWinners  []CookieCount
+	// Changes lists the cookies whose count changed, biggest rise first and
+	// biggest fall last, alphabetical among equal deltas.
+	Changes []CookieChange
+}
+
+// CompareDates counts before and after in a single pass over the file and
+// returns each date's winners, honouring the tie-break and winner order, and
+// how every cookie's count moved from before to after. The dates may be given
+// in either order; the scan only stops early past the later one.
+func (p *Processor) CompareDates(filename, before, after string) (Comparison, error) {
+	normalized, counts, err := p.countDates(filename, []string{before, after})
+	if err != nil {
+		return Comparison{}, err
+	}
+	if normalized[0] == normalized[1] {
+		return Comparison{}, fmt.Errorf("cannot compare %s with itself", before)
+	}
+
+	beforeCount, afterCount := counts[normalized[0]], counts[normalized[1]]
+	var changes []CookieChange
+	beforeCount.counter.each(func(cookie string, count int) {
+		if after := afterCount.counter.get(cookie); after != count {
+			changes = append(changes, CookieChange{Cookie: cookie, Before: count, After: after})
+		}
+	})
+	afterCount.counter.each(func(cookie string, count int) {
+		if beforeCount.counter.get(cookie) == 0 {
+			changes = append(changes, CookieChange{Cookie: cookie, After: count})
+		}
+	})
+	slices.SortFunc(changes, func(a, b CookieChange) int {
+		return cmp.Or(cmp.Compare(b.Delta(), a.Delta()), cmp.Compare(a.Cookie, b.Cookie))
+	})
+
+	return Comparison{
+		Before:        before,
+		After:         after,
+		BeforeWinners: p.countedWinners(beforeCount),
+		AfterWinners:  p.countedWinners(afterCount),
+		Changes:       changes,
+	}, nil
+}
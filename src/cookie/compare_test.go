@@ -0,0 +1,108 @@
+package cookie_test
+
+import (
+	"testing"
+
+	"github.com/mfenderov/most-active-cookie/src/cookie"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessor_CompareDates(t *testing.T) {
+	entries := []cookie.LogEntry{
+		{Cookie: "A", Timestamp: "2018-12-09T09:00:00+00:00"},
+		{Cookie: "A", Timestamp: "2018-12-09T10:00:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T11:00:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-09T12:00:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-10T09:00:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-10T10:00:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-10T11:00:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-10T12:00:00+00:00"},
+		{Cookie: "D", Timestamp: "2018-12-10T13:00:00+00:00"},
+	}
+
+	tests := []struct {
+		name          string
+		before        string
+		after         string
+		opts          []cookie.Option
+		expected      cookie.Comparison
+		errorContains string
+	}{
+		{
+			name:   "rises first and falls last",
+			before: "2018-12-09",
+			after:  "2018-12-10",
+			expected: cookie.Comparison{
+				Before:        "2018-12-09",
+				After:         "2018-12-10",
+				BeforeWinners: []cookie.CookieCount{{Cookie: "A", Count: 2}},
+				AfterWinners:  []cookie.CookieCount{{Cookie: "B", Count: 3}},
+				Changes: []cookie.CookieChange{
+					{Cookie: "B", Before: 1, After: 3},
+					{Cookie: "D", Before: 0, After: 1},
+					{Cookie: "A", Before: 2, After: 0},
+				},
+			},
+		},
+		{
+			name:   "later date first on a sorted file",
+			before: "2018-12-10",
+			after:  "2018-12-09",
+			opts:   []cookie.Option{cookie.WithSorted(true)},
+			expected: cookie.Comparison{
+				Before:        "2018-12-10",
+				After:         "2018-12-09",
+				BeforeWinners: []cookie.CookieCount{{Cookie: "B", Count: 3}},
+				AfterWinners:  []cookie.CookieCount{{Cookie: "A", Count: 2}},
+				Changes: []cookie.CookieChange{
+					{Cookie: "A", Before: 0, After: 2},
+					{Cookie: "D", Before: 1, After: 0},
+					{Cookie: "B", Before: 3, After: 1},
+				},
+			},
+		},
+		{
+			name:   "date without entries",
+			before: "2018-12-01",
+			after:  "2018-12-09",
+			expected: cookie.Comparison{
+				Before:        "2018-12-01",
+				After:         "2018-12-09",
+				BeforeWinners: []cookie.CookieCount{},
+				AfterWinners:  []cookie.CookieCount{{Cookie: "A", Count: 2}},
+				Changes: []cookie.CookieChange{
+					{Cookie: "A", Before: 0, After: 2},
+					{Cookie: "B", Before: 0, After: 1},
+					{Cookie: "C", Before: 0, After: 1},
+				},
+			},
+		},
+		{
+			name:          "same date twice",
+			before:        "2018-12-09",
+			after:         "2018-12-09",
+			errorContains: "cannot compare 2018-12-09 with itself",
+		},
+		{
+			name:          "invalid date",
+			before:        "2018-12-09",
+			after:         "12/10/2018",
+			errorContains: "invalid target date",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := cookie.NewProcessor(&sliceParser{entries: entries}, tt.opts...)
+
+			comparison, err := processor.CompareDates("test.csv", tt.before, tt.after)
+
+			if tt.errorContains != "" {
+				assert.ErrorContains(t, err, tt.errorContains, "error should contain expected substring")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, comparison, "comparison mismatch")
+		})
+	}
+}
@@ -0,0 +1,105 @@
+package cookie
+
+import "sort"
+
+// smallCounterLimit is how many distinct cookies are tallied in a slice before
+// switching to a map. Below it a linear scan is cheaper than hashing and avoids
+// the map allocation entirely; see BenchmarkProcessor_Cardinality.
+const smallCounterLimit = 8
+
+type tally struct {
+	cookie string
+	count  int
+}
+
+// cookieCounter counts cookie occurrences, starting with a small slice for the
+// common low-cardinality case and upgrading to a map once it grows.
+type cookieCounter struct {
+	small []tally
+	large map[string]int
+}
+
+func (c *cookieCounter) add(cookie string, n int) {
+	if c.large != nil {
+		c.large[cookie] += n
+		return
+	}
+
+	for i := range c.small {
+		if c.small[i].cookie == cookie {
+			c.small[i].count += n
+			return
+		}
+	}
+
+	if len(c.small) < smallCounterLimit {
+		c.small = append(c.small, tally{cookie: cookie, count: n})
+		return
+	}
+
+	c.large = make(map[string]int, 2*smallCounterLimit)
+	for _, t := range c.small {
+		c.large[t.cookie] = t.count
+	}
+	c.small = nil
+	c.large[cookie] += n
+}
+
+// each calls fn for every counted cookie, in no particular order.
+func (c *cookieCounter) each(fn func(cookie string, count int)) {
+	if c.large != nil {
+		for cookie, count := range c.large {
+			fn(cookie, count)
+		}
+		return
+	}
+	for _, t := range c.small {
+		fn(t.cookie, t.count)
+	}
+}
+
+// get returns the count of cookie, or zero if it was not counted.
+func (c *cookieCounter) get(cookie string) int {
+	if c.large != nil {
+		return c.large[cookie]
+	}
+	for _, t := range c.small {
+		if t.cookie == cookie {
+			return t.count
+		}
+	}
+	return 0
+}
+
+// len returns the number of distinct cookies counted so far.
+func (c *cookieCounter) len() int {
+	if c.large != nil {
+		return len(c.large)
+	}
+	return len(c.small)
+}
+
+// mostActive returns the alphabetically sorted cookies sharing the highest count.
+func (c *cookieCounter) mostActive() []string {
+	if c.large != nil {
+		return mostActive(c.large)
+	}
+	if len(c.small) == 0 {
+		return []string{}
+	}
+
+	var mostActiveCookies []string
+	maxCount := 0
+	for _, t := range c.small {
+		if t.count > maxCount {
+			maxCount = t.count
+			mostActiveCookies = []string{t.cookie}
+		} else if t.count == maxCount {
+			mostActiveCookies = append(mostActiveCookies, t.cookie)
+		}
+	}
+
+	sort.Strings(mostActiveCookies)
+
+	return mostActiveCookies
+}
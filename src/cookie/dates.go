@@ -0,0 +1,28 @@
+package cookie
+
+import (
+	"fmt"
+	"time"
+)
+
+// DatesBetween returns every YYYY-MM-DD date from start to end, both included,
+// stepping through the calendar so month ends and leap days are handled.
+func DatesBetween(start, end string) ([]string, error) {
+	first, err := time.Parse(isoDateLayout, start)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start date: expected YYYY-MM-DD, got '%s'", start)
+	}
+	last, err := time.Parse(isoDateLayout, end)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end date: expected YYYY-MM-DD, got '%s'", end)
+	}
+	if last.Before(first) {
+		return nil, fmt.Errorf("invalid date range %s..%s: end is before start", start, end)
+	}
+
+	dates := make([]string, 0, int(last.Sub(first).Hours()/24)+1)
+	for date := first; !date.After(last); date = date.AddDate(0, 0, 1) {
+		dates = append(dates, date.Format(isoDateLayout))
+	}
+	return dates, nil
+}
@@ -0,0 +1,74 @@
+package cookie_test
+
+import (
+	"testing"
+
+	"github.com/mfenderov/most-active-cookie/src/cookie"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDatesBetween(t *testing.T) {
+	tests := []struct {
+		name          string
+		start         string
+		end           string
+		expected      []string
+		errorContains string
+	}{
+		{
+			name:     "single day",
+			start:    "2018-12-09",
+			end:      "2018-12-09",
+			expected: []string{"2018-12-09"},
+		},
+		{
+			name:     "year boundary",
+			start:    "2018-12-30",
+			end:      "2019-01-02",
+			expected: []string{"2018-12-30", "2018-12-31", "2019-01-01", "2019-01-02"},
+		},
+		{
+			name:     "leap day",
+			start:    "2020-02-28",
+			end:      "2020-03-01",
+			expected: []string{"2020-02-28", "2020-02-29", "2020-03-01"},
+		},
+		{
+			name:     "non-leap february",
+			start:    "2019-02-28",
+			end:      "2019-03-01",
+			expected: []string{"2019-02-28", "2019-03-01"},
+		},
+		{
+			name:          "end before start",
+			start:         "2018-12-09",
+			end:           "2018-12-07",
+			errorContains: "end is before start",
+		},
+		{
+			name:          "invalid start",
+			start:         "2018-02-30",
+			end:           "2018-03-01",
+			errorContains: "invalid start date",
+		},
+		{
+			name:          "invalid end",
+			start:         "2018-12-07",
+			end:           "12/09/2018",
+			errorContains: "invalid end date",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dates, err := cookie.DatesBetween(tt.start, tt.end)
+
+			if tt.errorContains != "" {
+				assert.ErrorContains(t, err, tt.errorContains, "error should contain expected substring")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, dates, "dates mismatch")
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package cookie
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ColumnParser is a FileParser that can also hand over the value of another
+// named column with each entry, for queries across a second dimension.
+type ColumnParser interface {
+	FileParser
+	StreamFileColumn(filename, column string, processor func(entry LogEntry, value string) error) error
+}
+
+// DistinctValuesForWinner finds the most active cookie for targetDate and then,
+// in a second pass, counts the distinct non-empty values of column, such as a
+// region or user agent, among that cookie's target-date entries. It returns 0
+// when the date has no entries and an error when several cookies tie for most
+// active; WithTieBreak(TieBreakEarliest) settles ties. The parser must
+// implement ColumnParser.
+func (p *Processor) DistinctValuesForWinner(filename, targetDate, column string) (int, error) {
+	columnParser, ok := p.parser.(ColumnParser)
+	if !ok {
+		return 0, fmt.Errorf("parser %T does not read other columns", p.parser)
+	}
+
+	winners, err := p.FindMostActiveCookies(filename, targetDate)
+	if err != nil {
+		return 0, err
+	}
+	switch {
+	case len(winners) == 0:
+		return 0, nil
+	case len(winners) > 1:
+		return 0, fmt.Errorf("no unique winner on %s: %d cookies tied: %s", targetDate, len(winners), strings.Join(winners, ", "))
+	}
+	winner := winners[0]
+	targetDate, err = p.normalizeDate(targetDate)
+	if err != nil {
+		return 0, fmt.Errorf("invalid target date: %w", err)
+	}
+
+	values := make(map[string]struct{})
+	var value string
+	var process datedProcessor = func(entry LogEntry, entryDate string) error {
+		if entryDate > targetDate {
+			return ErrPastTargetDate
+		}
+		if entryDate == targetDate && entry.Cookie == winner && value != "" {
+			values[value] = struct{}{}
+		}
+		return nil
+	}
+	if p.window != nil {
+		process = p.window.filter(singleDate(targetDate), p.location, process)
+	}
+	scan := p.limitScan(dated(p.location, process))
+
+	err = columnParser.StreamFileColumn(filename, column, func(entry LogEntry, v string) error {
+		value = v
+		return scan(entry)
+	})
+	if err != nil && !errors.Is(err, ErrPastTargetDate) {
+		return 0, fmt.Errorf("failed to stream file: %w", err)
+	}
+	return len(values), nil
+}
@@ -0,0 +1,122 @@
+package cookie_test
+
+import (
+	"testing"
+
+	"github.com/mfenderov/most-active-cookie/src/cookie"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessor_DistinctValuesForWinner(t *testing.T) {
+	rows := []regionRow{
+		{cookie.LogEntry{Cookie: "A", Timestamp: "2018-12-09T14:19:00+00:00"}, "eu"},
+		{cookie.LogEntry{Cookie: "A", Timestamp: "2018-12-09T13:19:00+00:00"}, "us"},
+		{cookie.LogEntry{Cookie: "A", Timestamp: "2018-12-09T12:19:00+00:00"}, "eu"},
+		{cookie.LogEntry{Cookie: "A", Timestamp: "2018-12-09T11:19:00+00:00"}, ""},
+		{cookie.LogEntry{Cookie: "B", Timestamp: "2018-12-09T10:13:00+00:00"}, "apac"},
+		{cookie.LogEntry{Cookie: "A", Timestamp: "2018-12-08T10:13:00+00:00"}, "latam"},
+	}
+
+	tests := []struct {
+		name          string
+		rows          []regionRow
+		targetDate    string
+		opts          []cookie.Option
+		expected      int
+		errorContains string
+	}{
+		{
+			name:       "distinct values of the winner's rows",
+			rows:       rows,
+			targetDate: "2018-12-09",
+			expected:   2,
+		},
+		{
+			name:       "time-of-day window",
+			rows:       rows,
+			targetDate: "2018-12-09",
+			opts:       []cookie.Option{cookie.WithTimeOfDayWindow("12:00", "13:00")},
+			expected:   1,
+		},
+		{
+			name:       "no entries on the date",
+			rows:       rows,
+			targetDate: "2018-12-01",
+			expected:   0,
+		},
+		{
+			name:       "other dates are ignored",
+			rows:       rows,
+			targetDate: "2018-12-08",
+			expected:   1,
+		},
+		{
+			name: "tie without a tie-break",
+			rows: []regionRow{
+				{cookie.LogEntry{Cookie: "A", Timestamp: "2018-12-09T14:19:00+00:00"}, "eu"},
+				{cookie.LogEntry{Cookie: "B", Timestamp: "2018-12-09T13:19:00+00:00"}, "us"},
+			},
+			targetDate:    "2018-12-09",
+			errorContains: "no unique winner on 2018-12-09: 2 cookies tied: A, B",
+		},
+		{
+			name: "tie settled by the tie-break",
+			rows: []regionRow{
+				{cookie.LogEntry{Cookie: "A", Timestamp: "2018-12-09T14:19:00+00:00"}, "eu"},
+				{cookie.LogEntry{Cookie: "B", Timestamp: "2018-12-09T13:19:00+00:00"}, "us"},
+			},
+			targetDate: "2018-12-09",
+			opts:       []cookie.Option{cookie.WithTieBreak(cookie.TieBreakEarliest)},
+			expected:   1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := cookie.NewProcessor(&columnParser{rows: tt.rows}, tt.opts...)
+
+			distinct, err := processor.DistinctValuesForWinner("test.csv", tt.targetDate, "region")
+
+			if tt.errorContains != "" {
+				assert.ErrorContains(t, err, tt.errorContains, "error mismatch")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, distinct, "distinct values mismatch")
+		})
+	}
+
+	t.Run("parser without columns", func(t *testing.T) {
+		processor := cookie.NewProcessor(&sliceParser{})
+
+		_, err := processor.DistinctValuesForWinner("test.csv", "2018-12-09", "region")
+
+		assert.ErrorContains(t, err, "does not read other columns", "plain parsers have no other columns")
+	})
+}
+
+// regionRow is an entry with the value of its region column.
+type regionRow struct {
+	entry  cookie.LogEntry
+	region string
+}
+
+// columnParser serves rows with one extra column.
+type columnParser struct {
+	rows []regionRow
+}
+
+func (p *columnParser) StreamFile(filename string, processor cookie.EntryProcessor) error {
+	return p.StreamFileColumn(filename, "region", func(entry cookie.LogEntry, _ string) error {
+		return processor(entry)
+	})
+}
+
+func (p *columnParser) StreamFileColumn(_, _ string, processor func(cookie.LogEntry, string) error) error {
+	for _, row := range p.rows {
+		if err := processor(row.entry, row.region); err != nil {
+			return err
+		}
+	}
+	return nil
+}
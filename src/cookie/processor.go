@@ -1,52 +1,1093 @@
 package cookie
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"sort"
+	"strings"
 	"time"
 )
 
 type LogEntry struct {
 	Cookie    string
 	Timestamp string
+	// Time is the parsed timestamp when the parser resolved it (e.g. epoch
+	// timestamps). When zero, the date is taken from the Timestamp string.
+	Time time.Time
+	// Weight is the number of events the entry stands for, for batched logs.
+	// Zero counts as a single event.
+	Weight int
+}
+
+// weight returns how much the entry adds to its cookie's count.
+func (e LogEntry) weight() int {
+	if e.Weight > 0 {
+		return e.Weight
+	}
+	return 1
 }
 
 type EntryProcessor func(entry LogEntry) error
 
+// datedProcessor is an EntryProcessor also handed the entry's date in the
+// processor's location, so a chain of wrappers parses each timestamp once.
+type datedProcessor func(entry LogEntry, entryDate string) error
+
+// dated adapts next to an EntryProcessor, reading each entry's date in loc.
+func dated(loc *time.Location, next datedProcessor) EntryProcessor {
+	return func(entry LogEntry) error {
+		entryDate, err := entryDateOf(entry, loc)
+		if err != nil {
+			return err
+		}
+		return next(entry, entryDate)
+	}
+}
+
+// HourResult holds the most active cookie(s) within one hour of the target date.
+// Hours without entries have no cookies and a zero count.
+type HourResult struct {
+	Hour    int
+	Cookies []string
+	Count   int
+}
+
+// TieBreak selects how FindMostActiveCookies resolves cookies sharing the
+// highest count.
+type TieBreak int
+
+const (
+	// TieBreakAlphabetical returns every tied cookie, sorted alphabetically.
+	TieBreakAlphabetical TieBreak = iota
+	// TieBreakEarliest returns only the tied cookie whose first appearance on
+	// the target date has the earliest timestamp. Cookies first seen at the
+	// same instant fall back to alphabetical order.
+	TieBreakEarliest
+)
+
+// WinnerOrder selects how FindMostActiveCookies orders tied winners.
+type WinnerOrder int
+
+const (
+	// OrderAlphabetical sorts winners by cookie name.
+	OrderAlphabetical WinnerOrder = iota
+	// OrderFirstSeen lists winners in the order they first appear on the target
+	// date while streaming. For a given file this is deterministic; it follows
+	// file order, not timestamp order, if the file is unsorted.
+	OrderFirstSeen
+)
+
+// DateRange is the span of entry dates found in a file, as YYYY-MM-DD.
+type DateRange struct {
+	First   string
+	Last    string
+	Entries int
+}
+
+// Contains reports whether date (YYYY-MM-DD) lies within the range.
+func (r DateRange) Contains(date string) bool {
+	return r.Entries > 0 && date >= r.First && date <= r.Last
+}
+
+// DateRolloverHook receives the counts for a date once the scan moves on to a
+// different date. The hook takes ownership of the counts map.
+type DateRolloverHook func(date string, counts map[string]int)
+
 var ErrPastTargetDate = errors.New("past the target date")
 
+// ErrSkipEntry may be returned by an EntryProcessor to have the current entry
+// skipped while streaming continues.
+var ErrSkipEntry = errors.New("skip entry")
+
+// ErrUnsorted is returned by CheckSorted when an entry's date is earlier than
+// the date of an entry before it.
+var ErrUnsorted = errors.New("entries are not sorted by date")
+
+// isoDateLayout is the layout of the date portion of entry timestamps and the
+// internal form every target date is normalized to before comparison.
+const isoDateLayout = "2006-01-02"
+
 type FileParser interface {
 	StreamFile(filename string, processor EntryProcessor) error
 }
 
+// ScanStats summarizes one pass of a parser over a file.
+type ScanStats struct {
+	Lines        int  // lines read, header, comments and blank lines included
+	Entries      int  // entries handed to the processor and accepted
+	Blank        int  // blank lines skipped
+	Comments     int  // comment lines skipped
+	Skipped      int  // entries the processor skipped with ErrSkipEntry
+	Invalid      int  // malformed lines skipped instead of failing the scan
+	StoppedEarly bool // the scan stopped at the first entry past the target date
+	// MixedLineEndings reports that more than one of \n, \r\n and \r ended
+	// lines, as when files from different tools are concatenated.
+	MixedLineEndings bool
+	// Duplicates counts target-date entries repeating an earlier entry's
+	// cookie and timestamp, a sign of double logging. The processor fills it
+	// in when WithDuplicateTracking is set.
+	Duplicates int
+	// OutOfRangeYear counts entries rejected for a year outside the parser's
+	// valid range, whether skipped as invalid or failing the scan.
+	OutOfRangeYear int
+	// Warnings lists what the caller should know about a scan that still
+	// succeeded, in the order found. Parsers may cap the list; the counts
+	// above stay exact.
+	Warnings []Warning
+}
+
+// WarningCode identifies the kind of a Warning.
+type WarningCode string
+
+const (
+	// WarningInvalidLine is a malformed line skipped instead of failing.
+	WarningInvalidLine WarningCode = "invalid_line"
+	// WarningMixedLineEndings is a file ending lines in more than one style.
+	WarningMixedLineEndings WarningCode = "mixed_line_endings"
+	// WarningByteOrderMark is a UTF-8 byte order mark stripped from a line.
+	WarningByteOrderMark WarningCode = "byte_order_mark"
+	// WarningStoppedEarly is a scan of a sorted file ended before its end.
+	WarningStoppedEarly WarningCode = "stopped_early"
+)
+
+// Warning is a non-fatal finding of a scan, structured so a server can pass
+// it on, e.g. in a JSON response.
+type Warning struct {
+	Code    WarningCode `json:"code"`
+	Message string      `json:"message"`
+}
+
+// StatsParser is a FileParser that can also report what it read.
+type StatsParser interface {
+	FileParser
+	StreamFileStats(filename string, processor EntryProcessor) (ScanStats, error)
+}
+
+// ContextParser is a FileParser that can abandon a read once ctx is
+// cancelled, so a stalled input does not outlive the caller's deadline.
+type ContextParser interface {
+	FileParser
+	StreamFileContext(ctx context.Context, filename string, processor EntryProcessor) error
+}
+
 type Processor struct {
-	parser FileParser
+	parser       FileParser
+	dateLayout   string
+	cache        *resultCache
+	spillDir     string
+	spillAt      int
+	onRollover   DateRolloverHook
+	tieBreak     TieBreak
+	order        WinnerOrder
+	window       *timeWindow
+	optionErr    error
+	memoryBudget int64
+	location     *time.Location
+	sorted       bool
+	duplicates   bool
+	endExclusive bool
+	timeSpans    bool
+}
+
+// timeWindow is a half-open [start, end) range of minutes since midnight.
+type timeWindow struct {
+	start int
+	end   int
+}
+
+// Option configures a Processor.
+type Option func(*Processor)
+
+// WithDateLayout sets the Go time layout used to parse target dates, e.g.
+// "2006/01/02" or "02-01-2006". Target dates are normalized to YYYY-MM-DD
+// internally so they stay comparable with the date portion of entry timestamps.
+func WithDateLayout(layout string) Option {
+	return func(p *Processor) {
+		p.dateLayout = layout
+	}
+}
+
+// WithLocation buckets entries by their calendar date in loc instead of UTC, so
+// a team can ask for "2018-12-09" as their local day. Hours reported by
+// TopCookiePerHour and time windows are in loc too. A nil loc means UTC.
+func WithLocation(loc *time.Location) Option {
+	return func(p *Processor) {
+		if loc == nil {
+			loc = time.UTC
+		}
+		p.location = loc
+	}
+}
+
+// WithResultCache caches up to size FindMostActiveCookies results keyed by file,
+// target date, modification time and size, so repeated queries against an
+// unchanged file skip the scan. The cache is safe for concurrent use.
+func WithResultCache(size int) Option {
+	return func(p *Processor) {
+		if size > 0 {
+			p.cache = newResultCache(size)
+		}
+	}
 }
 
-func NewProcessor(parser FileParser) *Processor {
-	return &Processor{
-		parser: parser,
+// WithDiskSpill bounds the memory used by FindMostActiveOverall: once more than
+// threshold distinct cookies are held in memory, the counts are flushed to a
+// sorted temporary file in dir and merged from disk at the end of the scan.
+func WithDiskSpill(dir string, threshold int) Option {
+	return func(p *Processor) {
+		p.spillDir = dir
+		p.spillAt = threshold
 	}
 }
 
+// WithDateRolloverHook registers a hook fired during whole-file scans
+// (FindMostActiveOverall) each time the entry date changes, carrying the
+// completed date and its counts. On a date-sorted file every date is reported
+// exactly once; unsorted input yields one partial snapshot per contiguous run.
+func WithDateRolloverHook(hook DateRolloverHook) Option {
+	return func(p *Processor) {
+		p.onRollover = hook
+	}
+}
+
+// WithMemoryBudget aborts FindMostActiveCookies and FindMostActiveOverall with
+// ErrMemoryBudgetExceeded once the estimated size of the cookie counts exceeds
+// bytes, as a safety valve against untrusted inputs. The estimate is coarse and
+// checked periodically, so it should be set well below the real memory limit.
+// Zero, the default, means unlimited.
+func WithMemoryBudget(bytes int64) Option {
+	return func(p *Processor) {
+		p.memoryBudget = bytes
+	}
+}
+
+// WithTieBreak sets how ties for the highest count are resolved. The default,
+// TieBreakAlphabetical, returns all tied cookies.
+func WithTieBreak(tieBreak TieBreak) Option {
+	return func(p *Processor) {
+		p.tieBreak = tieBreak
+	}
+}
+
+// WithTimeOfDayWindow only counts target-date entries whose time of day, in
+// the processor's location (see WithLocation), falls within [start, end). Both
+// bounds use HH:MM.
+// Windows that wrap past midnight (start after end) are not supported and are
+// reported as an error when querying.
+func WithTimeOfDayWindow(start, end string) Option {
+	return func(p *Processor) {
+		startMinute, err := parseClock(start)
+		if err != nil {
+			p.optionErr = fmt.Errorf("invalid time-of-day window start: %w", err)
+			return
+		}
+		endMinute, err := parseClock(end)
+		if err != nil {
+			p.optionErr = fmt.Errorf("invalid time-of-day window end: %w", err)
+			return
+		}
+		if startMinute >= endMinute {
+			p.optionErr = fmt.Errorf("invalid time-of-day window %s-%s: start must be before end", start, end)
+			return
+		}
+		p.window = &timeWindow{start: startMinute, end: endMinute}
+	}
+}
+
+// WithSorted declares whether files are sorted by date in ascending order, as
+// CheckSorted verifies. Sorted files let date queries stop at the first entry
+// past the last date asked for instead of reading to the end. The default,
+// false, always scans the whole file: stopping early on an unsorted file
+// silently drops later entries for the target date.
+func WithSorted(sorted bool) Option {
+	return func(p *Processor) {
+		p.sorted = sorted
+	}
+}
+
+// WithWinnerOrder sets the order in which tied winners are returned. The default
+// is OrderAlphabetical.
+func WithWinnerOrder(order WinnerOrder) Option {
+	return func(p *Processor) {
+		p.order = order
+	}
+}
+
+// WithDuplicateTracking counts target-date entries that repeat a cookie and
+// timestamp already seen, reported as ScanStats.Duplicates. It is off by
+// default: every distinct cookie/timestamp pair on the target date is kept in
+// memory for the scan, which can be far more than the per-cookie counts.
+func WithDuplicateTracking() Option {
+	return func(p *Processor) {
+		p.duplicates = true
+	}
+}
+
+// WithEndExclusive makes FindMostActiveCookiesInRange treat its end date as
+// exclusive, counting [from, to) from midnight of from up to, but not
+// including, midnight of to, as time-bucketed systems usually expect. By
+// default the range is inclusive, like SQL BETWEEN, and runs to the end of to.
+func WithEndExclusive() Option {
+	return func(p *Processor) {
+		p.endExclusive = true
+	}
+}
+
+// WithTimeSpans fills in CookieCount.FirstSeen and LastSeen, the earliest and
+// latest timestamp at which each winner appeared on the target date, for
+// session-span analysis. It keeps two timestamps per cookie of the date for
+// the scan, so it is off by default.
+func WithTimeSpans() Option {
+	return func(p *Processor) {
+		p.timeSpans = true
+	}
+}
+
+// QueryOption adjusts a single query, leaving the Processor as it is, so one
+// shared processor can serve queries that need different settings.
+type QueryOption func(*Processor)
+
+// WithQuerySorted is WithSorted for one query: a request that knows its file
+// is sorted can stop early while others on the same processor scan in full.
+func WithQuerySorted(sorted bool) QueryOption {
+	return func(p *Processor) {
+		p.sorted = sorted
+	}
+}
+
+// forQuery returns p with opts applied to a copy, or p itself without opts.
+func (p *Processor) forQuery(opts []QueryOption) *Processor {
+	if len(opts) == 0 {
+		return p
+	}
+	q := *p
+	for _, opt := range opts {
+		opt(&q)
+	}
+	return &q
+}
+
+func NewProcessor(parser FileParser, opts ...Option) *Processor {
+	p := &Processor{
+		parser:     parser,
+		dateLayout: isoDateLayout,
+		location:   time.UTC,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
 func (p *Processor) FindMostActiveCookies(filename, targetDate string) ([]string, error) {
+	return p.FindMostActiveCookiesContext(context.Background(), filename, targetDate)
+}
+
+// FindMostActiveCookiesContext is FindMostActiveCookies stopping with ctx's
+// error once ctx is cancelled, so a long scan can be abandoned on a signal or
+// a cancelled request. opts apply to this query alone; a query with options
+// bypasses the result cache, which does not tell the settings apart.
+func (p *Processor) FindMostActiveCookiesContext(ctx context.Context, filename, targetDate string, opts ...QueryOption) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	useCache := p.cache != nil && len(opts) == 0
+	p = p.forQuery(opts)
+	return p.findMostActive(ctx, filename, targetDate, useCache, func(process EntryProcessor) error {
+		if parser, ok := p.parser.(ContextParser); ok {
+			return parser.StreamFileContext(ctx, filename, process)
+		}
+		return p.parser.StreamFile(filename, process)
+	})
+}
+
+// FindMostActiveCookiesWithStats is FindMostActiveCookies also returning the
+// parser's statistics for the scan, e.g. to confirm that a large import was
+// read in full. The parser must implement StatsParser. Results are never
+// served from the result cache, as a cached result has no statistics.
+func (p *Processor) FindMostActiveCookiesWithStats(filename, targetDate string) ([]string, ScanStats, error) {
+	count, stats, err := p.countDateStats(filename, targetDate)
+	if err != nil {
+		return nil, stats, err
+	}
+	return p.winners(count), stats, nil
+}
+
+// findMostActive runs a most-active query, streaming the file through stream.
+// useCache enables the result cache, when one is configured.
+func (p *Processor) findMostActive(ctx context.Context, filename, targetDate string, useCache bool, stream func(EntryProcessor) error) ([]string, error) {
 	if filename == "" {
 		return nil, fmt.Errorf("filename cannot be empty")
 	}
-	err := validateDate(targetDate)
+	if p.optionErr != nil {
+		return nil, p.optionErr
+	}
+	targetDate, err := p.normalizeDate(targetDate)
 	if err != nil {
 		return []string{}, fmt.Errorf("invalid target date: %w", err)
 	}
 
+	var key cacheKey
+	cacheable := false
+	if useCache {
+		key, cacheable = keyFor(filename, targetDate)
+	}
+	if cacheable {
+		if cached, ok := p.cache.get(key); ok {
+			return cached, nil
+		}
+	}
+
+	count, process := p.countTargetDate(targetDate)
+	err = stream(stopOnDone(ctx, process))
+	if err != nil && !errors.Is(err, ErrPastTargetDate) {
+		return nil, fmt.Errorf("failed to stream file: %w", err)
+	}
+	// A parser that ignores ctx may return cleanly after the deadline, e.g.
+	// from a read that stalled until then; its counts are not an answer.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	cookies := p.winners(count)
+	if cacheable {
+		p.cache.put(key, cookies)
+	}
+	return cookies, nil
+}
+
+// CountInto adds the target-date counts of filename to counts instead of a map
+// of its own, so a caller can run several files into one shared map or start
+// from pre-seeded counts. Rank the result with MostActive. Callers sharing
+// counts across goroutines must synchronize the calls themselves.
+func (p *Processor) CountInto(filename, targetDate string, counts map[string]int) error {
+	if filename == "" {
+		return fmt.Errorf("filename cannot be empty")
+	}
+	if counts == nil {
+		return fmt.Errorf("counts map cannot be nil")
+	}
+	if p.optionErr != nil {
+		return p.optionErr
+	}
+	targetDate, err := p.normalizeDate(targetDate)
+	if err != nil {
+		return fmt.Errorf("invalid target date: %w", err)
+	}
+
+	count, process := p.countTargetDate(targetDate)
+	count.held = len(counts)
+	err = p.parser.StreamFile(filename, process)
+	if err != nil && !errors.Is(err, ErrPastTargetDate) {
+		return fmt.Errorf("failed to stream file: %w", err)
+	}
+	count.counter.each(func(cookie string, n int) {
+		counts[cookie] += n
+	})
+	return nil
+}
+
+// dateCount holds what a single-date count collects: the counts and, when the
+// tie-break, winner order or time spans need them, each cookie's first
+// appearance.
+type dateCount struct {
+	counter    cookieCounter
+	spans      map[string]timeSpan
+	firstIndex map[string]int
+	seen       map[entryKey]struct{}
+	duplicates int
+	// held is how many distinct cookies the caller already keeps elsewhere,
+	// charged to the memory budget along with the counter.
+	held int
+}
+
+// countTargetDate builds the entry processor every single-date count runs on.
+// It adds target-date entries within the time window to the returned
+// dateCount, tracks first appearances as the options require, lets the scan
+// end past the date on sorted input and enforces the memory budget.
+func (p *Processor) countTargetDate(targetDate string) (*dateCount, EntryProcessor) {
+	count, track := p.trackTargetDate(targetDate)
+	process := p.enforceBudget(func() int { return count.held + count.counter.len() }, p.limitScan(dated(p.location, track)))
+	return count, process
+}
+
+// trackTargetDate is countTargetDate without the scan limit and memory budget,
+// for callers that count several dates in one scan and apply those once.
+func (p *Processor) trackTargetDate(targetDate string) (*dateCount, datedProcessor) {
+	return p.trackDates(singleDate(targetDate))
+}
+
+// trackDates is trackTargetDate counting every entry within dates as one.
+func (p *Processor) trackDates(dates dateSpan) (*dateCount, datedProcessor) {
+	count := &dateCount{}
+	process := processDateRange(dates, &count.counter)
+	if p.tieBreak == TieBreakEarliest || p.timeSpans {
+		count.spans = make(map[string]timeSpan)
+		process = trackTimeSpans(dates, p.location, count.spans, process)
+	}
+	if p.order == OrderFirstSeen {
+		count.firstIndex = make(map[string]int)
+		process = trackFirstIndex(dates, count.firstIndex, process)
+	}
+	if p.duplicates {
+		count.seen = make(map[entryKey]struct{})
+		process = trackDuplicates(dates, count, process)
+	}
+	if p.window != nil {
+		process = p.window.filter(dates, p.location, process)
+	}
+	return count, process
+}
+
+// winners returns the most active cookies of count, narrowed by the tie-break
+// and in the configured winner order.
+func (p *Processor) winners(count *dateCount) []string {
+	cookies := count.counter.mostActive()
+	if p.tieBreak == TieBreakEarliest {
+		cookies = earliest(cookies, count.spans)
+	}
+	if count.firstIndex != nil {
+		sort.SliceStable(cookies, func(i, j int) bool {
+			return count.firstIndex[cookies[i]] < count.firstIndex[cookies[j]]
+		})
+	}
+	return cookies
+}
+
+// FindMostActiveCookiesInFiles counts targetDate across every file in
+// filenames, such as the shards of one day's log, and returns the most active
+// cookie(s) of the combined counts, alphabetically. Each file is streamed, and
+// its header validated, on its own; a failure names the file it came from.
+func (p *Processor) FindMostActiveCookiesInFiles(filenames []string, targetDate string) ([]string, error) {
+	if len(filenames) == 0 {
+		return nil, fmt.Errorf("at least one filename is required")
+	}
+	if _, err := p.normalizeDate(targetDate); err != nil {
+		return nil, fmt.Errorf("invalid target date: %w", err)
+	}
+
+	counts := make(map[string]int)
+	for _, filename := range filenames {
+		if err := p.CountInto(filename, targetDate, counts); err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+	}
+	return MostActive(counts), nil
+}
+
+// FindMostActiveCookiesInRange returns the most active cookie(s) over every
+// entry dated from from to to inclusive, or up to midnight of to with
+// WithEndExclusive. Like single-date queries it honours the tie-break, winner
+// order and time-of-day window, and stops at the first entry past the range on
+// files declared sorted with WithSorted.
+func (p *Processor) FindMostActiveCookiesInRange(filename, from, to string) ([]string, error) {
+	if filename == "" {
+		return nil, fmt.Errorf("filename cannot be empty")
+	}
+	if p.optionErr != nil {
+		return nil, p.optionErr
+	}
+	from, err := p.normalizeDate(from)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start date: %w", err)
+	}
+	to, err = p.normalizeDate(to)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end date: %w", err)
+	}
+	if from > to {
+		return nil, fmt.Errorf("invalid date range: %s is after %s", from, to)
+	}
+
+	count, track := p.trackDates(dateSpan{from: from, to: to, includeTo: !p.endExclusive})
+	process := p.enforceBudget(count.counter.len, p.limitScan(dated(p.location, track)))
+
+	err = p.parser.StreamFile(filename, process)
+	if err != nil && !errors.Is(err, ErrPastTargetDate) {
+		return nil, fmt.Errorf("failed to stream file: %w", err)
+	}
+	return p.winners(count), nil
+}
+
+// FindMostActiveCookiesByDate answers several target dates in one pass over the
+// file, returning each date's most active cookie(s) keyed by the date as
+// given. Each date honours the tie-break, winner order and time-of-day window
+// like FindMostActiveCookies. On files declared sorted the scan stops at the
+// first entry past the latest date.
+func (p *Processor) FindMostActiveCookiesByDate(filename string, targetDates []string) (map[string][]string, error) {
+	normalized, counts, err := p.countDates(filename, targetDates)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string][]string, len(targetDates))
+	for i, targetDate := range targetDates {
+		results[targetDate] = p.winners(counts[normalized[i]])
+	}
+	return results, nil
+}
+
+// countDates counts every one of targetDates in a single pass over the file,
+// returning the dates normalized, in the order given, and each date's count
+// keyed by its normalized form.
+func (p *Processor) countDates(filename string, targetDates []string) ([]string, map[string]*dateCount, error) {
+	if filename == "" {
+		return nil, nil, fmt.Errorf("filename cannot be empty")
+	}
+	if len(targetDates) == 0 {
+		return nil, nil, fmt.Errorf("at least one target date is required")
+	}
+	if p.optionErr != nil {
+		return nil, nil, p.optionErr
+	}
+
+	normalized := make([]string, len(targetDates))
+	counts := make(map[string]*dateCount, len(targetDates))
+	processes := make(map[string]datedProcessor, len(targetDates))
+	last := ""
+	for i, targetDate := range targetDates {
+		date, err := p.normalizeDate(targetDate)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid target date: %w", err)
+		}
+		normalized[i] = date
+		if counts[date] == nil {
+			counts[date], processes[date] = p.trackTargetDate(date)
+		}
+		last = max(last, date)
+	}
+
+	process := dated(p.location, processDates(processes, last))
+	process = p.enforceBudget(func() int {
+		distinct := 0
+		for _, count := range counts {
+			distinct += count.counter.len()
+		}
+		return distinct
+	}, p.limitScan(process))
+
+	err := p.parser.StreamFile(filename, process)
+	if err != nil && !errors.Is(err, ErrPastTargetDate) {
+		return nil, nil, fmt.Errorf("failed to stream file: %w", err)
+	}
+	return normalized, counts, nil
+}
+
+// MostActive returns the alphabetically sorted cookies sharing the highest
+// count in counts, e.g. after one or more CountInto calls.
+func MostActive(counts map[string]int) []string {
+	return mostActive(counts)
+}
+
+// FindMostActiveOverall returns the most active cookie(s) across every entry in
+// the file, regardless of date. Because no date is targeted, the whole file is
+// always scanned.
+func (p *Processor) FindMostActiveOverall(filename string) ([]string, error) {
+	if filename == "" {
+		return nil, fmt.Errorf("filename cannot be empty")
+	}
+
+	if p.spillDir != "" && p.spillAt > 0 {
+		return p.findMostActiveOverallSpilling(filename)
+	}
+
 	cookieCounts := make(map[string]int)
-	err = p.parser.StreamFile(filename, processLogEntry(targetDate, cookieCounts))
+	process, flush := p.observeRollover(func(entry LogEntry) error {
+		cookieCounts[entry.Cookie] += entry.weight()
+		return nil
+	})
+	process = p.enforceBudget(func() int { return len(cookieCounts) }, process)
+	err := p.parser.StreamFile(filename, process)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stream file: %w", err)
+	}
+	flush()
+
+	return mostActive(cookieCounts), nil
+}
+
+// FindCookieActiveOnMostDays returns the cookie(s) seen on the most distinct
+// dates across the whole file, alphabetically when tied. How often a cookie
+// appears on a given date does not matter.
+func (p *Processor) FindCookieActiveOnMostDays(filename string) ([]string, error) {
+	if filename == "" {
+		return nil, fmt.Errorf("filename cannot be empty")
+	}
+
+	activeDays := make(map[string]map[string]struct{})
+	err := p.parser.StreamFile(filename, func(entry LogEntry) error {
+		entryDate, err := entryDateOf(entry, p.location)
+		if err != nil {
+			return err
+		}
+		days, ok := activeDays[entry.Cookie]
+		if !ok {
+			days = make(map[string]struct{})
+			activeDays[entry.Cookie] = days
+		}
+		days[entryDate] = struct{}{}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to stream file: %w", err)
+	}
+
+	dayCounts := make(map[string]int, len(activeDays))
+	for cookie, days := range activeDays {
+		dayCounts[cookie] = len(days)
+	}
+	return mostActive(dayCounts), nil
+}
+
+// CookieDailyCounts returns how often cookieID appears on each date across the
+// whole file, keyed by YYYY-MM-DD. Only that cookie is counted, so memory grows
+// with the dates it was seen on rather than with the file. Dates without any
+// entry for the cookie are absent from the map.
+func (p *Processor) CookieDailyCounts(filename, cookieID string) (map[string]int, error) {
+	if filename == "" {
+		return nil, fmt.Errorf("filename cannot be empty")
+	}
+	if cookieID == "" {
+		return nil, fmt.Errorf("cookie ID cannot be empty")
+	}
+
+	dailyCounts := make(map[string]int)
+	err := p.parser.StreamFile(filename, func(entry LogEntry) error {
+		if entry.Cookie != cookieID {
+			return nil
+		}
+		entryDate, err := entryDateOf(entry, p.location)
+		if err != nil {
+			return err
+		}
+		dailyCounts[entryDate] += entry.weight()
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to stream file: %w", err)
+	}
+	return dailyCounts, nil
+}
+
+func (p *Processor) findMostActiveOverallSpilling(filename string) ([]string, error) {
+	counter := newSpillCounter(p.spillDir, p.spillAt)
+	defer counter.cleanup()
+
+	process, flush := p.observeRollover(func(entry LogEntry) error {
+		return counter.add(entry.Cookie, entry.weight())
+	})
+	err := p.parser.StreamFile(filename, process)
+	if err != nil {
+		return nil, fmt.Errorf("failed to stream file: %w", err)
+	}
+	flush()
+
+	cookies, err := counter.mostActive()
+	if err != nil {
+		return nil, fmt.Errorf("failed to merge spilled counts: %w", err)
+	}
+	if cookies == nil {
+		return []string{}, nil
+	}
+	return cookies, nil
+}
+
+// TopCookiePerHour returns the most active cookie(s) for each of the 24 hours
+// of the target date. Hours are read in the processor's location (see
+// WithLocation), the same one entry dates are bucketed in. Hours outside the
+// time-of-day window, if any, have no winners. Each hour lists all its tied
+// cookies alphabetically, so the tie-break and winner order options are
+// rejected.
+func (p *Processor) TopCookiePerHour(filename, targetDate string) ([]HourResult, error) {
+	if filename == "" {
+		return nil, fmt.Errorf("filename cannot be empty")
+	}
+	if err := p.checkPerHour(); err != nil {
+		return nil, err
+	}
+	targetDate, err := p.normalizeDate(targetDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target date: %w", err)
+	}
+
+	var hourlyCounts [24]map[string]int
+	for hour := range hourlyCounts {
+		hourlyCounts[hour] = make(map[string]int)
+	}
+
+	var process datedProcessor = func(entry LogEntry, entryDate string) error {
+		if entryDate > targetDate {
+			return ErrPastTargetDate
+		}
+		if entryDate != targetDate {
+			return nil
+		}
+
+		timestamp, err := entryTimeOf(entry, p.location)
+		if err != nil {
+			return err
+		}
+		hourlyCounts[timestamp.Hour()][entry.Cookie] += entry.weight()
+		return nil
+	}
+	if p.window != nil {
+		process = p.window.filter(singleDate(targetDate), p.location, process)
+	}
+	held := func() int {
+		total := 0
+		for _, cookieCounts := range hourlyCounts {
+			total += len(cookieCounts)
+		}
+		return total
+	}
+	err = p.parser.StreamFile(filename, p.enforceBudget(held, p.limitScan(dated(p.location, process))))
 	if err != nil && !errors.Is(err, ErrPastTargetDate) {
 		return nil, fmt.Errorf("failed to stream file: %w", err)
 	}
 
+	results := make([]HourResult, 0, len(hourlyCounts))
+	for hour, cookieCounts := range hourlyCounts {
+		cookies := mostActive(cookieCounts)
+		count := 0
+		if len(cookies) > 0 {
+			count = cookieCounts[cookies[0]]
+		}
+		results = append(results, HourResult{Hour: hour, Cookies: cookies, Count: count})
+	}
+
+	return results, nil
+}
+
+// checkPerHour reports options that TopCookiePerHour cannot honour.
+func (p *Processor) checkPerHour() error {
+	switch {
+	case p.optionErr != nil:
+		return p.optionErr
+	case p.tieBreak != TieBreakAlphabetical:
+		return fmt.Errorf("per-hour winners cannot break ties by first appearance")
+	case p.order != OrderAlphabetical:
+		return fmt.Errorf("per-hour winners cannot be ordered by first appearance")
+	}
+	return nil
+}
+
+// DateRange scans the whole file and returns the earliest and latest entry
+// dates it contains. Unlike the date queries it does not rely on sort order.
+func (p *Processor) DateRange(filename string) (DateRange, error) {
+	if filename == "" {
+		return DateRange{}, fmt.Errorf("filename cannot be empty")
+	}
+
+	var dates DateRange
+	err := p.parser.StreamFile(filename, func(entry LogEntry) error {
+		entryDate, err := entryDateOf(entry, p.location)
+		if err != nil {
+			return err
+		}
+		if dates.Entries == 0 || entryDate < dates.First {
+			dates.First = entryDate
+		}
+		if dates.Entries == 0 || entryDate > dates.Last {
+			dates.Last = entryDate
+		}
+		dates.Entries++
+		return nil
+	})
+	if err != nil {
+		return DateRange{}, fmt.Errorf("failed to stream file: %w", err)
+	}
+	return dates, nil
+}
+
+// CheckSorted streams the whole file and returns an error wrapping ErrUnsorted
+// at the first entry dated before its predecessor. Only files that pass this
+// check may be declared sorted with WithSorted.
+func (p *Processor) CheckSorted(filename string) error {
+	if filename == "" {
+		return fmt.Errorf("filename cannot be empty")
+	}
+
+	previous := ""
+	err := p.parser.StreamFile(filename, func(entry LogEntry) error {
+		entryDate, err := entryDateOf(entry, p.location)
+		if err != nil {
+			return err
+		}
+		if entryDate < previous {
+			return fmt.Errorf("%w: %s follows %s", ErrUnsorted, entryDate, previous)
+		}
+		previous = entryDate
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to stream file: %w", err)
+	}
+	return nil
+}
+
+// observeRollover wraps next so the configured rollover hook sees per-date
+// counts. The returned flush reports the final date once streaming is done.
+// Without a hook, next is returned unchanged.
+func (p *Processor) observeRollover(next EntryProcessor) (EntryProcessor, func()) {
+	if p.onRollover == nil {
+		return next, func() {}
+	}
+
+	currentDate := ""
+	dailyCounts := make(map[string]int)
+	flush := func() {
+		if len(dailyCounts) > 0 {
+			p.onRollover(currentDate, dailyCounts)
+			dailyCounts = make(map[string]int)
+		}
+	}
+
+	process := func(entry LogEntry) error {
+		entryDate, err := entryDateOf(entry, p.location)
+		if err != nil {
+			return err
+		}
+		if entryDate != currentDate {
+			flush()
+			currentDate = entryDate
+		}
+		if err := next(entry); err != nil {
+			return err
+		}
+		dailyCounts[entry.Cookie] += entry.weight()
+		return nil
+	}
+	return process, flush
+}
+
+// timeSpan is the earliest and latest timestamp a cookie was seen at.
+type timeSpan struct {
+	first time.Time
+	last  time.Time
+}
+
+// trackTimeSpans wraps next to record the earliest and latest timestamp of
+// every cookie seen within dates.
+func trackTimeSpans(dates dateSpan, loc *time.Location, spans map[string]timeSpan, next datedProcessor) datedProcessor {
+	return func(entry LogEntry, entryDate string) error {
+		if err := next(entry, entryDate); err != nil {
+			return err
+		}
+
+		if !dates.contains(entryDate) {
+			return nil
+		}
+		timestamp, err := entryTimeOf(entry, loc)
+		if err != nil {
+			return err
+		}
+		span, ok := spans[entry.Cookie]
+		if !ok || timestamp.Before(span.first) {
+			span.first = timestamp
+		}
+		if !ok || timestamp.After(span.last) {
+			span.last = timestamp
+		}
+		spans[entry.Cookie] = span
+		return nil
+	}
+}
+
+// filter wraps next so entries within dates but outside the window are
+// ignored. Entries from other dates pass through so the early-break still
+// applies.
+func (w *timeWindow) filter(dates dateSpan, loc *time.Location, next datedProcessor) datedProcessor {
+	return func(entry LogEntry, entryDate string) error {
+		if !dates.contains(entryDate) {
+			return next(entry, entryDate)
+		}
+
+		timestamp, err := entryTimeOf(entry, loc)
+		if err != nil {
+			return err
+		}
+		minute := timestamp.Hour()*60 + timestamp.Minute()
+		if minute < w.start || minute >= w.end {
+			return nil
+		}
+		return next(entry, entryDate)
+	}
+}
+
+// parseClock converts HH:MM into minutes since midnight.
+func parseClock(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("expected HH:MM, got '%s'", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// trackFirstIndex wraps next to record the order in which cookies first appear
+// within dates.
+func trackFirstIndex(dates dateSpan, firstIndex map[string]int, next datedProcessor) datedProcessor {
+	return func(entry LogEntry, entryDate string) error {
+		if err := next(entry, entryDate); err != nil {
+			return err
+		}
+
+		if !dates.contains(entryDate) {
+			return nil
+		}
+		if _, ok := firstIndex[entry.Cookie]; !ok {
+			firstIndex[entry.Cookie] = len(firstIndex)
+		}
+		return nil
+	}
+}
+
+// entryKey identifies an entry by what a double-logged entry repeats.
+type entryKey struct {
+	cookie    string
+	timestamp string
+}
+
+// trackDuplicates wraps next to count the entries within dates whose cookie
+// and timestamp were already seen into count.duplicates.
+func trackDuplicates(dates dateSpan, count *dateCount, next datedProcessor) datedProcessor {
+	return func(entry LogEntry, entryDate string) error {
+		if err := next(entry, entryDate); err != nil {
+			return err
+		}
+
+		if !dates.contains(entryDate) {
+			return nil
+		}
+		key := entryKey{cookie: entry.Cookie, timestamp: entry.Timestamp}
+		if _, ok := count.seen[key]; ok {
+			count.duplicates++
+			return nil
+		}
+		count.seen[key] = struct{}{}
+		return nil
+	}
+}
+
+// earliest narrows alphabetically sorted cookies to the one first seen earliest.
+func earliest(cookies []string, spans map[string]timeSpan) []string {
+	if len(cookies) <= 1 {
+		return cookies
+	}
+
+	winner := cookies[0]
+	for _, cookie := range cookies[1:] {
+		if spans[cookie].first.Before(spans[winner].first) {
+			winner = cookie
+		}
+	}
+	return []string{winner}
+}
+
+// mostActive returns the alphabetically sorted cookies sharing the highest count.
+func mostActive(cookieCounts map[string]int) []string {
 	if len(cookieCounts) == 0 {
-		return []string{}, nil
+		return []string{}
 	}
 
 	var mostActiveCookies []string
@@ -63,37 +1104,159 @@ func (p *Processor) FindMostActiveCookies(filename, targetDate string) ([]string
 
 	sort.Strings(mostActiveCookies)
 
-	return mostActiveCookies, nil
+	return mostActiveCookies
 }
 
-func validateDate(targetDate string) error {
+// normalizeDate parses targetDate with the configured layout and returns it in
+// YYYY-MM-DD form.
+func (p *Processor) normalizeDate(targetDate string) (string, error) {
 	if targetDate == "" {
-		return fmt.Errorf("the target date cannot be empty")
+		return "", fmt.Errorf("the target date cannot be empty")
 	}
 
-	if _, err := time.Parse("2006-01-02", targetDate); err != nil {
-		return fmt.Errorf("invalid target date: expected YYYY-MM-DD, got '%s'", targetDate)
+	date, err := time.Parse(p.dateLayout, targetDate)
+	if err != nil {
+		expected := "YYYY-MM-DD"
+		if p.dateLayout != isoDateLayout {
+			expected = fmt.Sprintf("layout '%s'", p.dateLayout)
+		}
+		return "", fmt.Errorf("invalid target date: expected %s, got '%s'", expected, targetDate)
 	}
-	return nil
+	return date.Format(isoDateLayout), nil
 }
 
-func processLogEntry(targetDate string, cookieCounts map[string]int) func(entry LogEntry) error {
+// cancelCheckInterval is how many entries stopOnDone passes on between checks
+// for cancellation.
+const cancelCheckInterval = 1024
+
+// stopOnDone wraps next to fail with ctx's error, checked every
+// cancelCheckInterval entries, once ctx is cancelled. Contexts that can never
+// be cancelled leave next unwrapped.
+func stopOnDone(ctx context.Context, next EntryProcessor) EntryProcessor {
+	if ctx.Done() == nil {
+		return next
+	}
+
+	seen := 0
 	return func(entry LogEntry) error {
-		timestamp := entry.Timestamp
-		if len(timestamp) < 10 {
-			return fmt.Errorf("timestamp too short: %s", timestamp)
+		seen++
+		if seen%cancelCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
 		}
+		return next(entry)
+	}
+}
 
-		entryDate := timestamp[:10]
+// processDateRange counts the entries within dates only: earlier dates are
+// skipped without touching the counter and a later date returns
+// ErrPastTargetDate, so memory grows with the range's cookies alone. Whether
+// that error ends the scan is up to limitScan.
+func processDateRange(dates dateSpan, counter *cookieCounter) datedProcessor {
+	return func(entry LogEntry, entryDate string) error {
+		if dates.past(entryDate) {
+			return ErrPastTargetDate
+		}
 
-		if entryDate > targetDate {
+		if entryDate >= dates.from {
+			counter.add(entry.Cookie, entry.weight())
+		}
+
+		return nil
+	}
+}
+
+// dateSpan is the range of entry dates a count covers: from to to inclusive,
+// or up to but excluding to unless includeTo.
+type dateSpan struct {
+	from      string
+	to        string
+	includeTo bool
+}
+
+// singleDate is the dateSpan of date alone.
+func singleDate(date string) dateSpan {
+	return dateSpan{from: date, to: date, includeTo: true}
+}
+
+// contains reports whether date is within s.
+func (s dateSpan) contains(date string) bool {
+	return date >= s.from && !s.past(date)
+}
+
+// past reports whether date is after s.
+func (s dateSpan) past(date string) bool {
+	return date > s.to || (date == s.to && !s.includeTo)
+}
+
+// limitScan decides what ErrPastTargetDate from next means. On a file sorted by
+// date in ascending order, every entry after the first one past the target is
+// past it too, so the error is passed on and the parser stops reading. Without
+// that guarantee a target-date entry may still follow, so the error is dropped
+// and the scan reads the whole file.
+func (p *Processor) limitScan(next EntryProcessor) EntryProcessor {
+	if p.sorted {
+		return next
+	}
+	return func(entry LogEntry) error {
+		if err := next(entry); !errors.Is(err, ErrPastTargetDate) {
+			return err
+		}
+		return nil
+	}
+}
+
+// processDates is processDateRange for several target dates at once: each entry
+// is handed to its date's processor, if any, and the scan ends past last.
+func processDates(processes map[string]datedProcessor, last string) datedProcessor {
+	return func(entry LogEntry, entryDate string) error {
+		if entryDate > last {
 			return ErrPastTargetDate
 		}
 
-		if entryDate == targetDate {
-			cookieCounts[entry.Cookie]++
+		if process := processes[entryDate]; process != nil {
+			return process(entry, entryDate)
 		}
 
 		return nil
 	}
 }
+
+// entryDateOf returns the YYYY-MM-DD date of an entry in loc, preferring the
+// parsed Time over the raw timestamp. UTC timestamps bucketed in UTC use their
+// date prefix without parsing; others are converted to loc first, so
+// 2018-12-08T23:30:00-05:00 falls on 2018-12-09 in UTC.
+func entryDateOf(entry LogEntry, loc *time.Location) (string, error) {
+	if !entry.Time.IsZero() {
+		return entry.Time.In(loc).Format(isoDateLayout), nil
+	}
+
+	timestamp := entry.Timestamp
+	if len(timestamp) < 10 {
+		return "", fmt.Errorf("timestamp too short: %s", timestamp)
+	}
+	if loc == time.UTC && (strings.HasSuffix(timestamp, "+00:00") || strings.HasSuffix(timestamp, "Z")) {
+		return timestamp[:10], nil
+	}
+
+	local, err := entryTimeOf(entry, loc)
+	if err != nil {
+		return "", err
+	}
+	return local.Format(isoDateLayout), nil
+}
+
+// entryTimeOf returns the full timestamp of an entry in loc, parsing the raw
+// RFC3339 string when the parser did not resolve it.
+func entryTimeOf(entry LogEntry, loc *time.Location) (time.Time, error) {
+	if !entry.Time.IsZero() {
+		return entry.Time.In(loc), nil
+	}
+
+	timestamp, err := time.Parse(time.RFC3339, entry.Timestamp)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp %s: %w", entry.Timestamp, err)
+	}
+	return timestamp.In(loc), nil
+}
@@ -1,8 +1,12 @@
 package cookie_test
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/mfenderov/most-active-cookie/src/cookie"
 	"github.com/stretchr/testify/assert"
@@ -68,6 +72,15 @@ func TestProcessor_FindMostActiveCookies(t *testing.T) {
 			targetDate:     "2018-12-09",
 			expectedResult: []string{"A", "B"},
 		},
+		{
+			name: "parsed entry time takes precedence over the raw timestamp",
+			entries: []cookie.LogEntry{
+				{Cookie: "A", Timestamp: "1544364000000", Time: time.Date(2018, 12, 9, 14, 0, 0, 0, time.UTC)},
+				{Cookie: "B", Timestamp: "1544277600000", Time: time.Date(2018, 12, 8, 14, 0, 0, 0, time.UTC)},
+			},
+			targetDate:     "2018-12-09",
+			expectedResult: []string{"A"},
+		},
 		{
 			name:          "invalid target date format",
 			targetDate:    "invalid-date",
@@ -114,3 +127,1389 @@ func TestProcessor_FindMostActiveCookies_ParserError(t *testing.T) {
 	assert.Error(t, err, "expected error from parser")
 	assert.Contains(t, err.Error(), "failed to stream file", "error should mention streaming failure")
 }
+
+func TestProcessor_FindMostActiveCookiesContext(t *testing.T) {
+	t.Run("cancelled mid-scan", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		streamed := 0
+		mockParser := cookie.NewMockFileParser(t)
+		mockParser.EXPECT().StreamFile("test.csv", mock.AnythingOfType("cookie.EntryProcessor")).RunAndReturn(func(_ string, processor cookie.EntryProcessor) error {
+			for range 100000 {
+				streamed++
+				if streamed == 100 {
+					cancel()
+				}
+				if err := processor(cookie.LogEntry{Cookie: "A", Timestamp: "2018-12-09T14:19:00+00:00"}); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		processor := cookie.NewProcessor(mockParser)
+
+		_, err := processor.FindMostActiveCookiesContext(ctx, "test.csv", "2018-12-09")
+
+		assert.ErrorIs(t, err, context.Canceled, "cancellation should be reported")
+		assert.Less(t, streamed, 2048, "the scan should stop soon after cancellation")
+	})
+
+	t.Run("cancelled before the scan", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		processor := cookie.NewProcessor(cookie.NewMockFileParser(t))
+
+		_, err := processor.FindMostActiveCookiesContext(ctx, "test.csv", "2018-12-09")
+
+		assert.ErrorIs(t, err, context.Canceled, "a cancelled context should not start a scan")
+	})
+
+	t.Run("stalled read honouring the context", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		processor := cookie.NewProcessor(&blockingParser{})
+
+		_, err := processor.FindMostActiveCookiesContext(ctx, "test.csv", "2018-12-09")
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded, "the parser should be abandoned at the deadline")
+	})
+
+	t.Run("stalled read ignoring the context", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		mockParser := cookie.NewMockFileParser(t)
+		mockParser.EXPECT().StreamFile("test.csv", mock.AnythingOfType("cookie.EntryProcessor")).RunAndReturn(func(string, cookie.EntryProcessor) error {
+			<-ctx.Done()
+			return nil
+		})
+		processor := cookie.NewProcessor(mockParser)
+
+		_, err := processor.FindMostActiveCookiesContext(ctx, "test.csv", "2018-12-09")
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded, "a scan ending past the deadline should not succeed")
+	})
+}
+
+func TestProcessor_FindMostActiveCookiesContext_QueryOptions(t *testing.T) {
+	// Unsorted: a scan stopping at the first 2018-12-10 entry misses B.
+	entries := []cookie.LogEntry{
+		{Cookie: "A", Timestamp: "2018-12-09T10:00:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-10T10:00:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T11:00:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T12:00:00+00:00"},
+	}
+
+	tests := []struct {
+		name     string
+		opts     []cookie.Option
+		query    []cookie.QueryOption
+		expected []string
+	}{
+		{
+			name:     "processor default scans in full",
+			expected: []string{"B"},
+		},
+		{
+			name:     "sorted query stops early",
+			query:    []cookie.QueryOption{cookie.WithQuerySorted(true)},
+			expected: []string{"A"},
+		},
+		{
+			name:     "unsorted query on a sorted processor scans in full",
+			opts:     []cookie.Option{cookie.WithSorted(true)},
+			query:    []cookie.QueryOption{cookie.WithQuerySorted(false)},
+			expected: []string{"B"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := cookie.NewProcessor(&sliceParser{entries: entries}, tt.opts...)
+
+			cookies, err := processor.FindMostActiveCookiesContext(context.Background(), "test.csv", "2018-12-09", tt.query...)
+
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, cookies, "result mismatch")
+		})
+	}
+
+	t.Run("the processor keeps its own setting", func(t *testing.T) {
+		processor := cookie.NewProcessor(&sliceParser{entries: entries})
+
+		_, err := processor.FindMostActiveCookiesContext(context.Background(), "test.csv", "2018-12-09", cookie.WithQuerySorted(true))
+		assert.NoError(t, err, "unexpected error")
+		cookies, err := processor.FindMostActiveCookies("test.csv", "2018-12-09")
+
+		assert.NoError(t, err, "unexpected error")
+		assert.Equal(t, []string{"B"}, cookies, "a query option should not change later queries")
+	})
+}
+
+func TestProcessor_FindMostActiveCookiesWithStats(t *testing.T) {
+	entries := []cookie.LogEntry{
+		{Cookie: "A", Timestamp: "2018-12-08T14:19:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T14:19:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T15:19:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-10T07:25:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-10T08:25:00+00:00"},
+	}
+
+	t.Run("stats from the parser", func(t *testing.T) {
+		processor := cookie.NewProcessor(&statsParser{sliceParser{entries: entries}}, cookie.WithSorted(true), cookie.WithResultCache(4))
+
+		cookies, stats, err := processor.FindMostActiveCookiesWithStats("test.csv", "2018-12-09")
+
+		assert.NoError(t, err, "unexpected error")
+		assert.Equal(t, []string{"B"}, cookies, "result mismatch")
+		assert.Equal(t, cookie.ScanStats{Lines: 4, Entries: 3, StoppedEarly: true}, stats, "stats mismatch")
+	})
+
+	t.Run("duplicate tracking", func(t *testing.T) {
+		duplicated := append(slices.Clone(entries),
+			cookie.LogEntry{Cookie: "B", Timestamp: "2018-12-09T14:19:00+00:00"},
+			cookie.LogEntry{Cookie: "B", Timestamp: "2018-12-09T14:19:00+00:00"},
+			cookie.LogEntry{Cookie: "A", Timestamp: "2018-12-08T14:19:00+00:00"},
+		)
+		processor := cookie.NewProcessor(&statsParser{sliceParser{entries: duplicated}}, cookie.WithDuplicateTracking())
+
+		_, stats, err := processor.FindMostActiveCookiesWithStats("test.csv", "2018-12-09")
+
+		assert.NoError(t, err, "unexpected error")
+		assert.Equal(t, 2, stats.Duplicates, "only repeats on the target date should count")
+	})
+
+	t.Run("duplicates untracked by default", func(t *testing.T) {
+		processor := cookie.NewProcessor(&statsParser{sliceParser{entries: append(slices.Clone(entries), entries[1])}})
+
+		_, stats, err := processor.FindMostActiveCookiesWithStats("test.csv", "2018-12-09")
+
+		assert.NoError(t, err, "unexpected error")
+		assert.Zero(t, stats.Duplicates, "duplicates should only be counted when tracked")
+	})
+
+	t.Run("parser without stats", func(t *testing.T) {
+		processor := cookie.NewProcessor(&sliceParser{entries: entries})
+
+		_, _, err := processor.FindMostActiveCookiesWithStats("test.csv", "2018-12-09")
+
+		assert.ErrorContains(t, err, "does not report scan statistics", "plain parsers have no stats")
+	})
+}
+
+func TestProcessor_FindMostActiveOverall(t *testing.T) {
+	entries := []cookie.LogEntry{
+		{Cookie: "A", Timestamp: "2018-12-07T14:19:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-08T10:13:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T10:13:00+00:00"},
+		{Cookie: "A", Timestamp: "2018-12-10T07:25:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-11T07:25:00+00:00"},
+	}
+
+	mockParser := cookie.NewMockFileParser(t)
+	mockParser.EXPECT().StreamFile("test.csv", mock.AnythingOfType("cookie.EntryProcessor")).Run(func(_ string, processor cookie.EntryProcessor) {
+		for _, entry := range entries {
+			assert.NoError(t, processor(entry), "overall processing should never stop early")
+		}
+	}).Return(nil)
+	processor := cookie.NewProcessor(mockParser)
+
+	cookies, err := processor.FindMostActiveOverall("test.csv")
+
+	assert.NoError(t, err, "unexpected error")
+	assert.Equal(t, []string{"B"}, cookies, "result mismatch")
+}
+
+func TestProcessor_FindCookieActiveOnMostDays(t *testing.T) {
+	tests := []struct {
+		name     string
+		entries  []cookie.LogEntry
+		expected []string
+	}{
+		{
+			name: "distinct days beat volume",
+			entries: []cookie.LogEntry{
+				{Cookie: "A", Timestamp: "2018-12-07T14:19:00+00:00"},
+				{Cookie: "B", Timestamp: "2018-12-07T15:19:00+00:00"},
+				{Cookie: "B", Timestamp: "2018-12-07T16:19:00+00:00"},
+				{Cookie: "B", Timestamp: "2018-12-07T17:19:00+00:00"},
+				{Cookie: "A", Timestamp: "2018-12-08T10:13:00+00:00"},
+				{Cookie: "A", Timestamp: "2018-12-09T10:13:00+00:00"},
+				{Cookie: "B", Timestamp: "2018-12-09T11:13:00+00:00"},
+			},
+			expected: []string{"A"},
+		},
+		{
+			name: "ties sorted alphabetically",
+			entries: []cookie.LogEntry{
+				{Cookie: "C", Timestamp: "2018-12-07T14:19:00+00:00"},
+				{Cookie: "A", Timestamp: "2018-12-07T15:19:00+00:00"},
+				{Cookie: "B", Timestamp: "2018-12-07T16:19:00+00:00"},
+				{Cookie: "C", Timestamp: "2018-12-08T10:13:00+00:00"},
+				{Cookie: "A", Timestamp: "2018-12-09T10:13:00+00:00"},
+			},
+			expected: []string{"A", "C"},
+		},
+		{
+			name: "offsets bucketed by UTC date",
+			entries: []cookie.LogEntry{
+				{Cookie: "A", Timestamp: "2018-12-08T23:30:00-05:00"},
+				{Cookie: "A", Timestamp: "2018-12-09T10:13:00+00:00"},
+				{Cookie: "B", Timestamp: "2018-12-08T10:13:00+00:00"},
+				{Cookie: "B", Timestamp: "2018-12-09T10:13:00+00:00"},
+			},
+			expected: []string{"B"},
+		},
+		{
+			name:     "empty file",
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := cookie.NewProcessor(&sliceParser{entries: tt.entries})
+
+			cookies, err := processor.FindCookieActiveOnMostDays("test.csv")
+
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, cookies, "result mismatch")
+		})
+	}
+}
+
+func TestProcessor_CookieDailyCounts(t *testing.T) {
+	entries := []cookie.LogEntry{
+		{Cookie: "A", Timestamp: "2018-12-07T14:19:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-07T15:19:00+00:00"},
+		{Cookie: "A", Timestamp: "2018-12-07T16:19:00+00:00"},
+		{Cookie: "A", Timestamp: "2018-12-08T23:30:00-05:00", Weight: 3},
+		{Cookie: "B", Timestamp: "2018-12-09T11:13:00+00:00"},
+		{Cookie: "a", Timestamp: "2018-12-10T11:13:00+00:00"},
+	}
+
+	tests := []struct {
+		name          string
+		cookieID      string
+		expected      map[string]int
+		errorContains string
+	}{
+		{
+			name:     "counts per date",
+			cookieID: "A",
+			expected: map[string]int{"2018-12-07": 2, "2018-12-09": 3},
+		},
+		{
+			name:     "unknown cookie",
+			cookieID: "Z",
+			expected: map[string]int{},
+		},
+		{
+			name:          "empty cookie ID",
+			errorContains: "cookie ID cannot be empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := cookie.NewProcessor(&sliceParser{entries: entries})
+
+			counts, err := processor.CookieDailyCounts("test.csv", tt.cookieID)
+
+			if tt.errorContains != "" {
+				assert.ErrorContains(t, err, tt.errorContains, "error mismatch")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, counts, "counts mismatch")
+		})
+	}
+}
+
+func TestProcessor_FindMostActiveCookies_MidnightBoundaries(t *testing.T) {
+	midnight := func(day int) time.Time {
+		return time.Date(2018, time.December, day, 0, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		entries  []cookie.LogEntry
+		expected []string
+	}{
+		{
+			name: "timestamp strings",
+			entries: []cookie.LogEntry{
+				{Cookie: "Before", Timestamp: "2018-12-08T23:59:59+00:00"},
+				{Cookie: "Start", Timestamp: "2018-12-09T00:00:00+00:00"},
+				{Cookie: "End", Timestamp: "2018-12-09T23:59:59+00:00"},
+				{Cookie: "Next", Timestamp: "2018-12-10T00:00:00+00:00"},
+				{Cookie: "Next", Timestamp: "2018-12-10T00:00:01+00:00"},
+			},
+			expected: []string{"End", "Start"},
+		},
+		{
+			name: "offsets crossing midnight in UTC",
+			entries: []cookie.LogEntry{
+				{Cookie: "Before", Timestamp: "2018-12-09T00:30:00+01:00"},
+				{Cookie: "Start", Timestamp: "2018-12-08T23:30:00-05:00"},
+				{Cookie: "Start", Timestamp: "2018-12-09T05:00:00+05:00"},
+				{Cookie: "End", Timestamp: "2018-12-10T05:29:59+05:30"},
+				{Cookie: "Next", Timestamp: "2018-12-09T19:00:00-05:00"},
+			},
+			expected: []string{"Start"},
+		},
+		{
+			name: "parsed UTC times",
+			entries: []cookie.LogEntry{
+				{Cookie: "Before", Timestamp: "1544313599", Time: midnight(9).Add(-time.Second)},
+				{Cookie: "Start", Timestamp: "1544313600", Time: midnight(9)},
+				{Cookie: "End", Timestamp: "1544399999", Time: midnight(10).Add(-time.Second)},
+				{Cookie: "Next", Timestamp: "1544400000", Time: midnight(10)},
+				{Cookie: "Next", Timestamp: "1544400001", Time: midnight(10).Add(time.Second)},
+			},
+			expected: []string{"End", "Start"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := cookie.NewProcessor(&sliceParser{entries: tt.entries})
+
+			cookies, err := processor.FindMostActiveCookies("test.csv", "2018-12-09")
+
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, cookies, "midnight belongs to the day it starts")
+		})
+	}
+}
+
+func TestProcessor_FindMostActiveCookies_Location(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+
+	entries := []cookie.LogEntry{
+		{Cookie: "A", Timestamp: "2018-12-09T03:00:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T14:00:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-10T02:00:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-10T04:59:59+00:00"},
+		{Cookie: "D", Timestamp: "2018-12-10T05:00:00+00:00"},
+	}
+
+	tests := []struct {
+		name     string
+		opts     []cookie.Option
+		expected []string
+	}{
+		{
+			name:     "UTC by default",
+			expected: []string{"A", "B"},
+		},
+		{
+			name:     "nil location means UTC",
+			opts:     []cookie.Option{cookie.WithLocation(nil)},
+			expected: []string{"A", "B"},
+		},
+		{
+			name:     "New York day",
+			opts:     []cookie.Option{cookie.WithLocation(newYork)},
+			expected: []string{"C"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := cookie.NewProcessor(&sliceParser{entries: entries}, tt.opts...)
+
+			cookies, err := processor.FindMostActiveCookies("test.csv", "2018-12-09")
+
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, cookies, "result mismatch")
+		})
+	}
+}
+
+// TestProcessor_FindMostActiveCookiesWithCounts_LocationWrappers reads offset
+// timestamps in another location through the window, winner order and time
+// span tracking at once, all of which bucket entries by the same date.
+func TestProcessor_FindMostActiveCookiesWithCounts_LocationWrappers(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("timezone data unavailable: %v", err)
+	}
+
+	entries := []cookie.LogEntry{
+		{Cookie: "A", Timestamp: "2018-12-09T04:30:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T09:00:00-05:00"},
+		{Cookie: "A", Timestamp: "2018-12-09T10:00:00-05:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T10:00:00-05:00"},
+		{Cookie: "A", Timestamp: "2018-12-09T16:00:00+00:00"},
+		{Cookie: "A", Timestamp: "2018-12-09T20:00:00-05:00"},
+		{Cookie: "C", Timestamp: "2018-12-09T22:30:00+00:00"},
+	}
+	processor := cookie.NewProcessor(&sliceParser{entries: entries},
+		cookie.WithLocation(newYork),
+		cookie.WithTimeOfDayWindow("08:00", "18:00"),
+		cookie.WithWinnerOrder(cookie.OrderFirstSeen),
+		cookie.WithTimeSpans())
+
+	counts, err := processor.FindMostActiveCookiesWithCounts("test.csv", "2018-12-09")
+
+	assert.NoError(t, err, "unexpected error")
+	assert.Equal(t, []cookie.CookieCount{
+		{Cookie: "B", Count: 2, FirstSeen: time.Date(2018, 12, 9, 9, 0, 0, 0, newYork), LastSeen: time.Date(2018, 12, 9, 10, 0, 0, 0, newYork)},
+		{Cookie: "A", Count: 2, FirstSeen: time.Date(2018, 12, 9, 10, 0, 0, 0, newYork), LastSeen: time.Date(2018, 12, 9, 11, 0, 0, 0, newYork)},
+	}, counts, "entries should be bucketed and windowed in New York")
+}
+
+func TestProcessor_FindMostActiveCookiesInRange(t *testing.T) {
+	entries := []cookie.LogEntry{
+		{Cookie: "A", Timestamp: "2018-12-07T14:19:00+00:00"},
+		{Cookie: "A", Timestamp: "2018-12-07T15:19:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-08T10:13:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-09T10:13:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T11:13:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-10T07:25:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-10T08:25:00+00:00"},
+	}
+
+	tests := []struct {
+		name          string
+		from          string
+		to            string
+		expected      []string
+		errorContains string
+	}{
+		{
+			name:     "inclusive range",
+			from:     "2018-12-08",
+			to:       "2018-12-09",
+			expected: []string{"B"},
+		},
+		{
+			name:     "single day",
+			from:     "2018-12-07",
+			to:       "2018-12-07",
+			expected: []string{"A"},
+		},
+		{
+			name:     "whole file",
+			from:     "2018-12-01",
+			to:       "2018-12-31",
+			expected: []string{"C"},
+		},
+		{
+			name:     "no entries in range",
+			from:     "2018-12-11",
+			to:       "2018-12-12",
+			expected: []string{},
+		},
+		{
+			name:          "reversed range",
+			from:          "2018-12-09",
+			to:            "2018-12-08",
+			errorContains: "2018-12-09 is after 2018-12-08",
+		},
+		{
+			name:          "invalid end date",
+			from:          "2018-12-08",
+			to:            "12/09/2018",
+			errorContains: "invalid end date",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := cookie.NewProcessor(&sliceParser{entries: entries})
+
+			cookies, err := processor.FindMostActiveCookiesInRange("test.csv", tt.from, tt.to)
+
+			if tt.errorContains != "" {
+				assert.ErrorContains(t, err, tt.errorContains, "error should contain expected substring")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, cookies, "result mismatch")
+		})
+	}
+}
+
+func TestProcessor_FindMostActiveCookiesInRange_Options(t *testing.T) {
+	entries := []cookie.LogEntry{
+		{Cookie: "D", Timestamp: "2018-12-08T10:00:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-08T11:00:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-09T09:00:00+00:00"},
+		{Cookie: "D", Timestamp: "2018-12-09T12:00:00+00:00"},
+		{Cookie: "A", Timestamp: "2018-12-10T10:00:00+00:00"},
+	}
+
+	tests := []struct {
+		name          string
+		opts          []cookie.Option
+		expected      []string
+		errorContains string
+	}{
+		{
+			name:     "ties alphabetically by default",
+			expected: []string{"C", "D"},
+		},
+		{
+			name:     "first-seen order",
+			opts:     []cookie.Option{cookie.WithWinnerOrder(cookie.OrderFirstSeen)},
+			expected: []string{"D", "C"},
+		},
+		{
+			name:     "earliest tie-break",
+			opts:     []cookie.Option{cookie.WithTieBreak(cookie.TieBreakEarliest)},
+			expected: []string{"D"},
+		},
+		{
+			name:     "time-of-day window on every date",
+			opts:     []cookie.Option{cookie.WithTimeOfDayWindow("08:00", "11:30")},
+			expected: []string{"C"},
+		},
+		{
+			name:          "invalid window",
+			opts:          []cookie.Option{cookie.WithTimeOfDayWindow("25:00", "26:00")},
+			errorContains: "invalid time-of-day window start",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := cookie.NewProcessor(&sliceParser{entries: entries}, tt.opts...)
+
+			cookies, err := processor.FindMostActiveCookiesInRange("test.csv", "2018-12-08", "2018-12-09")
+
+			if tt.errorContains != "" {
+				assert.ErrorContains(t, err, tt.errorContains, "error should contain expected substring")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, cookies, "result mismatch")
+		})
+	}
+}
+
+func TestProcessor_FindMostActiveCookiesInRange_EndExclusive(t *testing.T) {
+	entries := []cookie.LogEntry{
+		{Cookie: "A", Timestamp: "2018-12-08T10:00:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-08T23:59:59+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T00:00:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T00:00:00+00:00"},
+		{Cookie: "A", Timestamp: "2018-12-10T00:00:00+00:00"},
+	}
+
+	tests := []struct {
+		name     string
+		opts     []cookie.Option
+		from     string
+		to       string
+		expected []string
+	}{
+		{
+			name:     "inclusive end counts entries at midnight of the end date",
+			from:     "2018-12-08",
+			to:       "2018-12-09",
+			expected: []string{"B"},
+		},
+		{
+			name:     "exclusive end stops at midnight of the end date",
+			opts:     []cookie.Option{cookie.WithEndExclusive()},
+			from:     "2018-12-08",
+			to:       "2018-12-09",
+			expected: []string{"A", "B"},
+		},
+		{
+			name:     "exclusive end keeps the last second before midnight",
+			opts:     []cookie.Option{cookie.WithEndExclusive()},
+			from:     "2018-12-09",
+			to:       "2018-12-10",
+			expected: []string{"B"},
+		},
+		{
+			name:     "exclusive range of a single date is empty",
+			opts:     []cookie.Option{cookie.WithEndExclusive()},
+			from:     "2018-12-09",
+			to:       "2018-12-09",
+			expected: []string{},
+		},
+		{
+			name:     "exclusive end on a sorted file",
+			opts:     []cookie.Option{cookie.WithEndExclusive(), cookie.WithSorted(true)},
+			from:     "2018-12-08",
+			to:       "2018-12-10",
+			expected: []string{"B"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := cookie.NewProcessor(&sliceParser{entries: entries}, tt.opts...)
+
+			cookies, err := processor.FindMostActiveCookiesInRange("test.csv", tt.from, tt.to)
+
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, cookies, "result mismatch")
+		})
+	}
+}
+
+func TestProcessor_FindMostActiveCookiesByDate(t *testing.T) {
+	entries := []cookie.LogEntry{
+		{Cookie: "A", Timestamp: "2018-12-07T14:19:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-08T10:13:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-09T10:13:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-09T11:13:00+00:00"},
+		{Cookie: "D", Timestamp: "2018-12-09T12:13:00+00:00"},
+		{Cookie: "E", Timestamp: "2018-12-10T07:25:00+00:00"},
+	}
+	processor := cookie.NewProcessor(&sliceParser{entries: entries})
+
+	results, err := processor.FindMostActiveCookiesByDate("test.csv", []string{"2018-12-09", "2018-12-07", "2018-12-01"})
+
+	assert.NoError(t, err, "unexpected error")
+	assert.Equal(t, map[string][]string{
+		"2018-12-09": {"C"},
+		"2018-12-07": {"A"},
+		"2018-12-01": {},
+	}, results, "each date should get its own winners")
+
+	_, err = processor.FindMostActiveCookiesByDate("test.csv", []string{"2018-12-09", "12/08/2018"})
+	assert.ErrorContains(t, err, "invalid target date", "every date should be validated")
+}
+
+func TestProcessor_FindMostActiveCookiesByDate_Options(t *testing.T) {
+	entries := []cookie.LogEntry{
+		{Cookie: "B", Timestamp: "2018-12-09T14:19:00+00:00"},
+		{Cookie: "A", Timestamp: "2018-12-09T10:13:00+00:00"},
+		{Cookie: "D", Timestamp: "2018-12-08T20:13:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-08T10:13:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-08T09:13:00+00:00"},
+	}
+
+	tests := []struct {
+		name     string
+		opts     []cookie.Option
+		expected map[string][]string
+	}{
+		{
+			name: "first-seen order",
+			opts: []cookie.Option{cookie.WithWinnerOrder(cookie.OrderFirstSeen)},
+			expected: map[string][]string{
+				"2018-12-09": {"B", "A"},
+				"2018-12-08": {"C"},
+			},
+		},
+		{
+			name: "earliest tie-break",
+			opts: []cookie.Option{cookie.WithTieBreak(cookie.TieBreakEarliest)},
+			expected: map[string][]string{
+				"2018-12-09": {"A"},
+				"2018-12-08": {"C"},
+			},
+		},
+		{
+			name: "time-of-day window",
+			opts: []cookie.Option{cookie.WithTimeOfDayWindow("12:00", "23:00")},
+			expected: map[string][]string{
+				"2018-12-09": {"B"},
+				"2018-12-08": {"D"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := cookie.NewProcessor(&sliceParser{entries: entries}, tt.opts...)
+
+			results, err := processor.FindMostActiveCookiesByDate("test.csv", []string{"2018-12-09", "2018-12-08"})
+
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, results, "each date should honour the options")
+		})
+	}
+}
+
+func TestProcessor_WeightedEntries(t *testing.T) {
+	entries := []cookie.LogEntry{
+		{Cookie: "A", Timestamp: "2018-12-09T14:19:00+00:00"},
+		{Cookie: "A", Timestamp: "2018-12-09T13:19:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T10:13:00+00:00", Weight: 3},
+	}
+	processor := cookie.NewProcessor(&sliceParser{entries: entries})
+
+	cookies, err := processor.FindMostActiveCookies("test.csv", "2018-12-09")
+	assert.NoError(t, err, "unexpected error")
+	assert.Equal(t, []string{"B"}, cookies, "a weight of 3 should outrank two single events")
+
+	overall, err := processor.FindMostActiveOverall("test.csv")
+	assert.NoError(t, err, "unexpected error")
+	assert.Equal(t, []string{"B"}, overall, "whole-file counts should honor weights")
+}
+
+func TestProcessor_CheckSorted(t *testing.T) {
+	tests := []struct {
+		name     string
+		entries  []cookie.LogEntry
+		unsorted bool
+	}{
+		{
+			name: "ascending dates",
+			entries: []cookie.LogEntry{
+				{Cookie: "A", Timestamp: "2018-12-07T14:19:00+00:00"},
+				{Cookie: "B", Timestamp: "2018-12-08T10:13:00+00:00"},
+				{Cookie: "C", Timestamp: "2018-12-08T09:13:00+00:00"},
+			},
+		},
+		{
+			name: "date goes backwards",
+			entries: []cookie.LogEntry{
+				{Cookie: "A", Timestamp: "2018-12-09T14:19:00+00:00"},
+				{Cookie: "B", Timestamp: "2018-12-08T10:13:00+00:00"},
+			},
+			unsorted: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := cookie.NewProcessor(&sliceParser{entries: tt.entries})
+
+			err := processor.CheckSorted("test.csv")
+
+			if tt.unsorted {
+				assert.ErrorIs(t, err, cookie.ErrUnsorted, "out-of-order dates should be reported")
+				assert.ErrorContains(t, err, "2018-12-08 follows 2018-12-09", "error should name both dates")
+				return
+			}
+			assert.NoError(t, err, "times within a date need not be ordered")
+		})
+	}
+}
+
+func TestProcessor_WithSorted(t *testing.T) {
+	// B wins 2018-12-09 only if the entries after the first one from
+	// 2018-12-10 are read.
+	unsorted := []cookie.LogEntry{
+		{Cookie: "A", Timestamp: "2018-12-09T14:19:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T15:19:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-10T07:25:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T16:19:00+00:00"},
+		{Cookie: "A", Timestamp: "2018-12-08T16:19:00+00:00"},
+	}
+
+	tests := []struct {
+		name     string
+		opts     []cookie.Option
+		expected []string
+	}{
+		{
+			name:     "whole file scanned by default",
+			expected: []string{"B"},
+		},
+		{
+			name:     "declared unsorted",
+			opts:     []cookie.Option{cookie.WithSorted(false)},
+			expected: []string{"B"},
+		},
+		{
+			name:     "declared sorted stops at the next date",
+			opts:     []cookie.Option{cookie.WithSorted(true)},
+			expected: []string{"A", "B"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := cookie.NewProcessor(&sliceParser{entries: unsorted}, tt.opts...)
+
+			cookies, err := processor.FindMostActiveCookies("test.csv", "2018-12-09")
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, cookies, "FindMostActiveCookies mismatch")
+
+			top, err := processor.FindTopCookies("test.csv", "2018-12-09", 1)
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected[:1], top, "FindTopCookies mismatch")
+
+			byDate, err := processor.FindMostActiveCookiesByDate("test.csv", []string{"2018-12-09"})
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, byDate["2018-12-09"], "FindMostActiveCookiesByDate mismatch")
+		})
+	}
+}
+
+func TestProcessor_CountInto(t *testing.T) {
+	monday := &sliceParser{entries: []cookie.LogEntry{
+		{Cookie: "A", Timestamp: "2018-12-09T14:19:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T15:19:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-10T07:25:00+00:00"},
+	}}
+	tuesday := &sliceParser{entries: []cookie.LogEntry{
+		{Cookie: "B", Timestamp: "2018-12-09T23:19:00+00:00"},
+	}}
+
+	counts := map[string]int{"A": 1}
+	assert.NoError(t, cookie.NewProcessor(monday).CountInto("monday.csv", "2018-12-09", counts), "unexpected error")
+	assert.NoError(t, cookie.NewProcessor(tuesday).CountInto("tuesday.csv", "2018-12-09", counts), "unexpected error")
+
+	assert.Equal(t, map[string]int{"A": 2, "B": 2}, counts, "counts should accumulate onto the seeded map")
+	assert.Equal(t, []string{"A", "B"}, cookie.MostActive(counts), "ranking mismatch")
+
+	err := cookie.NewProcessor(monday).CountInto("monday.csv", "2018-12-09", nil)
+	assert.ErrorContains(t, err, "counts map cannot be nil", "a nil map cannot be written to")
+}
+
+func TestProcessor_CountInto_MatchesFindMostActiveCookies(t *testing.T) {
+	entries := []cookie.LogEntry{
+		{Cookie: "A", Timestamp: "2018-12-09T08:19:00+00:00"},
+		{Cookie: "A", Timestamp: "2018-12-09T09:19:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T12:19:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-10T07:25:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T13:19:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T14:19:00+00:00"},
+	}
+
+	tests := []struct {
+		name string
+		opts []cookie.Option
+	}{
+		{name: "defaults"},
+		{name: "time window", opts: []cookie.Option{cookie.WithTimeOfDayWindow("08:00", "13:00")}},
+		{name: "sorted", opts: []cookie.Option{cookie.WithSorted(true)}},
+		{name: "sorted with time window", opts: []cookie.Option{cookie.WithSorted(true), cookie.WithTimeOfDayWindow("08:00", "13:00")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := cookie.NewProcessor(&sliceParser{entries: entries}, tt.opts...)
+
+			counts := make(map[string]int)
+			assert.NoError(t, processor.CountInto("test.csv", "2018-12-09", counts), "unexpected error")
+			cookies, err := processor.FindMostActiveCookies("test.csv", "2018-12-09")
+			assert.NoError(t, err, "unexpected error")
+
+			assert.Equal(t, cookies, cookie.MostActive(counts), "both paths should count the same entries")
+		})
+	}
+}
+
+func TestProcessor_FindMostActiveCookiesInFiles(t *testing.T) {
+	shards := map[string][]cookie.LogEntry{
+		"part-1.csv": {
+			{Cookie: "A", Timestamp: "2018-12-09T14:19:00+00:00"},
+			{Cookie: "B", Timestamp: "2018-12-09T15:19:00+00:00"},
+		},
+		"part-2.csv": {
+			{Cookie: "B", Timestamp: "2018-12-09T16:19:00+00:00"},
+			{Cookie: "A", Timestamp: "2018-12-10T07:25:00+00:00"},
+		},
+	}
+	streamShard := func(filename string, processor cookie.EntryProcessor) error {
+		for _, entry := range shards[filename] {
+			if err := processor(entry); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	t.Run("counts combined across files", func(t *testing.T) {
+		mockParser := cookie.NewMockFileParser(t)
+		mockParser.EXPECT().StreamFile("part-1.csv", mock.Anything).RunAndReturn(streamShard)
+		mockParser.EXPECT().StreamFile("part-2.csv", mock.Anything).RunAndReturn(streamShard)
+
+		cookies, err := cookie.NewProcessor(mockParser).FindMostActiveCookiesInFiles([]string{"part-1.csv", "part-2.csv"}, "2018-12-09")
+
+		assert.NoError(t, err, "unexpected error")
+		assert.Equal(t, []string{"B"}, cookies, "counts from both files should be combined")
+	})
+
+	t.Run("failing file is named", func(t *testing.T) {
+		mockParser := cookie.NewMockFileParser(t)
+		mockParser.EXPECT().StreamFile("part-1.csv", mock.Anything).RunAndReturn(streamShard)
+		mockParser.EXPECT().StreamFile("missing.csv", mock.Anything).Return(errors.New("file does not exist"))
+
+		_, err := cookie.NewProcessor(mockParser).FindMostActiveCookiesInFiles([]string{"part-1.csv", "missing.csv"}, "2018-12-09")
+
+		assert.ErrorContains(t, err, "missing.csv: failed to stream file: file does not exist", "the error should name the failing file")
+	})
+
+	t.Run("no files", func(t *testing.T) {
+		_, err := cookie.NewProcessor(cookie.NewMockFileParser(t)).FindMostActiveCookiesInFiles(nil, "2018-12-09")
+
+		assert.ErrorContains(t, err, "at least one filename is required", "an empty file list should be rejected")
+	})
+}
+
+func TestProcessor_DateRange(t *testing.T) {
+	entries := []cookie.LogEntry{
+		{Cookie: "A", Timestamp: "2018-12-09T14:19:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-07T10:13:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-08T07:25:00+00:00"},
+	}
+	processor := cookie.NewProcessor(&sliceParser{entries: entries})
+
+	dates, err := processor.DateRange("test.csv")
+
+	assert.NoError(t, err, "unexpected error")
+	assert.Equal(t, cookie.DateRange{First: "2018-12-07", Last: "2018-12-09", Entries: 3}, dates, "range mismatch")
+	assert.True(t, dates.Contains("2018-12-08"), "date inside the range should be contained")
+	assert.False(t, dates.Contains("2020-01-01"), "date after the range should not be contained")
+	assert.False(t, cookie.DateRange{}.Contains("2018-12-08"), "an empty range contains no dates")
+}
+
+func TestProcessor_FindMostActiveCookies_DateLayout(t *testing.T) {
+	entries := []cookie.LogEntry{
+		{Cookie: "A", Timestamp: "2018-12-08T14:19:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T10:13:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T11:13:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-09T12:13:00+00:00"},
+	}
+
+	tests := []struct {
+		name           string
+		layout         string
+		targetDate     string
+		expectedResult []string
+		expectError    bool
+	}{
+		{
+			name:           "slash separated layout",
+			layout:         "2006/01/02",
+			targetDate:     "2018/12/09",
+			expectedResult: []string{"B"},
+		},
+		{
+			name:           "day first layout",
+			layout:         "02-01-2006",
+			targetDate:     "08-12-2018",
+			expectedResult: []string{"A"},
+		},
+		{
+			name:        "date not matching layout",
+			layout:      "2006/01/02",
+			targetDate:  "2018-12-09",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockParser := cookie.NewMockFileParser(t)
+			if !tt.expectError {
+				mockParser.EXPECT().StreamFile("test.csv", mock.AnythingOfType("cookie.EntryProcessor")).Run(func(_ string, processor cookie.EntryProcessor) {
+					for _, entry := range entries {
+						if processor(entry) != nil {
+							return
+						}
+					}
+				}).Return(nil)
+			}
+			processor := cookie.NewProcessor(mockParser, cookie.WithDateLayout(tt.layout))
+
+			cookies, err := processor.FindMostActiveCookies("test.csv", tt.targetDate)
+
+			if tt.expectError {
+				assert.ErrorContains(t, err, "invalid target date", "expected layout mismatch error")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expectedResult, cookies, "result mismatch")
+		})
+	}
+}
+
+func TestProcessor_TopCookiePerHour(t *testing.T) {
+	entries := []cookie.LogEntry{
+		{Cookie: "A", Timestamp: "2018-12-08T09:19:00+00:00"},
+		{Cookie: "A", Timestamp: "2018-12-09T09:05:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T09:15:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T09:45:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-09T23:59:00+00:00"},
+		{Cookie: "D", Timestamp: "2018-12-09T23:30:00+00:00"},
+		{Cookie: "E", Timestamp: "2018-12-10T00:10:00+00:00"},
+	}
+
+	mockParser := cookie.NewMockFileParser(t)
+	mockParser.EXPECT().StreamFile("test.csv", mock.AnythingOfType("cookie.EntryProcessor")).Run(func(_ string, processor cookie.EntryProcessor) {
+		for _, entry := range entries {
+			if processor(entry) != nil {
+				return
+			}
+		}
+	}).Return(nil)
+	processor := cookie.NewProcessor(mockParser)
+
+	results, err := processor.TopCookiePerHour("test.csv", "2018-12-09")
+
+	assert.NoError(t, err, "unexpected error")
+	assert.Len(t, results, 24, "every hour of the day should be reported")
+	assert.Equal(t, cookie.HourResult{Hour: 0, Cookies: []string{}, Count: 0}, results[0], "empty hour mismatch")
+	assert.Equal(t, cookie.HourResult{Hour: 9, Cookies: []string{"B"}, Count: 2}, results[9], "09:00 hour mismatch")
+	assert.Equal(t, cookie.HourResult{Hour: 23, Cookies: []string{"C", "D"}, Count: 1}, results[23], "23:00 hour mismatch")
+}
+
+func TestProcessor_TopCookiePerHour_Options(t *testing.T) {
+	entries := []cookie.LogEntry{
+		{Cookie: "B", Timestamp: "2018-12-09T09:05:00+00:00"},
+		{Cookie: "A", Timestamp: "2018-12-09T09:15:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-09T23:30:00+00:00"},
+	}
+
+	tests := []struct {
+		name          string
+		opts          []cookie.Option
+		errorContains string
+	}{
+		{
+			name:          "invalid window",
+			opts:          []cookie.Option{cookie.WithTimeOfDayWindow("10:00", "09:00")},
+			errorContains: "start must be before end",
+		},
+		{
+			name:          "earliest tie-break",
+			opts:          []cookie.Option{cookie.WithTieBreak(cookie.TieBreakEarliest)},
+			errorContains: "per-hour winners cannot break ties by first appearance",
+		},
+		{
+			name:          "first-seen order",
+			opts:          []cookie.Option{cookie.WithWinnerOrder(cookie.OrderFirstSeen)},
+			errorContains: "per-hour winners cannot be ordered by first appearance",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := cookie.NewProcessor(&sliceParser{entries: entries}, tt.opts...)
+
+			_, err := processor.TopCookiePerHour("test.csv", "2018-12-09")
+
+			assert.ErrorContains(t, err, tt.errorContains, "error should contain expected substring")
+		})
+	}
+
+	t.Run("time-of-day window", func(t *testing.T) {
+		processor := cookie.NewProcessor(&sliceParser{entries: entries}, cookie.WithTimeOfDayWindow("09:10", "23:00"))
+
+		results, err := processor.TopCookiePerHour("test.csv", "2018-12-09")
+
+		assert.NoError(t, err, "unexpected error")
+		assert.Equal(t, cookie.HourResult{Hour: 9, Cookies: []string{"A"}, Count: 1}, results[9], "entries before the window should not count")
+		assert.Equal(t, cookie.HourResult{Hour: 23, Cookies: []string{}, Count: 0}, results[23], "entries after the window should not count")
+	})
+}
+
+func TestProcessor_DateRolloverHook(t *testing.T) {
+	entries := []cookie.LogEntry{
+		{Cookie: "A", Timestamp: "2018-12-07T14:19:00+00:00"},
+		{Cookie: "A", Timestamp: "2018-12-07T15:19:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-08T10:13:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-09T07:25:00+00:00"},
+	}
+
+	mockParser := cookie.NewMockFileParser(t)
+	mockParser.EXPECT().StreamFile("test.csv", mock.AnythingOfType("cookie.EntryProcessor")).Run(func(_ string, processor cookie.EntryProcessor) {
+		for _, entry := range entries {
+			processor(entry)
+		}
+	}).Return(nil)
+
+	var dates []string
+	snapshots := make(map[string]map[string]int)
+	processor := cookie.NewProcessor(mockParser, cookie.WithDateRolloverHook(func(date string, counts map[string]int) {
+		dates = append(dates, date)
+		snapshots[date] = counts
+	}))
+
+	_, err := processor.FindMostActiveOverall("test.csv")
+
+	assert.NoError(t, err, "unexpected error")
+	assert.Equal(t, []string{"2018-12-07", "2018-12-08", "2018-12-09"}, dates, "each date should roll over once, in file order")
+	assert.Equal(t, map[string]int{"A": 2}, snapshots["2018-12-07"], "first day counts mismatch")
+	assert.Equal(t, map[string]int{"C": 1}, snapshots["2018-12-09"], "final day should be flushed at end of scan")
+}
+
+func TestProcessor_FindMostActiveCookies_TieBreak(t *testing.T) {
+	entries := []cookie.LogEntry{
+		{Cookie: "A", Timestamp: "2018-12-09T14:19:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-09T06:19:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T10:13:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T11:13:00+00:00"},
+		{Cookie: "A", Timestamp: "2018-12-09T07:25:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-09T20:25:00+00:00"},
+	}
+
+	tests := []struct {
+		name           string
+		tieBreak       cookie.TieBreak
+		expectedResult []string
+	}{
+		{
+			name:           "alphabetical returns all tied cookies",
+			tieBreak:       cookie.TieBreakAlphabetical,
+			expectedResult: []string{"A", "B", "C"},
+		},
+		{
+			name:           "earliest returns the first cookie seen chronologically",
+			tieBreak:       cookie.TieBreakEarliest,
+			expectedResult: []string{"C"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockParser := cookie.NewMockFileParser(t)
+			mockParser.EXPECT().StreamFile("test.csv", mock.AnythingOfType("cookie.EntryProcessor")).Run(func(_ string, processor cookie.EntryProcessor) {
+				for _, entry := range entries {
+					processor(entry)
+				}
+			}).Return(nil)
+			processor := cookie.NewProcessor(mockParser, cookie.WithTieBreak(tt.tieBreak))
+
+			cookies, err := processor.FindMostActiveCookies("test.csv", "2018-12-09")
+
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expectedResult, cookies, "result mismatch")
+		})
+	}
+}
+
+func TestProcessor_FindMostActiveCookies_TimeOfDayWindow(t *testing.T) {
+	entries := []cookie.LogEntry{
+		{Cookie: "A", Timestamp: "2018-12-09T06:19:00+00:00"},
+		{Cookie: "A", Timestamp: "2018-12-09T07:25:00+00:00"},
+		{Cookie: "A", Timestamp: "2018-12-09T17:00:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T09:00:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T16:59:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-09T12:13:00+00:00"},
+	}
+
+	tests := []struct {
+		name           string
+		start, end     string
+		expectedResult []string
+		errorContains  string
+	}{
+		{
+			name:           "business hours",
+			start:          "09:00",
+			end:            "17:00",
+			expectedResult: []string{"B"},
+		},
+		{
+			name:          "window wrapping midnight",
+			start:         "22:00",
+			end:           "06:00",
+			errorContains: "start must be before end",
+		},
+		{
+			name:          "malformed bound",
+			start:         "9am",
+			end:           "17:00",
+			errorContains: "expected HH:MM",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockParser := cookie.NewMockFileParser(t)
+			if tt.errorContains == "" {
+				mockParser.EXPECT().StreamFile("test.csv", mock.AnythingOfType("cookie.EntryProcessor")).Run(func(_ string, processor cookie.EntryProcessor) {
+					for _, entry := range entries {
+						processor(entry)
+					}
+				}).Return(nil)
+			}
+			processor := cookie.NewProcessor(mockParser, cookie.WithTimeOfDayWindow(tt.start, tt.end))
+
+			cookies, err := processor.FindMostActiveCookies("test.csv", "2018-12-09")
+
+			if tt.errorContains != "" {
+				assert.ErrorContains(t, err, tt.errorContains, "error should contain expected substring")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expectedResult, cookies, "result mismatch")
+		})
+	}
+}
+
+// sliceParser replays a fixed set of entries without mock bookkeeping, so
+// benchmarks measure the processor rather than the test double.
+type sliceParser struct {
+	entries []cookie.LogEntry
+}
+
+func (p *sliceParser) StreamFile(_ string, processor cookie.EntryProcessor) error {
+	for _, entry := range p.entries {
+		if err := processor(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// statsParser is a sliceParser that reports scan statistics.
+// blockingParser stands for a reader that stalls: it never delivers an entry
+// and returns only once ctx is done.
+type blockingParser struct{}
+
+func (p *blockingParser) StreamFile(string, cookie.EntryProcessor) error {
+	select {}
+}
+
+func (p *blockingParser) StreamFileContext(ctx context.Context, _ string, _ cookie.EntryProcessor) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+type statsParser struct {
+	sliceParser
+}
+
+func (p *statsParser) StreamFileStats(filename string, processor cookie.EntryProcessor) (cookie.ScanStats, error) {
+	var stats cookie.ScanStats
+	err := p.StreamFile(filename, func(entry cookie.LogEntry) error {
+		stats.Lines++
+		err := processor(entry)
+		if err == nil {
+			stats.Entries++
+		}
+		stats.StoppedEarly = errors.Is(err, cookie.ErrPastTargetDate)
+		return err
+	})
+	if stats.StoppedEarly {
+		err = nil
+	}
+	return stats, err
+}
+
+func BenchmarkProcessor_Cardinality(b *testing.B) {
+	for _, distinct := range []int{1, 4, 8, 16, 64, 1024} {
+		b.Run(fmt.Sprintf("distinct=%d", distinct), func(b *testing.B) {
+			entries := make([]cookie.LogEntry, 10000)
+			for i := range entries {
+				entries[i] = cookie.LogEntry{
+					Cookie:    fmt.Sprintf("cookie-%04d", i%distinct),
+					Timestamp: "2018-12-09T14:19:00+00:00",
+				}
+			}
+			processor := cookie.NewProcessor(&sliceParser{entries: entries})
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				_, err := processor.FindMostActiveCookies("test.csv", "2018-12-09")
+				assert.NoError(b, err, "benchmark iteration should succeed")
+			}
+		})
+	}
+}
+
+// multiDateEntries builds a date-sorted log covering December 1st up to the
+// given number of days, with perDay distinct cookies on every date.
+func multiDateEntries(days, perDay int) []cookie.LogEntry {
+	entries := make([]cookie.LogEntry, 0, days*perDay)
+	for day := 1; day <= days; day++ {
+		for i := 0; i < perDay; i++ {
+			entries = append(entries, cookie.LogEntry{
+				Cookie:    fmt.Sprintf("day%02d-cookie-%03d", day, i),
+				Timestamp: fmt.Sprintf("2018-12-%02dT12:00:00+00:00", day),
+			})
+		}
+	}
+	return entries
+}
+
+func TestProcessor_FindMostActiveCookies_AllocatesOnlyForTargetDate(t *testing.T) {
+	const targetDate = "2018-12-10"
+	allDates := multiDateEntries(20, 100)
+	var targetOnly []cookie.LogEntry
+	for _, entry := range allDates {
+		if entry.Timestamp[:10] == targetDate {
+			targetOnly = append(targetOnly, entry)
+		}
+	}
+
+	allocsFor := func(entries []cookie.LogEntry) float64 {
+		processor := cookie.NewProcessor(&sliceParser{entries: entries})
+		return testing.AllocsPerRun(20, func() {
+			_, err := processor.FindMostActiveCookies("test.csv", targetDate)
+			assert.NoError(t, err, "unexpected error")
+		})
+	}
+
+	assert.Equal(t, allocsFor(targetOnly), allocsFor(allDates),
+		"entries from other dates should not allocate counter state")
+}
+
+func TestProcessor_MemoryBudget(t *testing.T) {
+	entries := multiDateEntries(1, 2000)
+
+	tests := []struct {
+		name        string
+		budget      int64
+		expectError bool
+	}{
+		{name: "unlimited by default", budget: 0},
+		{name: "within budget", budget: 1 << 20},
+		{name: "exceeds budget", budget: 16 << 10, expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := cookie.NewProcessor(&sliceParser{entries: entries}, cookie.WithMemoryBudget(tt.budget))
+
+			_, err := processor.FindMostActiveCookies("test.csv", "2018-12-01")
+			_, overallErr := processor.FindMostActiveOverall("test.csv")
+			_, hourlyErr := processor.TopCookiePerHour("test.csv", "2018-12-01")
+
+			if tt.expectError {
+				assert.ErrorIs(t, err, cookie.ErrMemoryBudgetExceeded, "target-date query should hit the budget")
+				assert.ErrorIs(t, overallErr, cookie.ErrMemoryBudgetExceeded, "whole-file query should hit the budget")
+				assert.ErrorIs(t, hourlyErr, cookie.ErrMemoryBudgetExceeded, "per-hour query should hit the budget")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.NoError(t, overallErr, "unexpected error")
+			assert.NoError(t, hourlyErr, "unexpected error")
+		})
+	}
+}
+
+func BenchmarkProcessor_MultiDateSorted(b *testing.B) {
+	processor := cookie.NewProcessor(&sliceParser{entries: multiDateEntries(30, 1000)})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := processor.FindMostActiveCookies("test.csv", "2018-12-15")
+		assert.NoError(b, err, "benchmark iteration should succeed")
+	}
+}
+
+func TestProcessor_FindMostActiveCookies_FirstSeenOrder(t *testing.T) {
+	entries := []cookie.LogEntry{
+		{Cookie: "X", Timestamp: "2018-12-08T23:00:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-09T06:19:00+00:00"},
+		{Cookie: "A", Timestamp: "2018-12-09T07:25:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T10:13:00+00:00"},
+		{Cookie: "A", Timestamp: "2018-12-09T11:13:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-09T12:13:00+00:00"},
+	}
+
+	mockParser := cookie.NewMockFileParser(t)
+	mockParser.EXPECT().StreamFile("test.csv", mock.AnythingOfType("cookie.EntryProcessor")).Run(func(_ string, processor cookie.EntryProcessor) {
+		for _, entry := range entries {
+			processor(entry)
+		}
+	}).Return(nil)
+	processor := cookie.NewProcessor(mockParser, cookie.WithWinnerOrder(cookie.OrderFirstSeen))
+
+	cookies, err := processor.FindMostActiveCookies("test.csv", "2018-12-09")
+
+	assert.NoError(t, err, "unexpected error")
+	assert.Equal(t, []string{"C", "A"}, cookies, "winners should follow first appearance order")
+}
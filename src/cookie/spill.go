@@ -0,0 +1,196 @@
+package cookie
+
+import (
+	"bufio"
+	"container/heap"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxRunLine bounds a single "count cookie" line in a run file.
+const maxRunLine = 1024 * 1024
+
+// spillCounter counts cookies in memory until more than threshold distinct
+// cookies are held, then writes the counts to a sorted run file in dir and
+// starts over. The runs are merged at the end, so memory stays bounded by the
+// threshold instead of by the number of distinct cookies in the file.
+type spillCounter struct {
+	dir       string
+	threshold int
+	counts    map[string]int
+	runs      []string
+}
+
+func newSpillCounter(dir string, threshold int) *spillCounter {
+	return &spillCounter{
+		dir:       dir,
+		threshold: threshold,
+		counts:    make(map[string]int),
+	}
+}
+
+func (s *spillCounter) add(cookie string, n int) error {
+	s.counts[cookie] += n
+	if len(s.counts) > s.threshold {
+		return s.spill()
+	}
+	return nil
+}
+
+func (s *spillCounter) spill() error {
+	cookies := make([]string, 0, len(s.counts))
+	for cookie := range s.counts {
+		cookies = append(cookies, cookie)
+	}
+	sort.Strings(cookies)
+
+	file, err := os.CreateTemp(s.dir, "cookie-counts-*.run")
+	if err != nil {
+		return fmt.Errorf("failed to create spill file: %w", err)
+	}
+	s.runs = append(s.runs, file.Name())
+	defer file.Close()
+
+	writer := bufio.NewWriter(file)
+	for _, cookie := range cookies {
+		writer.WriteString(strconv.Itoa(s.counts[cookie]))
+		writer.WriteByte(' ')
+		writer.WriteString(cookie)
+		writer.WriteByte('\n')
+	}
+	if err := writer.Flush(); err != nil {
+		return fmt.Errorf("failed to write spill file %s: %w", file.Name(), err)
+	}
+
+	s.counts = make(map[string]int)
+	return nil
+}
+
+// mostActive merges the spilled runs with the in-memory counts and returns the
+// alphabetically sorted cookies sharing the highest total.
+func (s *spillCounter) mostActive() ([]string, error) {
+	if len(s.runs) == 0 {
+		return mostActive(s.counts), nil
+	}
+	if len(s.counts) > 0 {
+		if err := s.spill(); err != nil {
+			return nil, err
+		}
+	}
+
+	readers := make([]*runReader, 0, len(s.runs))
+	defer func() {
+		for _, reader := range readers {
+			reader.file.Close()
+		}
+	}()
+
+	merger := &runHeap{}
+	for _, run := range s.runs {
+		reader, err := openRun(run)
+		if err != nil {
+			return nil, err
+		}
+		readers = append(readers, reader)
+		if reader.next() {
+			heap.Push(merger, reader)
+		}
+	}
+
+	var mostActiveCookies []string
+	maxCount := 0
+	for merger.Len() > 0 {
+		cookie := (*merger)[0].cookie
+		count := 0
+		for merger.Len() > 0 && (*merger)[0].cookie == cookie {
+			reader := (*merger)[0]
+			count += reader.count
+			if reader.next() {
+				heap.Fix(merger, 0)
+			} else {
+				heap.Pop(merger)
+			}
+		}
+
+		if count > maxCount {
+			maxCount = count
+			mostActiveCookies = []string{cookie}
+		} else if count == maxCount {
+			mostActiveCookies = append(mostActiveCookies, cookie)
+		}
+	}
+
+	for _, reader := range readers {
+		if reader.err != nil {
+			return nil, reader.err
+		}
+	}
+
+	// Runs are merged in cookie order, so the winners are already sorted.
+	return mostActiveCookies, nil
+}
+
+// cleanup removes all run files.
+func (s *spillCounter) cleanup() {
+	for _, run := range s.runs {
+		os.Remove(run)
+	}
+	s.runs = nil
+}
+
+type runReader struct {
+	file    *os.File
+	scanner *bufio.Scanner
+	cookie  string
+	count   int
+	err     error
+}
+
+func openRun(name string) (*runReader, error) {
+	file, err := os.Open(name) //nolint:gosec
+	if err != nil {
+		return nil, fmt.Errorf("failed to open spill file %s: %w", name, err)
+	}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(nil, maxRunLine)
+	return &runReader{file: file, scanner: scanner}, nil
+}
+
+func (r *runReader) next() bool {
+	if !r.scanner.Scan() {
+		if err := r.scanner.Err(); err != nil {
+			r.err = fmt.Errorf("failed to read spill file %s: %w", r.file.Name(), err)
+		}
+		return false
+	}
+
+	countStr, cookie, ok := strings.Cut(r.scanner.Text(), " ")
+	count, err := strconv.Atoi(countStr)
+	if !ok || err != nil {
+		r.err = fmt.Errorf("corrupt spill file %s", r.file.Name())
+		return false
+	}
+	r.cookie = cookie
+	r.count = count
+	return true
+}
+
+// runHeap orders run readers by their current cookie for a k-way merge.
+type runHeap []*runReader
+
+func (h runHeap) Len() int           { return len(h) }
+func (h runHeap) Less(i, j int) bool { return h[i].cookie < h[j].cookie }
+func (h runHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *runHeap) Push(x any) { *h = append(*h, x.(*runReader)) }
+
+func (h *runHeap) Pop() any {
+	old := *h
+	n := len(old)
+	reader := old[n-1]
+	*h = old[:n-1]
+	return reader
+}
@@ -0,0 +1,43 @@
+package cookie_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/mfenderov/most-active-cookie/src/cookie"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestProcessor_FindMostActiveOverall_DiskSpill(t *testing.T) {
+	entries := []cookie.LogEntry{
+		{Cookie: "D", Timestamp: "2018-12-07T14:19:00+00:00"},
+		{Cookie: "A", Timestamp: "2018-12-07T15:19:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-08T10:13:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-08T11:13:00+00:00"},
+		{Cookie: "A", Timestamp: "2018-12-09T10:13:00+00:00"},
+		{Cookie: "E", Timestamp: "2018-12-09T11:13:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-10T07:25:00+00:00"},
+		{Cookie: "F", Timestamp: "2018-12-10T08:25:00+00:00"},
+		{Cookie: "A", Timestamp: "2018-12-11T07:25:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-11T08:25:00+00:00"},
+	}
+
+	spillDir := t.TempDir()
+	mockParser := cookie.NewMockFileParser(t)
+	mockParser.EXPECT().StreamFile("test.csv", mock.AnythingOfType("cookie.EntryProcessor")).Run(func(_ string, processor cookie.EntryProcessor) {
+		for _, entry := range entries {
+			assert.NoError(t, processor(entry), "spilling should not fail")
+		}
+	}).Return(nil)
+	processor := cookie.NewProcessor(mockParser, cookie.WithDiskSpill(spillDir, 2))
+
+	cookies, err := processor.FindMostActiveOverall("test.csv")
+
+	assert.NoError(t, err, "unexpected error")
+	assert.Equal(t, []string{"A", "C"}, cookies, "counts split across spill files should be merged")
+
+	leftovers, err := os.ReadDir(spillDir)
+	assert.NoError(t, err, "failed to read spill dir")
+	assert.Empty(t, leftovers, "spill files should be removed after merging")
+}
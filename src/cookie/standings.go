@@ -0,0 +1,291 @@
+package cookie
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+)
+
+// CookieCount pairs a cookie with its number of occurrences.
+type CookieCount struct {
+	Cookie string `json:"cookie"`
+	Count  int    `json:"count"`
+	// FirstSeen and LastSeen are the earliest and latest timestamps of a
+	// winner on the target date, set only with WithTimeSpans.
+	FirstSeen time.Time `json:"first_seen,omitzero"`
+	LastSeen  time.Time `json:"last_seen,omitzero"`
+}
+
+// Standings is the outcome of a date with context: the winner(s), their count
+// and, when there is a single clear winner, the cookies right behind it.
+type Standings struct {
+	Winners []string
+	Count   int
+	// RunnersUp holds every cookie in the next count tiers below the winner,
+	// highest count first and in the winner order within a tier. Cookies tied
+	// with a winner picked by the tie-break form the first tier. It is empty
+	// when the winners are tied.
+	RunnersUp []CookieCount
+}
+
+// FindStandings returns the most active cookie(s) for targetDate together with
+// the runners-up in up to depth distinct count tiers behind a unique winner,
+// for summaries like "A won with 50, next closest was B with 12". Winners are
+// picked and ordered like in FindMostActiveCookies.
+func (p *Processor) FindStandings(filename, targetDate string, depth int) (Standings, error) {
+	count, err := p.countDate(filename, targetDate)
+	if err != nil {
+		return Standings{}, err
+	}
+	return p.standingsOf(count, depth), nil
+}
+
+// RankedCookie is a cookie with its count and its rank among the cookies of a
+// date. Ranks follow standard competition ranking: cookies with equal counts
+// share a rank and the next count's rank skips past them, as in 1, 1, 3.
+type RankedCookie struct {
+	Rank   int    `json:"rank"`
+	Cookie string `json:"cookie"`
+	Count  int    `json:"count"`
+}
+
+// Summary describes the scan behind a single-date result, for footers like
+// "scanned 100000 lines, 3 distinct cookies".
+type Summary struct {
+	ScanStats
+	Distinct int // distinct cookies counted on the target date
+}
+
+// FindMostActiveCookiesWithCounts is FindMostActiveCookies keeping the count
+// the winners share. Like FindMostActiveCookies it applies the tie-break and
+// winner order.
+func (p *Processor) FindMostActiveCookiesWithCounts(filename, targetDate string) ([]CookieCount, error) {
+	count, err := p.countDate(filename, targetDate)
+	if err != nil {
+		return nil, err
+	}
+	return p.countedWinners(count), nil
+}
+
+// FindMostActiveCookiesWithSummary is FindMostActiveCookiesWithCounts also
+// summarizing the scan. The parser must implement StatsParser.
+func (p *Processor) FindMostActiveCookiesWithSummary(filename, targetDate string) ([]CookieCount, Summary, error) {
+	count, stats, err := p.countDateStats(filename, targetDate)
+	if err != nil {
+		return nil, Summary{}, err
+	}
+	return p.countedWinners(count), Summary{ScanStats: stats, Distinct: count.counter.len()}, nil
+}
+
+// countedWinners returns the winners of count with the count they share and,
+// with WithTimeSpans, when each was first and last seen.
+func (p *Processor) countedWinners(count *dateCount) []CookieCount {
+	cookies := p.winners(count)
+	winners := make([]CookieCount, len(cookies))
+	for i, winner := range cookies {
+		winners[i] = CookieCount{Cookie: winner, Count: count.counter.get(winner)}
+		if p.timeSpans {
+			span := count.spans[winner]
+			winners[i].FirstSeen, winners[i].LastSeen = span.first, span.last
+		}
+	}
+	return winners
+}
+
+// FindMostActiveCookiesWithCountsByDate is FindMostActiveCookiesByDate with
+// each winner's count, read in the same single pass over the file.
+func (p *Processor) FindMostActiveCookiesWithCountsByDate(filename string, targetDates []string) (map[string][]CookieCount, error) {
+	normalized, counts, err := p.countDates(filename, targetDates)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make(map[string][]CookieCount, len(targetDates))
+	for i, targetDate := range targetDates {
+		results[targetDate] = p.countedWinners(counts[normalized[i]])
+	}
+	return results, nil
+}
+
+// FindTopCookies returns up to n distinct cookies for targetDate, most active
+// first and alphabetical among equal counts, so a cookie's rank is its index
+// plus one. Fewer than n are returned when fewer cookies were seen.
+func (p *Processor) FindTopCookies(filename, targetDate string, n int) ([]string, error) {
+	ranked, err := p.FindRankedCookies(filename, targetDate, n)
+	if err != nil {
+		return nil, err
+	}
+
+	top := make([]string, len(ranked))
+	for i, entry := range ranked {
+		top[i] = entry.Cookie
+	}
+	return top, nil
+}
+
+// FindRankedCookies is FindTopCookies keeping each cookie's count and rank,
+// for leaderboards. Cookies tied at the cut-off are dropped alphabetically,
+// like in FindTopCookies.
+func (p *Processor) FindRankedCookies(filename, targetDate string, n int) ([]RankedCookie, error) {
+	if n < 1 {
+		return nil, fmt.Errorf("n must be at least 1: %d", n)
+	}
+	count, err := p.countDate(filename, targetDate)
+	if err != nil {
+		return nil, err
+	}
+
+	ranked := rank(&count.counter)
+	top := make([]RankedCookie, 0, min(n, len(ranked)))
+	for i, entry := range ranked[:cap(top)] {
+		position := i + 1
+		if i > 0 && entry.Count == top[i-1].Count {
+			position = top[i-1].Rank
+		}
+		top = append(top, RankedCookie{Rank: position, Cookie: entry.Cookie, Count: entry.Count})
+	}
+	return top, nil
+}
+
+// FindLeastActiveCookies returns the cookie(s) seen the fewest times on
+// targetDate, alphabetically, for spotting anomalies. It returns an empty
+// slice when the date has no entries.
+func (p *Processor) FindLeastActiveCookies(filename, targetDate string) ([]string, error) {
+	count, err := p.countDate(filename, targetDate)
+	if err != nil {
+		return nil, err
+	}
+
+	ranked := rank(&count.counter)
+	start := len(ranked)
+	for start > 0 && ranked[start-1].Count == ranked[len(ranked)-1].Count {
+		start--
+	}
+	least := make([]string, 0, len(ranked)-start)
+	for _, entry := range ranked[start:] {
+		least = append(least, entry.Cookie)
+	}
+	return least, nil
+}
+
+// FindCookiesAboveThreshold returns every cookie seen at least minCount times
+// on targetDate with its count, most active first and alphabetical among equal
+// counts. Unlike FindTopCookies the result size depends on the data; it is an
+// empty slice when no cookie reaches minCount.
+func (p *Processor) FindCookiesAboveThreshold(filename, targetDate string, minCount int) ([]CookieCount, error) {
+	count, err := p.countDate(filename, targetDate)
+	if err != nil {
+		return nil, err
+	}
+
+	ranked := rank(&count.counter)
+	end := sort.Search(len(ranked), func(i int) bool {
+		return ranked[i].Count < minCount
+	})
+	return ranked[:end], nil
+}
+
+// countDate counts each cookie's entries on targetDate in a single pass.
+func (p *Processor) countDate(filename, targetDate string) (*dateCount, error) {
+	return p.streamDate(filename, targetDate, func(process EntryProcessor) error {
+		return p.parser.StreamFile(filename, process)
+	})
+}
+
+// countDateStats is countDate also returning the parser's statistics for the
+// scan, with the duplicates counted when tracked. The parser must implement
+// StatsParser.
+func (p *Processor) countDateStats(filename, targetDate string) (*dateCount, ScanStats, error) {
+	statsParser, ok := p.parser.(StatsParser)
+	if !ok {
+		return nil, ScanStats{}, fmt.Errorf("parser %T does not report scan statistics", p.parser)
+	}
+
+	var stats ScanStats
+	count, err := p.streamDate(filename, targetDate, func(process EntryProcessor) error {
+		var err error
+		stats, err = statsParser.StreamFileStats(filename, process)
+		return err
+	})
+	if err != nil {
+		return nil, stats, err
+	}
+	stats.Duplicates = count.duplicates
+	return count, stats, nil
+}
+
+// streamDate is countDate streaming the file through stream.
+func (p *Processor) streamDate(filename, targetDate string, stream func(EntryProcessor) error) (*dateCount, error) {
+	if filename == "" {
+		return nil, fmt.Errorf("filename cannot be empty")
+	}
+	if p.optionErr != nil {
+		return nil, p.optionErr
+	}
+	targetDate, err := p.normalizeDate(targetDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target date: %w", err)
+	}
+
+	count, process := p.countTargetDate(targetDate)
+	err = stream(process)
+	if err != nil && !errors.Is(err, ErrPastTargetDate) {
+		return nil, fmt.Errorf("failed to stream file: %w", err)
+	}
+	return count, nil
+}
+
+// rank lists the counted cookies by descending count, alphabetical within a
+// count.
+func rank(counter *cookieCounter) []CookieCount {
+	ranked := make([]CookieCount, 0, counter.len())
+	counter.each(func(cookie string, count int) {
+		ranked = append(ranked, CookieCount{Cookie: cookie, Count: count})
+	})
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].Count != ranked[j].Count {
+			return ranked[i].Count > ranked[j].Count
+		}
+		return ranked[i].Cookie < ranked[j].Cookie
+	})
+	return ranked
+}
+
+// standingsOf splits the counted cookies into the winners, narrowed by the
+// tie-break and in the winner order, and runner-up tiers.
+func (p *Processor) standingsOf(count *dateCount, depth int) Standings {
+	winners := p.winners(count)
+	if len(winners) == 0 {
+		return Standings{Winners: []string{}}
+	}
+
+	standings := Standings{Winners: winners, Count: count.counter.get(winners[0])}
+	if len(winners) > 1 {
+		return standings
+	}
+
+	ranked := rank(&count.counter)
+	if count.firstIndex != nil {
+		sort.SliceStable(ranked, func(i, j int) bool {
+			if ranked[i].Count != ranked[j].Count {
+				return ranked[i].Count > ranked[j].Count
+			}
+			return count.firstIndex[ranked[i].Cookie] < count.firstIndex[ranked[j].Cookie]
+		})
+	}
+	rest := slices.DeleteFunc(ranked, func(c CookieCount) bool { return c.Cookie == winners[0] })
+
+	tiers := 0
+	for i, entry := range rest {
+		if i == 0 || entry.Count != rest[i-1].Count {
+			tiers++
+		}
+		if tiers > depth {
+			break
+		}
+		standings.RunnersUp = append(standings.RunnersUp, entry)
+	}
+	return standings
+}
@@ -0,0 +1,452 @@
+package cookie_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mfenderov/most-active-cookie/src/cookie"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProcessor_FindStandings(t *testing.T) {
+	// entriesFor expands cookie counts into target-date entries.
+	entriesFor := func(counts map[string]int) []cookie.LogEntry {
+		var entries []cookie.LogEntry
+		for c, n := range counts {
+			for range n {
+				entries = append(entries, cookie.LogEntry{Cookie: c, Timestamp: "2018-12-09T14:19:00+00:00"})
+			}
+		}
+		return entries
+	}
+
+	tests := []struct {
+		name     string
+		counts   map[string]int
+		depth    int
+		expected cookie.Standings
+	}{
+		{
+			name:   "clear winner with two tiers",
+			counts: map[string]int{"A": 5, "B": 3, "C": 3, "D": 2, "E": 1},
+			depth:  2,
+			expected: cookie.Standings{
+				Winners:   []string{"A"},
+				Count:     5,
+				RunnersUp: []cookie.CookieCount{{Cookie: "B", Count: 3}, {Cookie: "C", Count: 3}, {Cookie: "D", Count: 2}},
+			},
+		},
+		{
+			name:   "depth beyond available tiers",
+			counts: map[string]int{"A": 5, "B": 1},
+			depth:  3,
+			expected: cookie.Standings{
+				Winners:   []string{"A"},
+				Count:     5,
+				RunnersUp: []cookie.CookieCount{{Cookie: "B", Count: 1}},
+			},
+		},
+		{
+			name:   "tied winners have no runners-up",
+			counts: map[string]int{"A": 4, "B": 4, "C": 1},
+			depth:  1,
+			expected: cookie.Standings{
+				Winners: []string{"A", "B"},
+				Count:   4,
+			},
+		},
+		{
+			name:     "no entries",
+			counts:   map[string]int{},
+			depth:    1,
+			expected: cookie.Standings{Winners: []string{}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := cookie.NewProcessor(&sliceParser{entries: entriesFor(tt.counts)})
+
+			standings, err := processor.FindStandings("test.csv", "2018-12-09", tt.depth)
+
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, standings, "standings mismatch")
+		})
+	}
+}
+
+func TestProcessor_FindStandings_WinnerOptions(t *testing.T) {
+	entries := []cookie.LogEntry{
+		{Cookie: "D", Timestamp: "2018-12-09T14:00:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-09T13:00:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T09:00:00+00:00"},
+		{Cookie: "A", Timestamp: "2018-12-09T10:00:00+00:00"},
+		{Cookie: "A", Timestamp: "2018-12-09T11:00:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T12:00:00+00:00"},
+	}
+
+	tests := []struct {
+		name     string
+		opts     []cookie.Option
+		expected cookie.Standings
+	}{
+		{
+			name:     "first-seen order",
+			opts:     []cookie.Option{cookie.WithWinnerOrder(cookie.OrderFirstSeen)},
+			expected: cookie.Standings{Winners: []string{"B", "A"}, Count: 2},
+		},
+		{
+			name: "earliest tie-break",
+			opts: []cookie.Option{cookie.WithTieBreak(cookie.TieBreakEarliest)},
+			expected: cookie.Standings{
+				Winners:   []string{"B"},
+				Count:     2,
+				RunnersUp: []cookie.CookieCount{{Cookie: "A", Count: 2}, {Cookie: "C", Count: 1}, {Cookie: "D", Count: 1}},
+			},
+		},
+		{
+			name: "earliest tie-break in first-seen order",
+			opts: []cookie.Option{cookie.WithTieBreak(cookie.TieBreakEarliest), cookie.WithWinnerOrder(cookie.OrderFirstSeen)},
+			expected: cookie.Standings{
+				Winners:   []string{"B"},
+				Count:     2,
+				RunnersUp: []cookie.CookieCount{{Cookie: "A", Count: 2}, {Cookie: "D", Count: 1}, {Cookie: "C", Count: 1}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := cookie.NewProcessor(&sliceParser{entries: entries}, tt.opts...)
+
+			standings, err := processor.FindStandings("test.csv", "2018-12-09", 2)
+
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, standings, "standings mismatch")
+		})
+	}
+}
+
+func TestProcessor_FindTopCookies(t *testing.T) {
+	entries := []cookie.LogEntry{
+		{Cookie: "E", Timestamp: "2018-12-08T08:19:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-09T14:19:00+00:00"},
+		{Cookie: "A", Timestamp: "2018-12-09T13:19:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T12:19:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-09T11:19:00+00:00"},
+		{Cookie: "D", Timestamp: "2018-12-09T10:19:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T09:19:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-09T08:19:00+00:00"},
+	}
+
+	tests := []struct {
+		name          string
+		n             int
+		expected      []string
+		errorContains string
+	}{
+		{
+			name:     "top one",
+			n:        1,
+			expected: []string{"C"},
+		},
+		{
+			name:     "ties broken alphabetically",
+			n:        3,
+			expected: []string{"C", "B", "A"},
+		},
+		{
+			name:     "n beyond distinct cookies",
+			n:        10,
+			expected: []string{"C", "B", "A", "D"},
+		},
+		{
+			name:          "non-positive n",
+			n:             0,
+			errorContains: "n must be at least 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := cookie.NewProcessor(&sliceParser{entries: entries})
+
+			top, err := processor.FindTopCookies("test.csv", "2018-12-09", tt.n)
+
+			if tt.errorContains != "" {
+				assert.ErrorContains(t, err, tt.errorContains, "error should contain expected substring")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, top, "top cookies mismatch")
+		})
+	}
+}
+
+func TestProcessor_FindRankedCookies(t *testing.T) {
+	entries := []cookie.LogEntry{
+		{Cookie: "D", Timestamp: "2018-12-09T09:00:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T10:00:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T11:00:00+00:00"},
+		{Cookie: "A", Timestamp: "2018-12-09T12:00:00+00:00"},
+		{Cookie: "A", Timestamp: "2018-12-09T13:00:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-09T14:00:00+00:00"},
+		{Cookie: "E", Timestamp: "2018-12-09T15:00:00+00:00"},
+		{Cookie: "E", Timestamp: "2018-12-09T16:00:00+00:00"},
+		{Cookie: "E", Timestamp: "2018-12-09T17:00:00+00:00"},
+	}
+	processor := cookie.NewProcessor(&sliceParser{entries: entries})
+
+	ranked, err := processor.FindRankedCookies("test.csv", "2018-12-09", 4)
+
+	assert.NoError(t, err, "unexpected error")
+	assert.Equal(t, []cookie.RankedCookie{
+		{Rank: 1, Cookie: "E", Count: 3},
+		{Rank: 2, Cookie: "A", Count: 2},
+		{Rank: 2, Cookie: "B", Count: 2},
+		{Rank: 4, Cookie: "C", Count: 1},
+	}, ranked, "ties should share a rank and the next rank skip past them")
+
+	_, err = processor.FindRankedCookies("test.csv", "2018-12-09", 0)
+	assert.ErrorContains(t, err, "n must be at least 1", "n should be validated")
+}
+
+func TestProcessor_FindMostActiveCookiesWithCounts(t *testing.T) {
+	tests := []struct {
+		name     string
+		entries  []cookie.LogEntry
+		opts     []cookie.Option
+		expected []cookie.CookieCount
+	}{
+		{
+			name: "tied winners share their count",
+			entries: []cookie.LogEntry{
+				{Cookie: "B", Timestamp: "2018-12-09T14:19:00+00:00"},
+				{Cookie: "A", Timestamp: "2018-12-09T13:19:00+00:00"},
+				{Cookie: "C", Timestamp: "2018-12-09T12:19:00+00:00"},
+				{Cookie: "B", Timestamp: "2018-12-09T11:19:00+00:00"},
+				{Cookie: "A", Timestamp: "2018-12-09T10:19:00+00:00"},
+			},
+			expected: []cookie.CookieCount{{Cookie: "A", Count: 2}, {Cookie: "B", Count: 2}},
+		},
+		{
+			name: "first-seen order",
+			entries: []cookie.LogEntry{
+				{Cookie: "B", Timestamp: "2018-12-09T14:19:00+00:00"},
+				{Cookie: "A", Timestamp: "2018-12-09T13:19:00+00:00"},
+				{Cookie: "B", Timestamp: "2018-12-09T11:19:00+00:00"},
+				{Cookie: "A", Timestamp: "2018-12-09T10:19:00+00:00"},
+			},
+			opts:     []cookie.Option{cookie.WithWinnerOrder(cookie.OrderFirstSeen)},
+			expected: []cookie.CookieCount{{Cookie: "B", Count: 2}, {Cookie: "A", Count: 2}},
+		},
+		{
+			name: "earliest tie-break",
+			entries: []cookie.LogEntry{
+				{Cookie: "A", Timestamp: "2018-12-09T14:19:00+00:00"},
+				{Cookie: "B", Timestamp: "2018-12-09T13:19:00+00:00"},
+				{Cookie: "A", Timestamp: "2018-12-09T11:19:00+00:00"},
+				{Cookie: "B", Timestamp: "2018-12-09T10:19:00+00:00"},
+			},
+			opts:     []cookie.Option{cookie.WithTieBreak(cookie.TieBreakEarliest)},
+			expected: []cookie.CookieCount{{Cookie: "B", Count: 2}},
+		},
+		{
+			name: "weighted entries",
+			entries: []cookie.LogEntry{
+				{Cookie: "A", Timestamp: "2018-12-09T14:19:00+00:00", Weight: 5},
+				{Cookie: "B", Timestamp: "2018-12-09T13:19:00+00:00"},
+			},
+			expected: []cookie.CookieCount{{Cookie: "A", Count: 5}},
+		},
+		{
+			name: "time spans",
+			entries: []cookie.LogEntry{
+				{Cookie: "A", Timestamp: "2018-12-09T14:19:00+00:00"},
+				{Cookie: "B", Timestamp: "2018-12-09T13:19:00+00:00"},
+				{Cookie: "A", Timestamp: "2018-12-09T06:05:00+00:00"},
+				{Cookie: "A", Timestamp: "2018-12-08T23:59:00+00:00"},
+				{Cookie: "A", Timestamp: "2018-12-09T09:30:00+00:00"},
+			},
+			opts: []cookie.Option{cookie.WithTimeSpans()},
+			expected: []cookie.CookieCount{{
+				Cookie:    "A",
+				Count:     3,
+				FirstSeen: time.Date(2018, 12, 9, 6, 5, 0, 0, time.UTC),
+				LastSeen:  time.Date(2018, 12, 9, 14, 19, 0, 0, time.UTC),
+			}},
+		},
+		{
+			name: "earliest tie-break without time spans",
+			entries: []cookie.LogEntry{
+				{Cookie: "A", Timestamp: "2018-12-09T14:19:00+00:00"},
+				{Cookie: "B", Timestamp: "2018-12-09T10:19:00+00:00"},
+			},
+			opts:     []cookie.Option{cookie.WithTieBreak(cookie.TieBreakEarliest)},
+			expected: []cookie.CookieCount{{Cookie: "B", Count: 1}},
+		},
+		{
+			name:     "no entries on the date",
+			entries:  []cookie.LogEntry{{Cookie: "A", Timestamp: "2018-12-08T14:19:00+00:00"}},
+			expected: []cookie.CookieCount{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := cookie.NewProcessor(&sliceParser{entries: tt.entries}, tt.opts...)
+
+			winners, err := processor.FindMostActiveCookiesWithCounts("test.csv", "2018-12-09")
+
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, winners, "winners mismatch")
+		})
+	}
+}
+
+func TestProcessor_FindMostActiveCookiesWithCountsByDate(t *testing.T) {
+	entries := []cookie.LogEntry{
+		{Cookie: "A", Timestamp: "2018-12-08T10:00:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-09T09:00:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T10:00:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T11:00:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-09T12:00:00+00:00"},
+	}
+	processor := cookie.NewProcessor(&sliceParser{entries: entries})
+
+	results, err := processor.FindMostActiveCookiesWithCountsByDate("test.csv", []string{"2018-12-09", "2018-12-08", "2018-12-01"})
+
+	assert.NoError(t, err, "unexpected error")
+	assert.Equal(t, map[string][]cookie.CookieCount{
+		"2018-12-09": {{Cookie: "B", Count: 2}, {Cookie: "C", Count: 2}},
+		"2018-12-08": {{Cookie: "A", Count: 1}},
+		"2018-12-01": {},
+	}, results, "each date should get its winners with their counts")
+}
+
+func TestProcessor_FindMostActiveCookiesWithSummary(t *testing.T) {
+	entries := []cookie.LogEntry{
+		{Cookie: "A", Timestamp: "2018-12-08T14:19:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T14:19:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-09T15:19:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T16:19:00+00:00"},
+		{Cookie: "D", Timestamp: "2018-12-10T07:25:00+00:00"},
+	}
+
+	t.Run("winners with the scan summary", func(t *testing.T) {
+		processor := cookie.NewProcessor(&statsParser{sliceParser{entries: entries}})
+
+		winners, summary, err := processor.FindMostActiveCookiesWithSummary("test.csv", "2018-12-09")
+
+		assert.NoError(t, err, "unexpected error")
+		assert.Equal(t, []cookie.CookieCount{{Cookie: "B", Count: 2}}, winners, "winners mismatch")
+		assert.Equal(t, cookie.Summary{ScanStats: cookie.ScanStats{Lines: 5, Entries: 5}, Distinct: 2}, summary, "summary mismatch")
+	})
+
+	t.Run("parser without stats", func(t *testing.T) {
+		processor := cookie.NewProcessor(&sliceParser{entries: entries})
+
+		_, _, err := processor.FindMostActiveCookiesWithSummary("test.csv", "2018-12-09")
+
+		assert.ErrorContains(t, err, "does not report scan statistics", "plain parsers have no stats")
+	})
+}
+
+func TestProcessor_FindLeastActiveCookies(t *testing.T) {
+	tests := []struct {
+		name     string
+		entries  []cookie.LogEntry
+		expected []string
+	}{
+		{
+			name: "single least active cookie",
+			entries: []cookie.LogEntry{
+				{Cookie: "A", Timestamp: "2018-12-09T14:19:00+00:00"},
+				{Cookie: "B", Timestamp: "2018-12-09T13:19:00+00:00"},
+				{Cookie: "A", Timestamp: "2018-12-09T12:19:00+00:00"},
+				{Cookie: "C", Timestamp: "2018-12-08T12:19:00+00:00"},
+			},
+			expected: []string{"B"},
+		},
+		{
+			name: "ties sorted alphabetically",
+			entries: []cookie.LogEntry{
+				{Cookie: "D", Timestamp: "2018-12-09T14:19:00+00:00"},
+				{Cookie: "A", Timestamp: "2018-12-09T13:19:00+00:00"},
+				{Cookie: "C", Timestamp: "2018-12-09T12:19:00+00:00"},
+				{Cookie: "A", Timestamp: "2018-12-09T11:19:00+00:00"},
+			},
+			expected: []string{"C", "D"},
+		},
+		{
+			name: "every cookie tied",
+			entries: []cookie.LogEntry{
+				{Cookie: "B", Timestamp: "2018-12-09T14:19:00+00:00"},
+				{Cookie: "A", Timestamp: "2018-12-09T13:19:00+00:00"},
+			},
+			expected: []string{"A", "B"},
+		},
+		{
+			name:     "no entries on the date",
+			entries:  []cookie.LogEntry{{Cookie: "A", Timestamp: "2018-12-08T14:19:00+00:00"}},
+			expected: []string{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := cookie.NewProcessor(&sliceParser{entries: tt.entries})
+
+			least, err := processor.FindLeastActiveCookies("test.csv", "2018-12-09")
+
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, least, "least active cookies mismatch")
+		})
+	}
+}
+
+func TestProcessor_FindCookiesAboveThreshold(t *testing.T) {
+	entries := []cookie.LogEntry{
+		{Cookie: "E", Timestamp: "2018-12-08T08:19:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-09T14:19:00+00:00"},
+		{Cookie: "A", Timestamp: "2018-12-09T13:19:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T12:19:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-09T11:19:00+00:00"},
+		{Cookie: "D", Timestamp: "2018-12-09T10:19:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T09:19:00+00:00"},
+		{Cookie: "C", Timestamp: "2018-12-09T08:19:00+00:00"},
+	}
+
+	tests := []struct {
+		name     string
+		minCount int
+		expected []cookie.CookieCount
+	}{
+		{
+			name:     "threshold met by one cookie",
+			minCount: 3,
+			expected: []cookie.CookieCount{{Cookie: "C", Count: 3}},
+		},
+		{
+			name:     "sorted by count then name",
+			minCount: 1,
+			expected: []cookie.CookieCount{{Cookie: "C", Count: 3}, {Cookie: "B", Count: 2}, {Cookie: "A", Count: 1}, {Cookie: "D", Count: 1}},
+		},
+		{
+			name:     "threshold met by nobody",
+			minCount: 4,
+			expected: []cookie.CookieCount{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			processor := cookie.NewProcessor(&sliceParser{entries: entries})
+
+			counts, err := processor.FindCookiesAboveThreshold("test.csv", "2018-12-09", tt.minCount)
+
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, counts, "cookies above threshold mismatch")
+		})
+	}
+}
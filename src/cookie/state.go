@@ -0,0 +1,140 @@
+package cookie
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+)
+
+// State is a running aggregate of per-date cookie counts built up across
+// separate runs, so a week of daily files can be queried without rescanning
+// the older ones.
+type State struct {
+	// Files holds the SHA-256 of every file already counted, so the same
+	// content is never added twice, even under a different name.
+	Files []string `json:"files"`
+	// Counts maps YYYY-MM-DD dates to per-cookie counts.
+	Counts map[string]map[string]int `json:"counts"`
+}
+
+// LoadState reads the state saved at path. A missing file yields an empty
+// state, so the first run starts from scratch.
+func LoadState(path string) (*State, error) {
+	state := &State{Counts: make(map[string]map[string]int)}
+
+	data, err := os.ReadFile(path) //nolint:gosec
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, state); err != nil {
+		return nil, fmt.Errorf("failed to parse state %s: %w", path, err)
+	}
+	if state.Counts == nil {
+		state.Counts = make(map[string]map[string]int)
+	}
+	return state, nil
+}
+
+// Save writes the state to path, replacing it atomically so an interrupted
+// run cannot leave a truncated state behind.
+func (s *State) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write state %s: %w", path, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write state %s: %w", path, err)
+	}
+	return nil
+}
+
+// FindMostActiveAccumulated adds every entry of filename to state, unless a
+// file with the same content was added before, and returns the most active
+// cookie(s) for targetDate across everything the state has accumulated. The
+// caller is responsible for saving the state afterwards. The state keeps only
+// counts, so the tie-break, winner order and time-of-day window options are
+// rejected rather than ignored.
+func (p *Processor) FindMostActiveAccumulated(filename, targetDate string, state *State) ([]string, error) {
+	if filename == "" {
+		return nil, fmt.Errorf("filename cannot be empty")
+	}
+	if err := p.checkAccumulable(); err != nil {
+		return nil, err
+	}
+	targetDate, err := p.normalizeDate(targetDate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target date: %w", err)
+	}
+
+	digest, err := fileDigest(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.Contains(state.Files, digest) {
+		err = p.parser.StreamFile(filename, func(entry LogEntry) error {
+			entryDate, err := entryDateOf(entry, p.location)
+			if err != nil {
+				return err
+			}
+			counts := state.Counts[entryDate]
+			if counts == nil {
+				counts = make(map[string]int)
+				state.Counts[entryDate] = counts
+			}
+			counts[entry.Cookie] += entry.weight()
+			return nil
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to stream file: %w", err)
+		}
+		state.Files = append(state.Files, digest)
+	}
+
+	return mostActive(state.Counts[targetDate]), nil
+}
+
+// checkAccumulable reports options that FindMostActiveAccumulated cannot
+// honour from saved counts.
+func (p *Processor) checkAccumulable() error {
+	switch {
+	case p.optionErr != nil:
+		return p.optionErr
+	case p.tieBreak != TieBreakAlphabetical:
+		return fmt.Errorf("accumulated counts cannot break ties by first appearance")
+	case p.order != OrderAlphabetical:
+		return fmt.Errorf("accumulated counts cannot order winners by first appearance")
+	case p.window != nil:
+		return fmt.Errorf("accumulated counts cannot be limited to a time-of-day window")
+	}
+	return nil
+}
+
+// fileDigest returns the hex SHA-256 of a file's content.
+func fileDigest(filename string) (string, error) {
+	file, err := os.Open(filename) //nolint:gosec
+	if err != nil {
+		return "", fmt.Errorf("failed to open file %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", fmt.Errorf("failed to read file %s: %w", filename, err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
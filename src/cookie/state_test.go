@@ -0,0 +1,115 @@
+package cookie_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mfenderov/most-active-cookie/src/cookie"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProcessor_FindMostActiveAccumulated(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "state.json")
+	monday := filepath.Join(dir, "monday.csv")
+	tuesday := filepath.Join(dir, "tuesday.csv")
+	require.NoError(t, os.WriteFile(monday, []byte("monday"), 0o600), "failed to write log")
+	require.NoError(t, os.WriteFile(tuesday, []byte("tuesday"), 0o600), "failed to write log")
+
+	// run loads the saved state, adds entries as the content of filename and
+	// saves the state again, like one CLI invocation.
+	run := func(filename string, entries []cookie.LogEntry) []string {
+		state, err := cookie.LoadState(statePath)
+		require.NoError(t, err, "failed to load state")
+
+		processor := cookie.NewProcessor(&sliceParser{entries: entries})
+		cookies, err := processor.FindMostActiveAccumulated(filename, "2018-12-09", state)
+		require.NoError(t, err, "unexpected error")
+
+		require.NoError(t, state.Save(statePath), "failed to save state")
+		return cookies
+	}
+
+	mondayEntries := []cookie.LogEntry{
+		{Cookie: "A", Timestamp: "2018-12-09T14:19:00+00:00"},
+		{Cookie: "A", Timestamp: "2018-12-09T15:19:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-09T16:19:00+00:00"},
+	}
+	tuesdayEntries := []cookie.LogEntry{
+		{Cookie: "B", Timestamp: "2018-12-09T23:19:00+00:00"},
+		{Cookie: "B", Timestamp: "2018-12-10T01:19:00+00:00"},
+	}
+
+	assert.Equal(t, []string{"A"}, run(monday, mondayEntries), "first run should count the first file")
+	assert.Equal(t, []string{"A", "B"}, run(tuesday, tuesdayEntries), "second run should add to the saved counts")
+	assert.Equal(t, []string{"A", "B"}, run(tuesday, tuesdayEntries), "a file already counted should not be counted again")
+
+	state, err := cookie.LoadState(statePath)
+	require.NoError(t, err, "failed to load state")
+	assert.Len(t, state.Files, 2, "each distinct file should be recorded once")
+	assert.Equal(t, map[string]int{"B": 1}, state.Counts["2018-12-10"], "other dates should be accumulated too")
+}
+
+func TestProcessor_FindMostActiveAccumulated_UnsupportedOptions(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "monday.csv")
+	require.NoError(t, os.WriteFile(filename, []byte("monday"), 0o600), "failed to write log")
+
+	tests := []struct {
+		name          string
+		opt           cookie.Option
+		errorContains string
+	}{
+		{
+			name:          "earliest tie-break",
+			opt:           cookie.WithTieBreak(cookie.TieBreakEarliest),
+			errorContains: "cannot break ties by first appearance",
+		},
+		{
+			name:          "first-seen order",
+			opt:           cookie.WithWinnerOrder(cookie.OrderFirstSeen),
+			errorContains: "cannot order winners by first appearance",
+		},
+		{
+			name:          "time-of-day window",
+			opt:           cookie.WithTimeOfDayWindow("09:00", "17:00"),
+			errorContains: "cannot be limited to a time-of-day window",
+		},
+		{
+			name:          "invalid option",
+			opt:           cookie.WithTimeOfDayWindow("25:00", "17:00"),
+			errorContains: "invalid time-of-day window start",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			state, err := cookie.LoadState(filepath.Join(t.TempDir(), "state.json"))
+			require.NoError(t, err, "failed to load state")
+			processor := cookie.NewProcessor(&sliceParser{}, tt.opt)
+
+			_, err = processor.FindMostActiveAccumulated(filename, "2018-12-09", state)
+
+			assert.ErrorContains(t, err, tt.errorContains, "error mismatch")
+			assert.Empty(t, state.Files, "nothing should be accumulated")
+		})
+	}
+}
+
+func TestLoadState_Missing(t *testing.T) {
+	state, err := cookie.LoadState(filepath.Join(t.TempDir(), "missing.json"))
+
+	assert.NoError(t, err, "a missing state should not be an error")
+	assert.Empty(t, state.Files, "a missing state should be empty")
+	assert.NotNil(t, state.Counts, "counts should be ready to accumulate into")
+}
+
+func TestLoadState_Corrupt(t *testing.T) {
+	statePath := filepath.Join(t.TempDir(), "state.json")
+	require.NoError(t, os.WriteFile(statePath, []byte("{not json"), 0o600), "failed to write state")
+
+	_, err := cookie.LoadState(statePath)
+
+	assert.ErrorContains(t, err, "failed to parse state", "corrupt state should be reported")
+}
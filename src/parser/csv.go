@@ -2,107 +2,1177 @@ package parser
 
 import (
 	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"log/slog"
+	"net"
+	"net/http"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+	"unicode/utf8"
 
 	"github.com/mfenderov/most-active-cookie/src/cookie"
 )
 
 const (
 	expectedColumns = 2
+	defaultHeader   = "cookie,timestamp"
+	byteOrderMark   = "\uFEFF"
+	comma           = ','
+	defaultMinYear  = 2000
+	defaultMaxYear  = 2100
+	// defaultMaxLineSize is well above bufio.Scanner's 64KB default, leaving
+	// room for very long cookie names.
+	defaultMaxLineSize = 1 << 20
+	// cancelCheckInterval is how many lines StreamFileContext reads between
+	// checks for cancellation.
+	cancelCheckInterval = 1024
 )
 
-type CSVParser struct{}
+// sniffedDelimiters are tried, in order, by WithAutoDetectDelimiter.
+var sniffedDelimiters = []byte{',', '\t', ';'}
 
-func NewCSVParser() *CSVParser {
-	return &CSVParser{}
+// TimestampMode selects how the timestamp column is interpreted.
+type TimestampMode int
+
+const (
+	// TimestampRFC3339 expects ISO-8601 timestamps such as 2018-12-09T14:19:00+00:00.
+	TimestampRFC3339 TimestampMode = iota
+	// TimestampUnixMilli expects integer milliseconds since the Unix epoch.
+	TimestampUnixMilli
+	// TimestampUnixSec expects integer seconds since the Unix epoch.
+	TimestampUnixSec
+)
+
+type CSVParser struct {
+	acceptedHeaders []string
+	timestampMode   TimestampMode
+	layouts         []string
+	maxLines        int
+	maxLineSize     int
+	assumedLocation *time.Location
+	skipHeaders     bool
+	delimiter       byte
+	autoDelimiter   bool
+	weightColumn    string
+	fallbackColumns []string
+	recordSep       byte
+	minYear         int
+	maxYear         int
+	commentPrefix   byte
+	noHeader        bool
+	skipInvalid     bool
+	onInvalidLine   func(lineNum int, err error)
+	strictUTF8      bool
+	foldCase        bool
+	column          string             // extra column reported by StreamFileColumn
+	onColumn        func(value string) // receives column's value before each entry
+	splitRecord     recordSplitter     // set for records that are not delimited
+}
+
+// recordSplitter extracts the trimmed cookie and timestamp of a line in a
+// format other than delimited text, reporting the 1-based field at fault with
+// an error.
+type recordSplitter func(line string) (cookieID, timestamp string, field int, err error)
+
+// Option configures a CSVParser.
+type Option func(*CSVParser)
+
+// WithTimestampMode sets how timestamps are parsed. Epoch modes populate
+// LogEntry.Time in UTC so date bucketing works on the numeric values.
+func WithTimestampMode(mode TimestampMode) Option {
+	return func(p *CSVParser) {
+		p.timestampMode = mode
+	}
+}
+
+// WithTimestampLayouts accepts timestamps in any of layouts, Go reference
+// layouts such as "2006-01-02 15:04:05" or time.RFC3339, tried in order.
+// Parsed timestamps populate LogEntry.Time in UTC, so dates are derived from
+// the same layout. Layouts without a zone are read as UTC, or as local times
+// in the WithAssumedLocation zone when one is set.
+func WithTimestampLayouts(layouts ...string) Option {
+	return func(p *CSVParser) {
+		p.layouts = layouts
+	}
+}
+
+// WithAcceptedHeaders sets the header rows the parser accepts, each naming the
+// cookie column and then the timestamp column, as in "cookie,timestamp". A
+// file's header matches when it has both columns, in either order and among
+// any others. Names are compared after trimming whitespace and lowercasing.
+func WithAcceptedHeaders(headers []string) Option {
+	return func(p *CSVParser) {
+		p.acceptedHeaders = make([]string, 0, len(headers))
+		for _, h := range headers {
+			p.acceptedHeaders = append(p.acceptedHeaders, strings.TrimSpace(strings.ToLower(h)))
+		}
+	}
+}
+
+// WithMaxLines aborts streaming with an error once more than n lines (header
+// included) have been read. Zero means unlimited.
+func WithMaxLines(n int) Option {
+	return func(p *CSVParser) {
+		p.maxLines = n
+	}
+}
+
+// WithMaxLineSize sets the longest line, in bytes, the parser accepts; longer
+// lines fail the scan. The default is 1MB.
+func WithMaxLineSize(n int) Option {
+	return func(p *CSVParser) {
+		p.maxLineSize = n
+	}
+}
+
+// WithAssumedLocation declares that timestamps carry no UTC offset and are
+// local times in loc (e.g. 2018-12-09T14:19:00). They are converted to UTC
+// into LogEntry.Time. Timestamps that do carry an offset are rejected so that
+// mixed data cannot be bucketed inconsistently.
+func WithAssumedLocation(loc *time.Location) Option {
+	return func(p *CSVParser) {
+		p.assumedLocation = loc
+	}
 }
 
+// WithSkipRepeatedHeaders lets naively concatenated files (cat a.csv b.csv)
+// parse: a UTF-8 byte order mark at the start of any line is stripped and data
+// lines that match an accepted header are skipped. Off by default because a
+// legitimate cookie could equal a header value.
+func WithSkipRepeatedHeaders() Option {
+	return func(p *CSVParser) {
+		p.skipHeaders = true
+	}
+}
+
+// WithDelimiter splits fields on delimiter (e.g. '\t', ';' or '|') instead of
+// on commas. The header is still expected to name the cookie and timestamp
+// columns, separated by the same delimiter.
+func WithDelimiter(delimiter byte) Option {
+	return func(p *CSVParser) {
+		p.delimiter = delimiter
+	}
+}
+
+// WithAutoDetectDelimiter detects from the header row whether a file is comma-,
+// tab- or semicolon-separated, picking the delimiter that splits the header
+// into an accepted column set. The configured delimiter, comma by default, is
+// used when none or several match.
+func WithAutoDetectDelimiter() Option {
+	return func(p *CSVParser) {
+		p.autoDelimiter = true
+	}
+}
+
+// WithWeightColumn accepts an optional column, named name in the header
+// (e.g. cookie,timestamp,weight), holding how many events a row stands for.
+// Counts then grow by the weight instead of by one. Rows with a missing or
+// invalid weight count as a single event, unless WithStrictUTF8 rejects the
+// invalid ones. The header may omit the column, in which case every row weighs
+// one.
+func WithWeightColumn(name string) Option {
+	return func(p *CSVParser) {
+		p.weightColumn = strings.TrimSpace(strings.ToLower(name))
+	}
+}
+
+// WithTimestampFallback reads a row's timestamp from the first non-empty of
+// columns, header column names in order of preference such as
+// []string{"event_time", "ingest_time"}, when the timestamp column of the
+// accepted header is blank. Columns the header lacks are ignored. A row blank
+// in every one of them is invalid, like any row without a timestamp: it fails
+// the scan, or is skipped under WithSkipInvalidLines.
+func WithTimestampFallback(columns []string) Option {
+	return func(p *CSVParser) {
+		p.fallbackColumns = make([]string, 0, len(columns))
+		for _, c := range columns {
+			p.fallbackColumns = append(p.fallbackColumns, strings.TrimSpace(strings.ToLower(c)))
+		}
+	}
+}
+
+// WithRecordSeparator splits records on sep (e.g. '\f' for form-feed separated
+// feeds) instead of on line endings. Whitespace around fields, including stray
+// newlines, is still trimmed.
+func WithRecordSeparator(sep byte) Option {
+	return func(p *CSVParser) {
+		p.recordSep = sep
+	}
+}
+
+// WithValidYearRange rejects entries whose timestamp year falls outside
+// [minYear, maxYear] as parse errors, catching corrupt feeds that emit years
+// like 0018 or 20018 which would otherwise silently never match. The default
+// range is 2000-2100; WithValidYearRange(0, 0) disables the check.
+func WithValidYearRange(minYear, maxYear int) Option {
+	return func(p *CSVParser) {
+		p.minYear = minYear
+		p.maxYear = maxYear
+	}
+}
+
+// WithCommentPrefix skips lines starting with prefix, such as '#', after
+// leading whitespace is trimmed. Comments may precede the header, which is
+// then the first line that is not a comment.
+func WithCommentPrefix(prefix byte) Option {
+	return func(p *CSVParser) {
+		p.commentPrefix = prefix
+	}
+}
+
+// WithoutHeader reads files that have no header row, treating the first line
+// as data. Without a header to inspect, WithAutoDetectDelimiter has no effect.
+func WithoutHeader() Option {
+	return func(p *CSVParser) {
+		p.noHeader = true
+	}
+}
+
+// WithSkipInvalidLines logs and skips data lines that fail to parse instead of
+// aborting on the first one, for dirty production logs. onInvalidLine, when
+// not nil, is called with the line number and *ParseError of every skipped
+// line, e.g. to count or report them. The total skipped is logged once
+// streaming completes. A file without a single valid line is still an error.
+func WithSkipInvalidLines(onInvalidLine func(lineNum int, err error)) Option {
+	return func(p *CSVParser) {
+		p.skipInvalid = true
+		p.onInvalidLine = onInvalidLine
+	}
+}
+
+// WithStrictUTF8 rejects lines whose cookie ID is not valid UTF-8, or whose
+// weight is not a positive integer, as parse errors. By default invalid byte
+// sequences are passed through unchanged and invalid weights count once.
+func WithStrictUTF8() Option {
+	return func(p *CSVParser) {
+		p.strictUTF8 = true
+	}
+}
+
+// WithCaseInsensitiveCookies lower-cases cookie IDs, so IDs differing only in
+// case count as one cookie.
+func WithCaseInsensitiveCookies() Option {
+	return func(p *CSVParser) {
+		p.foldCase = true
+	}
+}
+
+func NewCSVParser(opts ...Option) *CSVParser {
+	p := &CSVParser{
+		acceptedHeaders: []string{defaultHeader},
+		delimiter:       comma,
+		maxLineSize:     defaultMaxLineSize,
+		minYear:         defaultMinYear,
+		maxYear:         defaultMaxYear,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Stdin is the filename that makes StreamFile read from standard input, as in
+// most-active-cookie -f - -d 2018-12-09.
+const Stdin = "-"
+
+// IsURL reports whether filename refers to an HTTP(S) resource rather than a
+// local file.
+func IsURL(filename string) bool {
+	return strings.HasPrefix(filename, "http://") || strings.HasPrefix(filename, "https://")
+}
+
+// StreamFile streams entries from a local file or, when filename is an HTTP(S)
+// URL, from the response body. A filename of Stdin reads standard input, which
+// can only be streamed once.
 func (p *CSVParser) StreamFile(filename string, processor cookie.EntryProcessor) error {
-	file, err := os.Open(filename) //nolint:gosec
+	return p.StreamFileContext(context.Background(), filename, processor)
+}
+
+// StreamFileContext is StreamFile stopping with ctx's error, checked every
+// cancelCheckInterval lines, once ctx is cancelled.
+func (p *CSVParser) StreamFileContext(ctx context.Context, filename string, processor cookie.EntryProcessor) error {
+	_, err := p.streamFile(ctx, filename, processor)
+	return err
+}
+
+// StreamFileStats is StreamFile also reporting what was read, e.g. to confirm
+// that a large import was consumed in full. The statistics cover the lines
+// read before an error, if any.
+func (p *CSVParser) StreamFileStats(filename string, processor cookie.EntryProcessor) (cookie.ScanStats, error) {
+	return p.streamFile(context.Background(), filename, processor)
+}
+
+func (p *CSVParser) streamFile(ctx context.Context, filename string, processor cookie.EntryProcessor) (cookie.ScanStats, error) {
+	if err := ctx.Err(); err != nil {
+		return cookie.ScanStats{}, err
+	}
+	file, err := open(ctx, filename)
 	if err != nil {
-		return fmt.Errorf("failed to open file %s: %w", filename, err)
+		return cookie.ScanStats{}, fmt.Errorf("failed to open file %s: %w", filename, err)
 	}
 	defer file.Close()
 
-	scanner := bufio.NewScanner(file)
-	lineNum := 0
-	entriesProcessed := 0
+	return p.stream(ctx, file, filename, processor)
+}
 
-	if scanner.Scan() {
-		lineNum++
-		header := scanner.Text()
-		if !isValidHeader(header) {
-			return fmt.Errorf("invalid header format at line %d: expected 'cookie,timestamp', got '%s'", lineNum, header)
+// StreamFileColumn is StreamFile also handing processor the value of column,
+// another header column such as a region or user agent, for each entry. The
+// column is matched like the accepted headers, ignoring case; a header without
+// it is an error, as is a parser configured WithoutHeader.
+func (p *CSVParser) StreamFileColumn(filename, column string, processor func(entry cookie.LogEntry, value string) error) error {
+	if p.noHeader {
+		return fmt.Errorf("column %q cannot be found without a header row", column)
+	}
+
+	var value string
+	withColumn := *p
+	withColumn.column = strings.ToLower(strings.TrimSpace(column))
+	withColumn.onColumn = func(v string) { value = v }
+	_, err := withColumn.streamFile(context.Background(), filename, func(entry cookie.LogEntry) error {
+		return processor(entry, value)
+	})
+	return err
+}
+
+// StreamFS streams entries from the named file in fsys, decoupling parsing from
+// the OS filesystem (e.g. embed.FS or testing/fstest.MapFS).
+func (p *CSVParser) StreamFS(fsys fs.FS, name string, processor cookie.EntryProcessor) error {
+	file, err := fsys.Open(name)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s: %w", name, err)
+	}
+	defer file.Close()
+
+	_, err = p.stream(context.Background(), file, name, processor)
+	return err
+}
+
+// StreamReader streams entries from r, so in-memory buffers and network
+// streams can be parsed without a file. Errors refer to the input as readerName.
+func (p *CSVParser) StreamReader(r io.Reader, processor cookie.EntryProcessor) error {
+	_, err := p.stream(context.Background(), r, readerName, processor)
+	return err
+}
+
+// readerName stands in for the filename in messages about StreamReader input.
+const readerName = "<reader>"
+
+func open(ctx context.Context, filename string) (io.ReadCloser, error) {
+	if IsURL(filename) {
+		return openURL(ctx, filename)
+	}
+	if filename == Stdin {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(filename) //nolint:gosec
+}
+
+// urlTimeout bounds connecting to a URL input and waiting for its response
+// headers. Reading the body, which may be a large log, is only bounded by the
+// scan's context.
+const urlTimeout = 30 * time.Second
+
+// httpClient fetches URL inputs. Gzip content-encoding is decoded
+// transparently by its transport.
+var httpClient = newHTTPClient()
+
+func newHTTPClient() *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = urlTimeout
+	transport.TLSHandshakeTimeout = urlTimeout
+	transport.DialContext = (&net.Dialer{Timeout: urlTimeout}).DialContext
+	return &http.Client{Transport: transport}
+}
+
+// openURL fetches url over HTTP, abandoning the request, body included, once
+// ctx is cancelled.
+func openURL(ctx context.Context, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected HTTP status %s", resp.Status)
+	}
+	return resp.Body, nil
+}
+
+// newScanner returns a scanner over r that yields one record per token.
+func (p *CSVParser) newScanner(r io.Reader, endings *lineEndings) *bufio.Scanner {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, p.maxLineSize)
+	switch {
+	case p.recordSep != 0:
+		scanner.Split(splitOn(p.recordSep))
+	case endings != nil:
+		scanner.Split(endings.track(scanLines))
+	default:
+		scanner.Split(scanLines)
+	}
+	return scanner
+}
+
+// lineEndings records which line ending styles a scan has seen.
+type lineEndings uint8
+
+const (
+	endingLF lineEndings = 1 << iota
+	endingCRLF
+	endingCR
+)
+
+// mixed reports whether more than one line ending style was seen.
+func (e lineEndings) mixed() bool {
+	return e&(e-1) != 0
+}
+
+// track wraps split, a line splitter returning each line without its ending,
+// to record the ending of every line it returns.
+func (e *lineEndings) track(split bufio.SplitFunc) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := split(data, atEOF)
+		switch advance - len(token) {
+		case 2:
+			*e |= endingCRLF
+		case 1:
+			if data[len(token)] == '\n' {
+				*e |= endingLF
+			} else {
+				*e |= endingCR
+			}
+		}
+		return advance, token, err
+	}
+}
+
+// scanLines is a bufio.SplitFunc that ends a line at \n, \r\n or a lone \r,
+// so files with classic Mac line endings are read line by line too.
+func scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+	end := bytes.IndexByte(data, '\n')
+	search := data
+	if end >= 0 {
+		search = data[:end]
+	}
+	if cr := bytes.IndexByte(search, '\r'); cr >= 0 {
+		if cr+1 == len(data) && !atEOF {
+			// A \r at the end of the buffer may be the first half of a \r\n.
+			return 0, nil, nil
+		}
+		if cr+1 < len(data) && data[cr+1] == '\n' {
+			return cr + 2, data[:cr], nil
+		}
+		return cr + 1, data[:cr], nil
+	}
+	if end >= 0 {
+		return end + 1, data[:end], nil
+	}
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// splitOn is a bufio.SplitFunc that tokenizes on sep. A final record without a
+// trailing separator is still returned.
+func splitOn(sep byte) bufio.SplitFunc {
+	return func(data []byte, atEOF bool) (int, []byte, error) {
+		if atEOF && len(data) == 0 {
+			return 0, nil, nil
+		}
+		if i := bytes.IndexByte(data, sep); i >= 0 {
+			return i + 1, data[:i], nil
 		}
+		if atEOF {
+			return len(data), data, nil
+		}
+		return 0, nil, nil
+	}
+}
+
+// gzipMagic starts every gzip stream.
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// decompress returns r, transparently gunzipped when it starts with the gzip
+// magic bytes, so archived .csv.gz logs stream like plain ones. Concatenated
+// gzip members, as produced by cat a.gz b.gz, are read as one stream.
+func decompress(r io.Reader) (io.Reader, error) {
+	buffered := bufio.NewReader(r)
+	magic, err := buffered.Peek(len(gzipMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	if !bytes.Equal(magic, gzipMagic) {
+		return buffered, nil
+	}
+	gz, err := gzip.NewReader(buffered)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gzip data: %w", err)
+	}
+	gz.Multistream(true)
+	return gz, nil
+}
+
+func (p *CSVParser) stream(ctx context.Context, r io.Reader, filename string, processor cookie.EntryProcessor) (cookie.ScanStats, error) {
+	start := time.Now()
+	var stats cookie.ScanStats
+	r, err := decompress(r)
+	if err != nil {
+		return stats, fmt.Errorf("error reading file %s: %w", filename, err)
+	}
+	var endings lineEndings
+	scanner := p.newScanner(r, &endings)
+	layout := p.positionalLayout()
+
+	if !p.noHeader {
+		layout, err = p.readHeader(scanner, filename, &stats)
+		if err != nil {
+			return stats, err
+		}
+		stats.Comments = stats.Lines - 1
 	}
 
 	for scanner.Scan() {
-		lineNum++
-		line := strings.TrimSpace(scanner.Text())
+		stats.Lines++
+		lineNum := stats.Lines
+		if p.maxLines > 0 && lineNum > p.maxLines {
+			return stats, fmt.Errorf("exceeded maximum of %d lines in file %s", p.maxLines, filename)
+		}
+		if lineNum%cancelCheckInterval == 0 {
+			if err := ctx.Err(); err != nil {
+				return stats, fmt.Errorf("stopped reading %s at line %d: %w", filename, lineNum, err)
+			}
+		}
+		line := p.trimLine(scanner.Text())
+		if lineNum == 1 {
+			// Without a header the byte order mark precedes the first entry.
+			line = stripBOM(line, &stats)
+		}
 
 		if line == "" {
+			stats.Blank++
 			continue
 		}
+		if p.isComment(line) {
+			stats.Comments++
+			continue
+		}
+
+		if p.skipHeaders {
+			line = stripBOM(line, &stats)
+			if _, ok := p.headerLayout(line, layout.delimiter); ok {
+				slog.Debug("skipping repeated header", "line", lineNum)
+				continue
+			}
+		}
 
-		entry, err := p.parseLine(line)
+		entry, err := p.parseLine(line, lineNum, layout, &stats)
 		if err != nil {
-			return fmt.Errorf("error parsing line %d: %w", lineNum, err)
+			if !p.skipInvalid {
+				return stats, err
+			}
+			stats.Invalid++
+			warn(&stats, cookie.WarningInvalidLine, "skipped invalid line: %v", err)
+			slog.Warn("skipping invalid line", "filename", filename, "line", lineNum, "error", err)
+			if p.onInvalidLine != nil {
+				p.onInvalidLine(lineNum, err)
+			}
+			continue
 		}
 
+		if p.onColumn != nil {
+			p.onColumn(columnValue(line, layout))
+		}
 		if err := processor(entry); err != nil {
 			if errors.Is(err, cookie.ErrPastTargetDate) {
+				stats.StoppedEarly = true
+				warn(&stats, cookie.WarningStoppedEarly, "stopped reading at line %d, the first entry past the target date", lineNum)
 				break
 			}
-			return fmt.Errorf("processing error at line %d: %w", lineNum, err)
+			if errors.Is(err, cookie.ErrSkipEntry) {
+				stats.Skipped++
+				continue
+			}
+			return stats, fmt.Errorf("processing error at line %d: %w", lineNum, err)
 		}
 
-		entriesProcessed++
+		stats.Entries++
 	}
 
 	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("error reading file %s: %w", filename, err)
+		if errors.Is(err, bufio.ErrTooLong) {
+			return stats, fmt.Errorf("error reading file %s: line %d is longer than the maximum of %d bytes: %w", filename, stats.Lines+1, p.maxLineSize, err)
+		}
+		return stats, fmt.Errorf("error reading file %s: %w", filename, err)
 	}
 
-	if entriesProcessed == 0 {
-		return fmt.Errorf("no valid entries found in file %s", filename)
+	stats.MixedLineEndings = endings.mixed()
+	if stats.MixedLineEndings {
+		warn(&stats, cookie.WarningMixedLineEndings, "lines end in more than one of \\n, \\r\\n and \\r")
+		slog.Debug("file mixes line ending styles", "filename", filename)
 	}
 
-	slog.Info("successfully streamed CSV file", "filename", filename, "entriesProcessed", entriesProcessed, "linesProcessed", lineNum)
-	return nil
+	// Stopping on the first data row means the file has data, all of it past
+	// the target date; that is an empty result, not a malformed file.
+	if stats.Entries == 0 && stats.Skipped == 0 && !stats.StoppedEarly {
+		return stats, fmt.Errorf("no valid entries found in file %s", filename)
+	}
+
+	elapsed := time.Since(start)
+	slog.Info("successfully streamed CSV file", "filename", filename, "entriesProcessed", stats.Entries, "entriesSkipped", stats.Skipped, "invalidLines", stats.Invalid, "linesProcessed", stats.Lines,
+		"duration", elapsed.Round(time.Microsecond), "linesPerSecond", int(float64(stats.Lines)/elapsed.Seconds()))
+	return stats, nil
 }
 
-func (p *CSVParser) parseLine(line string) (cookie.LogEntry, error) {
-	parts := strings.Split(line, ",")
-	if len(parts) != expectedColumns {
-		return cookie.LogEntry{}, fmt.Errorf("invalid CSV format: expected %d columns, got %d", expectedColumns, len(parts))
+// trimLine strips the whitespace around a line. Records split by position keep
+// their leading blanks, which belong to the first column.
+func (p *CSVParser) trimLine(line string) string {
+	if p.splitRecord != nil {
+		return strings.TrimRight(line, " \t\r")
 	}
+	return strings.TrimSpace(line)
+}
 
-	cookieID := strings.TrimSpace(parts[0])
-	timestampStr := strings.TrimSpace(parts[1])
+// recordLayout locates the fields of a data line, as named by the header.
+type recordLayout struct {
+	delimiter byte
+	columns   int // fields in the header
+	cookie    int // 0-based field indices
+	timestamp int
+	weight    int // -1 without a weight column
+	column    int // -1 unless StreamFileColumn reports a column
+	// fallbacks holds the indices of the WithTimestampFallback columns, tried
+	// in order when the timestamp field is blank.
+	fallbacks []int
+}
+
+// positional reports whether the layout is the plain cookie,timestamp order,
+// optionally followed by the weight column, which lines are split in place
+// without allocating.
+func (l recordLayout) positional() bool {
+	return l.cookie == 0 && l.timestamp == 1 && l.column < 0 && len(l.fallbacks) == 0 &&
+		(l.columns == expectedColumns || l.weight == expectedColumns)
+}
+
+// positionalLayout is the layout of files without a header: cookie and
+// timestamp first, then the weight column when one is configured.
+func (p *CSVParser) positionalLayout() recordLayout {
+	layout := recordLayout{delimiter: p.delimiter, columns: expectedColumns, cookie: 0, timestamp: 1, weight: -1, column: -1}
+	if p.weightColumn != "" {
+		layout.columns++
+		layout.weight = expectedColumns
+	}
+	return layout
+}
+
+// headerLayout finds the columns of an accepted header among the columns of
+// header, split on delimiter. The cookie and timestamp columns may come in
+// either order and among other columns, which are ignored.
+func (p *CSVParser) headerLayout(header string, delimiter byte) (recordLayout, bool) {
+	names := strings.Split(strings.ToLower(header), string(delimiter))
+	for i, name := range names {
+		names[i] = strings.TrimSpace(name)
+	}
+
+	weight := -1
+	if p.weightColumn != "" {
+		weight = slices.Index(names, p.weightColumn)
+	}
+	column := -1
+	if p.column != "" {
+		column = slices.Index(names, p.column)
+	}
+	var fallbacks []int
+	for _, name := range p.fallbackColumns {
+		if i := slices.Index(names, name); i >= 0 {
+			fallbacks = append(fallbacks, i)
+		}
+	}
+	for _, accepted := range p.acceptedHeaders {
+		cookieName, timestampName, ok := strings.Cut(accepted, ",")
+		if !ok {
+			continue
+		}
+		cookieIndex := slices.Index(names, strings.TrimSpace(cookieName))
+		timestampIndex := slices.Index(names, strings.TrimSpace(timestampName))
+		if cookieIndex >= 0 && timestampIndex >= 0 {
+			return recordLayout{
+				delimiter: delimiter,
+				columns:   len(names),
+				cookie:    cookieIndex,
+				timestamp: timestampIndex,
+				weight:    weight,
+				column:    column,
+				fallbacks: fallbacks,
+			}, true
+		}
+	}
+	return recordLayout{}, false
+}
+
+// ParseError reports a data line that could not be parsed.
+type ParseError struct {
+	Line   int    // 1-based line number, counting the header
+	Column int    // 1-based field at fault, or 0 when the line as a whole is malformed
+	Raw    string // the offending line
+	Cause  error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("error parsing line %d: %v", e.Line, e.Cause)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Cause
+}
+
+// readHeader consumes the header row, and any comments above it, returning the
+// layout of the data lines and the number of lines read.
+func (p *CSVParser) readHeader(scanner *bufio.Scanner, filename string, stats *cookie.ScanStats) (recordLayout, error) {
+	header, err := p.scanHeader(scanner, filename, stats)
+	if err != nil {
+		return recordLayout{}, err
+	}
+
+	layout, ok := p.matchHeader(header, filename, stats)
+	if !ok {
+		return recordLayout{}, fmt.Errorf("invalid header format at line %d: expected one of %q, got '%s'", stats.Lines, p.acceptedHeaders, header)
+	}
+	if p.column != "" && layout.column < 0 {
+		return recordLayout{}, fmt.Errorf("no column %q in the header of %s", p.column, filename)
+	}
+	return layout, nil
+}
+
+// scanHeader returns the first line that is not a comment, counting the lines
+// read in stats.
+func (p *CSVParser) scanHeader(scanner *bufio.Scanner, filename string, stats *cookie.ScanStats) (string, error) {
+	for {
+		if !scanner.Scan() {
+			if err := scanner.Err(); err != nil {
+				return "", fmt.Errorf("error reading file %s: %w", filename, err)
+			}
+			if stats.Lines > 0 {
+				return "", fmt.Errorf("no header row in %s: the file only has comments", filename)
+			}
+			return "", fmt.Errorf("file is empty: no header row in %s", filename)
+		}
+		stats.Lines++
+		header := scanner.Text()
+		if !p.isComment(strings.TrimSpace(strings.TrimPrefix(header, byteOrderMark))) {
+			return header, nil
+		}
+	}
+}
+
+// matchHeader finds the layout of header as StreamFile reads it: a byte order
+// mark is only stripped under WithSkipRepeatedHeaders, and the delimiter only
+// sniffed under WithAutoDetectDelimiter.
+func (p *CSVParser) matchHeader(header, filename string, stats *cookie.ScanStats) (recordLayout, bool) {
+	if p.skipHeaders {
+		header = stripBOM(header, stats)
+	}
+	delimiter := p.delimiter
+	if p.autoDelimiter {
+		delimiter = p.sniffDelimiter(header)
+		slog.Debug("detected delimiter", "filename", filename, "delimiter", string(delimiter))
+	}
+	return p.headerLayout(header, delimiter)
+}
+
+// maxWarnings caps ScanStats.Warnings, so a file of bad lines cannot grow it
+// without bound.
+const maxWarnings = 100
+
+// warn adds a warning to stats unless the cap is reached.
+func warn(stats *cookie.ScanStats, code cookie.WarningCode, format string, args ...any) {
+	if len(stats.Warnings) < maxWarnings {
+		stats.Warnings = append(stats.Warnings, cookie.Warning{Code: code, Message: fmt.Sprintf(format, args...)})
+	}
+}
+
+// stripBOM removes a leading byte order mark from line, warning about it the
+// first time in a scan.
+func stripBOM(line string, stats *cookie.ScanStats) string {
+	line, found := strings.CutPrefix(line, byteOrderMark)
+	if found && !slices.ContainsFunc(stats.Warnings, func(w cookie.Warning) bool { return w.Code == cookie.WarningByteOrderMark }) {
+		warn(stats, cookie.WarningByteOrderMark, "stripped a UTF-8 byte order mark")
+	}
+	return line
+}
+
+// parseLine parses a data line, returning a *ParseError when it is invalid.
+func (p *CSVParser) parseLine(line string, lineNum int, layout recordLayout, stats *cookie.ScanStats) (cookie.LogEntry, error) {
+	entry, column, err := p.parseFields(line, layout, stats)
+	if err != nil {
+		return cookie.LogEntry{}, &ParseError{Line: lineNum, Column: column, Raw: line, Cause: err}
+	}
+	return entry, nil
+}
+
+// parseFields parses a data line, reporting with an error the 1-based column
+// at fault.
+func (p *CSVParser) parseFields(line string, layout recordLayout, stats *cookie.ScanStats) (cookie.LogEntry, int, error) {
+	var cookieID, timestampStr, weightStr string
+	var err error
+	switch {
+	case p.splitRecord != nil:
+		var field int
+		if cookieID, timestampStr, field, err = p.splitRecord(line); err != nil {
+			return cookie.LogEntry{}, field, err
+		}
+	case layout.positional():
+		cookieID, timestampStr, weightStr, err = p.splitLine(line, layout.delimiter)
+	default:
+		cookieID, timestampStr, weightStr, err = splitColumns(line, layout)
+	}
+	if err != nil {
+		return cookie.LogEntry{}, 0, err
+	}
+	cookieField, timestampField := layout.cookie+1, layout.timestamp+1
 
 	if cookieID == "" {
-		return cookie.LogEntry{}, fmt.Errorf("empty cookie ID")
+		return cookie.LogEntry{}, cookieField, fmt.Errorf("empty cookie ID")
+	}
+
+	if p.strictUTF8 && !utf8.ValidString(cookieID) {
+		return cookie.LogEntry{}, cookieField, fmt.Errorf("cookie ID %q is not valid UTF-8", cookieID)
+	}
+	if p.foldCase {
+		cookieID = strings.ToLower(cookieID)
 	}
 
 	if timestampStr == "" {
-		return cookie.LogEntry{}, fmt.Errorf("empty timestamp")
+		return cookie.LogEntry{}, timestampField, fmt.Errorf("empty timestamp")
+	}
+
+	var entry cookie.LogEntry
+	switch {
+	case p.timestampMode != TimestampRFC3339:
+		entry, err = p.parseEpochEntry(cookieID, timestampStr)
+	case len(p.layouts) > 0:
+		entry, err = p.parseLayoutEntry(cookieID, timestampStr)
+	case p.assumedLocation != nil:
+		entry, err = p.parseLocalEntry(cookieID, timestampStr)
+	default:
+		err = validateTimestamp(timestampStr)
+		entry = cookie.LogEntry{Cookie: cookieID, Timestamp: timestampStr}
+	}
+	if err != nil {
+		return cookie.LogEntry{}, timestampField, err
+	}
+
+	if err := p.checkYear(entry, stats); err != nil {
+		return cookie.LogEntry{}, timestampField, err
+	}
+
+	if weightStr != "" {
+		weight, ok := parseWeight(weightStr)
+		if !ok && p.strictUTF8 {
+			return cookie.LogEntry{}, layout.weight + 1, fmt.Errorf("invalid weight '%s': expected a positive integer", weightStr)
+		}
+		entry.Weight = weight
+	}
+	return entry, 0, nil
+}
+
+// splitLine returns the trimmed cookie and timestamp fields of line and, when a
+// weight column is configured and present, the raw weight field.
+func (p *CSVParser) splitLine(line string, delimiter byte) (cookieID, timestamp, weight string, err error) {
+	maxColumns := expectedColumns
+	if p.weightColumn != "" {
+		maxColumns++
+	}
+
+	if strings.IndexByte(line, '"') >= 0 {
+		var fields []string
+		fields, err = splitQuoted(line, delimiter)
+		if err != nil {
+			return "", "", "", err
+		}
+		if len(fields) < expectedColumns || len(fields) > maxColumns {
+			return "", "", "", columnCountError(len(fields), maxColumns)
+		}
+		if len(fields) > expectedColumns {
+			weight = fields[2]
+		}
+		return strings.TrimSpace(fields[0]), strings.TrimSpace(fields[1]), weight, nil
+	}
+
+	// Slice around the delimiters instead of strings.Split to avoid
+	// allocating a slice for every line.
+	sep := strings.IndexByte(line, delimiter)
+	if sep < 0 {
+		return "", "", "", columnCountError(1, maxColumns)
+	}
+	rest := line[sep+1:]
+	columns := expectedColumns
+	if p.weightColumn != "" {
+		if weightSep := strings.IndexByte(rest, delimiter); weightSep >= 0 {
+			weight = rest[weightSep+1:]
+			rest = rest[:weightSep]
+			columns += 1 + strings.Count(weight, string(delimiter))
+		}
+	} else {
+		columns += strings.Count(rest, string(delimiter))
+	}
+	if columns > maxColumns {
+		return "", "", "", columnCountError(columns, maxColumns)
+	}
+
+	return strings.TrimSpace(line[:sep]), strings.TrimSpace(rest), weight, nil
+}
+
+// splitColumns picks the cookie, timestamp and weight fields of line by the
+// header's column positions. Lines may omit trailing columns the layout does
+// not need, but may not have more columns than the header.
+func splitColumns(line string, layout recordLayout) (cookieID, timestamp, weight string, err error) {
+	var fields []string
+	if strings.IndexByte(line, '"') >= 0 {
+		fields, err = splitQuoted(line, layout.delimiter)
+		if err != nil {
+			return "", "", "", err
+		}
+	} else {
+		fields = strings.Split(line, string(layout.delimiter))
+	}
+
+	needed := max(layout.cookie, layout.timestamp) + 1
+	if len(fields) < needed || len(fields) > layout.columns {
+		return "", "", "", fmt.Errorf("invalid CSV format: expected %d columns, got %d", layout.columns, len(fields))
+	}
+	if layout.weight >= 0 && layout.weight < len(fields) {
+		weight = fields[layout.weight]
+	}
+	timestamp = strings.TrimSpace(fields[layout.timestamp])
+	for _, i := range layout.fallbacks {
+		if timestamp != "" {
+			break
+		}
+		if i < len(fields) {
+			timestamp = strings.TrimSpace(fields[i])
+		}
+	}
+	return strings.TrimSpace(fields[layout.cookie]), timestamp, weight, nil
+}
+
+// columnValue returns the trimmed value of the layout's reported column in an
+// already parsed line, or "" when the line is short of it.
+func columnValue(line string, layout recordLayout) string {
+	var fields []string
+	if strings.IndexByte(line, '"') >= 0 {
+		fields, _ = splitQuoted(line, layout.delimiter)
+	} else {
+		fields = strings.Split(line, string(layout.delimiter))
+	}
+	if layout.column >= len(fields) {
+		return ""
+	}
+	return strings.TrimSpace(fields[layout.column])
+}
+
+func columnCountError(got, maxColumns int) error {
+	expected := expectedColumns
+	if got > maxColumns {
+		expected = maxColumns
+	}
+	return fmt.Errorf("invalid CSV format: expected %d columns, got %d", expected, got)
+}
+
+// splitQuoted splits a line into RFC 4180 fields: a field wrapped in double
+// quotes may contain the delimiter, and "" inside it stands for one quote.
+// Records are read line by line, so a quoted field still open at the end of
+// the line is rejected rather than continued on the next line.
+func splitQuoted(line string, delimiter byte) ([]string, error) {
+	var fields []string
+	for {
+		field := strings.TrimLeft(line, " ")
+		if !strings.HasPrefix(field, `"`) {
+			value := line
+			end := strings.IndexByte(line, delimiter)
+			if end >= 0 {
+				value = line[:end]
+			}
+			if strings.IndexByte(value, '"') >= 0 {
+				return nil, fmt.Errorf("invalid CSV format: bare quote in unquoted field '%s'", value)
+			}
+			fields = append(fields, value)
+			if end < 0 {
+				return fields, nil
+			}
+			line = line[end+1:]
+			continue
+		}
+
+		var value strings.Builder
+		i := 1
+		for {
+			quote := strings.IndexByte(field[i:], '"')
+			if quote < 0 {
+				return nil, fmt.Errorf("invalid CSV format: unterminated quoted field (quoted fields cannot span lines)")
+			}
+			value.WriteString(field[i : i+quote])
+			i += quote + 1
+			if i < len(field) && field[i] == '"' {
+				value.WriteByte('"')
+				i++
+				continue
+			}
+			break
+		}
+		fields = append(fields, value.String())
+
+		rest := strings.TrimLeft(field[i:], " ")
+		if rest == "" {
+			return fields, nil
+		}
+		if rest[0] != delimiter {
+			return nil, fmt.Errorf("invalid CSV format: unexpected '%c' after closing quote", rest[0])
+		}
+		line = rest[1:]
+	}
+}
+
+// checkYear rejects entries dated outside the configured year range, counting
+// them into stats.OutOfRangeYear. The year is taken from the parsed time when
+// present, else from the digits before the first '-' of the raw timestamp.
+func (p *CSVParser) checkYear(entry cookie.LogEntry, stats *cookie.ScanStats) error {
+	if p.minYear == 0 && p.maxYear == 0 {
+		return nil
+	}
+
+	var year int
+	if !entry.Time.IsZero() {
+		year = entry.Time.Year()
+	} else {
+		end := strings.IndexByte(entry.Timestamp, '-')
+		if end < 0 {
+			end = len(entry.Timestamp)
+		}
+		parsed, err := strconv.Atoi(entry.Timestamp[:end])
+		if err != nil {
+			return fmt.Errorf("invalid timestamp format '%s': expected a numeric year", entry.Timestamp)
+		}
+		year = parsed
 	}
 
-	if len(timestampStr) < 10 || !strings.Contains(timestampStr, "T") {
-		return cookie.LogEntry{}, fmt.Errorf("invalid timestamp format '%s': expected YYYY-MM-DDTHH:mm:ss format", timestampStr)
+	if year < p.minYear || year > p.maxYear {
+		stats.OutOfRangeYear++
+		return fmt.Errorf("timestamp '%s' has year %d outside the valid range %d-%d", entry.Timestamp, year, p.minYear, p.maxYear)
+	}
+	return nil
+}
+
+// parseWeight reads a weight column value, reporting whether it is valid.
+// Invalid and non-positive weights yield zero, which counts as a single event.
+func parseWeight(value string) (int, bool) {
+	weight, err := strconv.Atoi(strings.TrimSpace(value))
+	if err != nil || weight < 1 {
+		return 0, false
+	}
+	return weight, true
+}
+
+const localTimestampLayout = "2006-01-02T15:04:05"
+
+func (p *CSVParser) parseLocalEntry(cookieID, timestampStr string) (cookie.LogEntry, error) {
+	timestamp, err := time.ParseInLocation(localTimestampLayout, timestampStr, p.assumedLocation)
+	if err != nil {
+		if _, rfcErr := time.Parse(time.RFC3339, timestampStr); rfcErr == nil {
+			return cookie.LogEntry{}, fmt.Errorf("timestamp '%s' carries a UTC offset but timestamps are assumed to be in %s", timestampStr, p.assumedLocation)
+		}
+		return cookie.LogEntry{}, fmt.Errorf("invalid timestamp format '%s': expected YYYY-MM-DDTHH:mm:ss without offset", timestampStr)
 	}
 
 	return cookie.LogEntry{
 		Cookie:    cookieID,
 		Timestamp: timestampStr,
+		Time:      timestamp.UTC(),
 	}, nil
 }
 
-func isValidHeader(header string) bool {
-	expected := "cookie,timestamp"
-	return strings.TrimSpace(strings.ToLower(header)) == expected
+func validateTimestamp(timestampStr string) error {
+	if _, err := time.Parse(time.RFC3339, timestampStr); err != nil {
+		return fmt.Errorf("invalid timestamp format '%s': expected RFC3339 such as 2018-12-09T14:19:00+00:00", timestampStr)
+	}
+	return nil
+}
+
+// parseLayoutEntry parses timestampStr with the first matching configured
+// layout.
+func (p *CSVParser) parseLayoutEntry(cookieID, timestampStr string) (cookie.LogEntry, error) {
+	loc := time.UTC
+	if p.assumedLocation != nil {
+		loc = p.assumedLocation
+	}
+	for _, layout := range p.layouts {
+		if timestamp, err := time.ParseInLocation(layout, timestampStr, loc); err == nil {
+			return cookie.LogEntry{
+				Cookie:    cookieID,
+				Timestamp: timestampStr,
+				Time:      timestamp.UTC(),
+			}, nil
+		}
+	}
+	return cookie.LogEntry{}, fmt.Errorf("invalid timestamp format '%s': expected one of the layouts %q", timestampStr, p.layouts)
+}
+
+func (p *CSVParser) parseEpochEntry(cookieID, timestampStr string) (cookie.LogEntry, error) {
+	value, err := strconv.ParseInt(timestampStr, 10, 64)
+	if err != nil {
+		return cookie.LogEntry{}, fmt.Errorf("invalid epoch timestamp '%s': expected an integer", timestampStr)
+	}
+
+	var timestamp time.Time
+	if p.timestampMode == TimestampUnixMilli {
+		timestamp = time.UnixMilli(value)
+	} else {
+		timestamp = time.Unix(value, 0)
+	}
+
+	return cookie.LogEntry{
+		Cookie:    cookieID,
+		Timestamp: timestampStr,
+		Time:      timestamp.UTC(),
+	}, nil
+}
+
+// sniffDelimiter returns the only candidate delimiter that turns header into an
+// accepted header, or the configured delimiter when the choice is ambiguous or
+// nothing matches.
+func (p *CSVParser) sniffDelimiter(header string) byte {
+	var matches []byte
+	for _, candidate := range sniffedDelimiters {
+		if strings.IndexByte(header, candidate) < 0 {
+			continue
+		}
+		if _, ok := p.headerLayout(header, candidate); ok {
+			matches = append(matches, candidate)
+		}
+	}
+	if len(matches) != 1 {
+		return p.delimiter
+	}
+	return matches[0]
+}
+
+// isComment reports whether a trimmed line is a comment.
+func (p *CSVParser) isComment(line string) bool {
+	return p.commentPrefix != 0 && line != "" && line[0] == p.commentPrefix
 }
@@ -1,10 +1,19 @@
 package parser_test
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"slices"
+	"strings"
 	"testing"
+	"testing/fstest"
+	"time"
 
 	"github.com/mfenderov/most-active-cookie/src/cookie"
 	"github.com/mfenderov/most-active-cookie/src/parser"
@@ -12,6 +21,9 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
+// malformedUTF8CSV has a cookie ID with an invalid UTF-8 byte sequence.
+const malformedUTF8CSV = "cookie,timestamp\n\xFF\xFE\x00invalid,2018-12-09T14:19:00+00:00"
+
 // createTempCSVFile creates a temporary CSV file with the given content and returns its path.
 // Cleanup is handled automatically using t.Cleanup().
 func createTempCSVFile(t *testing.T, content string) string {
@@ -71,9 +83,6 @@ café🍪μπισκότο,2018-12-09T14:19:00+00:00
 	}
 	longFieldCSV := fmt.Sprintf("cookie,timestamp\n%s,2018-12-09T14:19:00+00:00", longCookieName)
 
-	// Edge case: Malformed UTF-8 (invalid byte sequence)
-	malformedUTF8CSV := "cookie,timestamp\n\xFF\xFE\x00invalid,2018-12-09T14:19:00+00:00"
-
 	tests := []struct {
 		name          string
 		csvContent    string
@@ -99,6 +108,12 @@ café🍪μπισκότο,2018-12-09T14:19:00+00:00
 			expectError:   true,
 			errorContains: "invalid timestamp format",
 		},
+		{
+			name:          "zero-byte file",
+			csvContent:    "",
+			expectError:   true,
+			errorContains: "file is empty: no header row",
+		},
 		{
 			name:          "empty file with header only",
 			csvContent:    emptyFileCSV,
@@ -120,8 +135,14 @@ café🍪μπισκότο,2018-12-09T14:19:00+00:00
 		{
 			name:          "CR line endings",
 			csvContent:    crCSV,
-			expectError:   true,
-			errorContains: "invalid header format", // Current parser doesn't handle CR-only
+			expectedCount: 2,
+			expectError:   false,
+		},
+		{
+			name:          "mixed line endings",
+			csvContent:    "cookie,timestamp\r\nA,2018-12-09T14:19:00+00:00\rB,2018-12-09T10:13:00+00:00\nC,2018-12-09T07:25:00+00:00\r",
+			expectedCount: 3,
+			expectError:   false,
 		},
 		{
 			name:          "Unicode characters in cookie names",
@@ -132,8 +153,32 @@ café🍪μπισκότο,2018-12-09T14:19:00+00:00
 		{
 			name:          "quoted fields with commas and quotes",
 			csvContent:    quotedCSV,
+			expectedCount: 2,
+			expectError:   false,
+		},
+		{
+			name:          "quoted field spanning lines",
+			csvContent:    "cookie,timestamp\n\"cookie\nwith newline\",2018-12-09T14:19:00+00:00",
+			expectError:   true,
+			errorContains: "quoted fields cannot span lines",
+		},
+		{
+			name:          "bare quote in unquoted field",
+			csvContent:    "cookie,timestamp\ncoo\"kie,2018-12-09T14:19:00+00:00",
+			expectError:   true,
+			errorContains: "bare quote in unquoted field",
+		},
+		{
+			name:          "missing delimiter",
+			csvContent:    "cookie,timestamp\nAtY0laUfhglK3lC7",
+			expectError:   true,
+			errorContains: "expected 2 columns, got 1",
+		},
+		{
+			name:          "too many columns",
+			csvContent:    "cookie,timestamp\nAtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00,extra",
 			expectError:   true,
-			errorContains: "invalid CSV format", // Current parser doesn't handle CSV quoting
+			errorContains: "expected 2 columns, got 3",
 		},
 		{
 			name:          "very long cookie name",
@@ -145,7 +190,7 @@ café🍪μπισκότο,2018-12-09T14:19:00+00:00
 			name:          "malformed UTF-8",
 			csvContent:    malformedUTF8CSV,
 			expectedCount: 1,
-			expectError:   false, // Current parser accepts malformed UTF-8
+			expectError:   false, // Lenient unless WithStrictUTF8 is set
 		},
 	}
 
@@ -200,3 +245,1347 @@ AtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00`
 	assert.Error(t, err, "expected processor error to propagate")
 	assert.ErrorContains(t, err, "processing error", "error should mention processing failure")
 }
+
+func TestCSVParser_StreamFile_TimestampLayouts(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          []parser.Option
+		timestamp     string
+		expectedTime  time.Time
+		errorContains string
+	}{
+		{
+			name:         "space separated layout read as UTC",
+			opts:         []parser.Option{parser.WithTimestampLayouts("2006-01-02 15:04:05")},
+			timestamp:    "2018-12-09 14:19:00",
+			expectedTime: time.Date(2018, 12, 9, 14, 19, 0, 0, time.UTC),
+		},
+		{
+			name:         "later layout matches",
+			opts:         []parser.Option{parser.WithTimestampLayouts(time.RFC3339, "2006-01-02 15:04:05")},
+			timestamp:    "2018-12-09 23:30:00",
+			expectedTime: time.Date(2018, 12, 9, 23, 30, 0, 0, time.UTC),
+		},
+		{
+			name:         "offset converted to UTC",
+			opts:         []parser.Option{parser.WithTimestampLayouts(time.RFC3339)},
+			timestamp:    "2018-12-09T23:30:00-02:00",
+			expectedTime: time.Date(2018, 12, 10, 1, 30, 0, 0, time.UTC),
+		},
+		{
+			name:          "no layout matches",
+			opts:          []parser.Option{parser.WithTimestampLayouts("2006-01-02 15:04:05")},
+			timestamp:     "2018-12-09T14:19:00+00:00",
+			errorContains: "expected one of the layouts",
+		},
+		{
+			name:          "space separated rejected by default",
+			timestamp:     "2018-12-09 14:19:00",
+			errorContains: "invalid timestamp format",
+		},
+		{
+			name:          "default validation parses the timestamp",
+			timestamp:     "2018-13-45T99:19:00+00:00",
+			errorContains: "invalid timestamp format",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := createTempCSVFile(t, "cookie,timestamp\nAtY0laUfhglK3lC7,"+tt.timestamp)
+
+			var entries []cookie.LogEntry
+			err := parser.NewCSVParser(tt.opts...).StreamFile(filename, func(entry cookie.LogEntry) error {
+				entries = append(entries, entry)
+				return nil
+			})
+
+			if tt.errorContains != "" {
+				assert.ErrorContains(t, err, tt.errorContains, "error should contain expected substring")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Len(t, entries, 1, "expected one entry")
+			assert.True(t, tt.expectedTime.Equal(entries[0].Time), "expected time %v, got %v", tt.expectedTime, entries[0].Time)
+		})
+	}
+}
+
+func TestCSVParser_StreamFile_AcceptedHeaders(t *testing.T) {
+	csvParser := parser.NewCSVParser(parser.WithAcceptedHeaders([]string{"cookie,timestamp", "Cookie_ID,Event_Time"}))
+
+	tests := []struct {
+		name        string
+		csvContent  string
+		expectError bool
+	}{
+		{
+			name:       "canonical header",
+			csvContent: "cookie,timestamp\nAtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00",
+		},
+		{
+			name:       "alternative header is case-insensitive",
+			csvContent: "COOKIE_ID,EVENT_TIME\nAtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00",
+		},
+		{
+			name:        "unknown header",
+			csvContent:  "id,ts\nAtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00",
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := createTempCSVFile(t, tt.csvContent)
+
+			err := csvParser.StreamFile(filename, func(_ cookie.LogEntry) error {
+				return nil
+			})
+
+			if tt.expectError {
+				assert.ErrorContains(t, err, "invalid header format", "unknown header should be rejected")
+				return
+			}
+			assert.NoError(t, err, "accepted header should parse")
+		})
+	}
+}
+
+func TestCSVParser_StreamFile_ColumnMapping(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          []parser.Option
+		csvContent    string
+		expected      []cookie.LogEntry
+		errorContains string
+	}{
+		{
+			name:       "timestamp before cookie",
+			csvContent: "timestamp,cookie\n2018-12-09T14:19:00+00:00,AtY0laUfhglK3lC7\n",
+			expected:   []cookie.LogEntry{{Cookie: "AtY0laUfhglK3lC7", Timestamp: "2018-12-09T14:19:00+00:00"}},
+		},
+		{
+			name:       "extra columns ignored",
+			csvContent: "cookie,timestamp,region\nAtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00,eu-west\n",
+			expected:   []cookie.LogEntry{{Cookie: "AtY0laUfhglK3lC7", Timestamp: "2018-12-09T14:19:00+00:00"}},
+		},
+		{
+			name:       "quoted extra column",
+			csvContent: "region,Timestamp,Cookie\n\"eu-west, dublin\",2018-12-09T14:19:00+00:00,AtY0laUfhglK3lC7\n",
+			expected:   []cookie.LogEntry{{Cookie: "AtY0laUfhglK3lC7", Timestamp: "2018-12-09T14:19:00+00:00"}},
+		},
+		{
+			name:       "weight column found by name",
+			opts:       []parser.Option{parser.WithWeightColumn("weight")},
+			csvContent: "weight,timestamp,cookie\n3,2018-12-09T14:19:00+00:00,AtY0laUfhglK3lC7\n",
+			expected:   []cookie.LogEntry{{Cookie: "AtY0laUfhglK3lC7", Timestamp: "2018-12-09T14:19:00+00:00", Weight: 3}},
+		},
+		{
+			name:          "missing timestamp column",
+			csvContent:    "cookie,region\nAtY0laUfhglK3lC7,eu-west\n",
+			errorContains: "invalid header format",
+		},
+		{
+			name:          "more columns than the header",
+			csvContent:    "timestamp,cookie\n2018-12-09T14:19:00+00:00,AtY0laUfhglK3lC7,eu-west\n",
+			errorContains: "expected 2 columns, got 3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := createTempCSVFile(t, tt.csvContent)
+
+			var entries []cookie.LogEntry
+			err := parser.NewCSVParser(tt.opts...).StreamFile(filename, func(entry cookie.LogEntry) error {
+				entries = append(entries, entry)
+				return nil
+			})
+
+			if tt.errorContains != "" {
+				assert.ErrorContains(t, err, tt.errorContains, "error should contain expected substring")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, entries, "entries mismatch")
+		})
+	}
+
+	filename := createTempCSVFile(t, "timestamp,cookie\nyesterday,AtY0laUfhglK3lC7\n")
+	err := parser.NewCSVParser().StreamFile(filename, func(_ cookie.LogEntry) error { return nil })
+	var parseErr *parser.ParseError
+	if assert.ErrorAs(t, err, &parseErr, "expected a *ParseError") {
+		assert.Equal(t, 1, parseErr.Column, "the timestamp is the header's first column")
+	}
+}
+
+func TestCSVParser_StreamFile_SkipEntry(t *testing.T) {
+	validCSV := `cookie,timestamp
+AtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00
+SAZuXPGUrfbcn5UA,2018-12-09T10:13:00+00:00
+5UAVanZf6UtGyKVS,2018-12-09T07:25:00+00:00`
+
+	csvParser := parser.NewCSVParser()
+	filename := createTempCSVFile(t, validCSV)
+
+	var seen []string
+	err := csvParser.StreamFile(filename, func(entry cookie.LogEntry) error {
+		seen = append(seen, entry.Cookie)
+		if entry.Cookie == "SAZuXPGUrfbcn5UA" {
+			return fmt.Errorf("ignoring %s: %w", entry.Cookie, cookie.ErrSkipEntry)
+		}
+		return nil
+	})
+
+	assert.NoError(t, err, "skipped entries should not abort streaming")
+	assert.Equal(t, []string{"AtY0laUfhglK3lC7", "SAZuXPGUrfbcn5UA", "5UAVanZf6UtGyKVS"}, seen, "streaming should continue past a skipped entry")
+}
+
+func TestCSVParser_StreamFile_URL(t *testing.T) {
+	validCSV := `cookie,timestamp
+AtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00
+SAZuXPGUrfbcn5UA,2018-12-09T10:13:00+00:00`
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/cookie_log.csv" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, validCSV)
+	}))
+	t.Cleanup(server.Close)
+
+	csvParser := parser.NewCSVParser()
+
+	t.Run("successful download", func(t *testing.T) {
+		var entries []cookie.LogEntry
+		err := csvParser.StreamFile(server.URL+"/cookie_log.csv", func(entry cookie.LogEntry) error {
+			entries = append(entries, entry)
+			return nil
+		})
+
+		assert.NoError(t, err, "unexpected error")
+		assert.Equal(t, 2, len(entries), "entry count mismatch")
+	})
+
+	t.Run("non-200 response", func(t *testing.T) {
+		err := csvParser.StreamFile(server.URL+"/missing.csv", func(_ cookie.LogEntry) error {
+			return nil
+		})
+
+		assert.ErrorContains(t, err, "unexpected HTTP status 404", "error should mention the HTTP status")
+	})
+
+	t.Run("cancelled while waiting for the response", func(t *testing.T) {
+		release := make(chan struct{})
+		slow := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			<-release
+		}))
+		t.Cleanup(slow.Close)
+		t.Cleanup(func() { close(release) })
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+
+		err := csvParser.StreamFileContext(ctx, slow.URL+"/cookie_log.csv", func(_ cookie.LogEntry) error {
+			return nil
+		})
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded, "the request should end with the context")
+	})
+}
+
+func TestCSVParser_StreamFile_EpochTimestamps(t *testing.T) {
+	tests := []struct {
+		name          string
+		mode          parser.TimestampMode
+		csvContent    string
+		expectedTime  time.Time
+		errorContains string
+	}{
+		{
+			name:         "epoch milliseconds",
+			mode:         parser.TimestampUnixMilli,
+			csvContent:   "cookie,timestamp\nAtY0laUfhglK3lC7,1544364000000",
+			expectedTime: time.Date(2018, 12, 9, 14, 0, 0, 0, time.UTC),
+		},
+		{
+			name:         "epoch seconds",
+			mode:         parser.TimestampUnixSec,
+			csvContent:   "cookie,timestamp\nAtY0laUfhglK3lC7,1544364000",
+			expectedTime: time.Date(2018, 12, 9, 14, 0, 0, 0, time.UTC),
+		},
+		{
+			name:          "non-numeric epoch",
+			mode:          parser.TimestampUnixMilli,
+			csvContent:    "cookie,timestamp\nAtY0laUfhglK3lC7,2018-12-09T14:00:00Z",
+			errorContains: "invalid epoch timestamp",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csvParser := parser.NewCSVParser(parser.WithTimestampMode(tt.mode))
+			filename := createTempCSVFile(t, tt.csvContent)
+
+			var entries []cookie.LogEntry
+			err := csvParser.StreamFile(filename, func(entry cookie.LogEntry) error {
+				entries = append(entries, entry)
+				return nil
+			})
+
+			if tt.errorContains != "" {
+				assert.ErrorContains(t, err, tt.errorContains, "error should contain expected substring")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Len(t, entries, 1, "entry count mismatch")
+			assert.True(t, tt.expectedTime.Equal(entries[0].Time), "parsed time mismatch: got %v", entries[0].Time)
+		})
+	}
+}
+
+func TestCSVParser_StreamFile_MaxLines(t *testing.T) {
+	validCSV := `cookie,timestamp
+AtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00
+SAZuXPGUrfbcn5UA,2018-12-09T10:13:00+00:00
+5UAVanZf6UtGyKVS,2018-12-09T07:25:00+00:00`
+
+	filename := createTempCSVFile(t, validCSV)
+	noop := func(_ cookie.LogEntry) error { return nil }
+
+	err := parser.NewCSVParser(parser.WithMaxLines(4)).StreamFile(filename, noop)
+	assert.NoError(t, err, "file within the line limit should parse")
+
+	err = parser.NewCSVParser(parser.WithMaxLines(3)).StreamFile(filename, noop)
+	assert.ErrorContains(t, err, "exceeded maximum of 3 lines", "file over the line limit should abort")
+}
+
+func TestCSVParser_StreamFile_LongLines(t *testing.T) {
+	longCookie := strings.Repeat("a", 100*1024)
+	filename := createTempCSVFile(t, "cookie,timestamp\n"+longCookie+",2018-12-09T14:19:00+00:00\n")
+
+	var cookies []string
+	err := parser.NewCSVParser().StreamFile(filename, func(entry cookie.LogEntry) error {
+		cookies = append(cookies, entry.Cookie)
+		return nil
+	})
+	assert.NoError(t, err, "lines over 64KB should parse by default")
+	assert.Equal(t, []string{longCookie}, cookies, "the long cookie should be read in full")
+
+	err = parser.NewCSVParser(parser.WithMaxLineSize(64*1024)).StreamFile(filename, func(_ cookie.LogEntry) error {
+		return nil
+	})
+	assert.ErrorContains(t, err, "line 2 is longer than the maximum of 65536 bytes", "lines over the configured maximum should fail")
+}
+
+func TestCSVParser_StreamFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"logs/cookie_log.csv": {Data: []byte("cookie,timestamp\nAtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00\nSAZuXPGUrfbcn5UA,2018-12-09T10:13:00+00:00\n")},
+	}
+	csvParser := parser.NewCSVParser()
+
+	var entries []cookie.LogEntry
+	err := csvParser.StreamFS(fsys, "logs/cookie_log.csv", func(entry cookie.LogEntry) error {
+		entries = append(entries, entry)
+		return nil
+	})
+	assert.NoError(t, err, "unexpected error")
+	assert.Equal(t, 2, len(entries), "entry count mismatch")
+
+	err = csvParser.StreamFS(fsys, "logs/missing.csv", func(_ cookie.LogEntry) error {
+		return nil
+	})
+	assert.ErrorContains(t, err, "failed to open file", "missing file should fail to open")
+}
+
+func TestCSVParser_StreamFile_AssumedLocation(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	assert.NoError(t, err, "failed to load location")
+
+	tests := []struct {
+		name          string
+		csvContent    string
+		expectedTime  time.Time
+		errorContains string
+	}{
+		{
+			name:         "offset-less timestamp is converted to UTC",
+			csvContent:   "cookie,timestamp\nAtY0laUfhglK3lC7,2018-12-09T22:30:00",
+			expectedTime: time.Date(2018, 12, 10, 3, 30, 0, 0, time.UTC),
+		},
+		{
+			name:         "local midnight falls on the same UTC date",
+			csvContent:   "cookie,timestamp\nAtY0laUfhglK3lC7,2018-12-09T00:00:00",
+			expectedTime: time.Date(2018, 12, 9, 5, 0, 0, 0, time.UTC),
+		},
+		{
+			name:         "last local second of the day crosses into the next UTC date",
+			csvContent:   "cookie,timestamp\nAtY0laUfhglK3lC7,2018-12-09T23:59:59",
+			expectedTime: time.Date(2018, 12, 10, 4, 59, 59, 0, time.UTC),
+		},
+		{
+			name:          "timestamp with offset is rejected",
+			csvContent:    "cookie,timestamp\nAtY0laUfhglK3lC7,2018-12-09T22:30:00+00:00",
+			errorContains: "carries a UTC offset",
+		},
+		{
+			name:          "malformed timestamp",
+			csvContent:    "cookie,timestamp\nAtY0laUfhglK3lC7,yesterday",
+			errorContains: "invalid timestamp format",
+		},
+	}
+
+	csvParser := parser.NewCSVParser(parser.WithAssumedLocation(newYork))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := createTempCSVFile(t, tt.csvContent)
+
+			var entries []cookie.LogEntry
+			err := csvParser.StreamFile(filename, func(entry cookie.LogEntry) error {
+				entries = append(entries, entry)
+				return nil
+			})
+
+			if tt.errorContains != "" {
+				assert.ErrorContains(t, err, tt.errorContains, "error should contain expected substring")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Len(t, entries, 1, "entry count mismatch")
+			assert.Equal(t, tt.expectedTime, entries[0].Time, "parsed time mismatch")
+		})
+	}
+}
+
+func TestCSVParser_StreamFile_StopBeforeFirstEntry(t *testing.T) {
+	csvContent := `cookie,timestamp
+AtY0laUfhglK3lC7,2018-12-10T14:19:00+00:00
+SAZuXPGUrfbcn5UA,2018-12-10T10:13:00+00:00`
+
+	csvParser := parser.NewCSVParser()
+	filename := createTempCSVFile(t, csvContent)
+
+	err := csvParser.StreamFile(filename, func(_ cookie.LogEntry) error {
+		return cookie.ErrPastTargetDate
+	})
+
+	assert.NoError(t, err, "data past the target date is an empty result, not an error")
+}
+
+func TestCSVParser_StreamFile_SkipRepeatedHeaders(t *testing.T) {
+	concatenatedCSV := "\xEF\xBB\xBFcookie,timestamp\n" +
+		"AtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00\n" +
+		"\xEF\xBB\xBFcookie,timestamp\n" +
+		"SAZuXPGUrfbcn5UA,2018-12-09T10:13:00+00:00\n" +
+		"cookie,timestamp\n" +
+		"5UAVanZf6UtGyKVS,2018-12-09T07:25:00+00:00\n"
+
+	filename := createTempCSVFile(t, concatenatedCSV)
+
+	var cookies []string
+	err := parser.NewCSVParser(parser.WithSkipRepeatedHeaders()).StreamFile(filename, func(entry cookie.LogEntry) error {
+		cookies = append(cookies, entry.Cookie)
+		return nil
+	})
+	assert.NoError(t, err, "concatenated file should parse")
+	assert.Equal(t, []string{"AtY0laUfhglK3lC7", "SAZuXPGUrfbcn5UA", "5UAVanZf6UtGyKVS"}, cookies, "repeated headers should be skipped")
+
+	err = parser.NewCSVParser().StreamFile(filename, func(_ cookie.LogEntry) error {
+		return nil
+	})
+	assert.Error(t, err, "repeated headers should fail without the option")
+}
+
+func TestCSVParser_StreamFile_ParseError(t *testing.T) {
+	tests := []struct {
+		name           string
+		line           string
+		expectedColumn int
+		expectedCause  string
+	}{
+		{
+			name:           "wrong column count",
+			line:           "AtY0laUfhglK3lC7",
+			expectedColumn: 0,
+			expectedCause:  "expected 2 columns, got 1",
+		},
+		{
+			name:           "empty cookie",
+			line:           ",2018-12-09T14:19:00+00:00",
+			expectedColumn: 1,
+			expectedCause:  "empty cookie ID",
+		},
+		{
+			name:           "invalid timestamp",
+			line:           "AtY0laUfhglK3lC7,yesterday",
+			expectedColumn: 2,
+			expectedCause:  "invalid timestamp format 'yesterday'",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csv := "cookie,timestamp\nSAZuXPGUrfbcn5UA,2018-12-09T10:13:00+00:00\n" + tt.line + "\n"
+			filename := createTempCSVFile(t, csv)
+
+			err := parser.NewCSVParser().StreamFile(filename, func(_ cookie.LogEntry) error {
+				return nil
+			})
+
+			var parseErr *parser.ParseError
+			if assert.ErrorAs(t, err, &parseErr, "expected a *ParseError") {
+				assert.Equal(t, 3, parseErr.Line, "line mismatch")
+				assert.Equal(t, tt.expectedColumn, parseErr.Column, "column mismatch")
+				assert.Equal(t, tt.line, parseErr.Raw, "raw line mismatch")
+				assert.ErrorContains(t, parseErr.Cause, tt.expectedCause, "cause mismatch")
+			}
+			assert.ErrorContains(t, err, "error parsing line 3: ", "message should name the line")
+		})
+	}
+}
+
+func TestCSVParser_StreamFile_SkipInvalidLines(t *testing.T) {
+	dirtyCSV := "cookie,timestamp\n" +
+		"AtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00\n" +
+		"garbage\n" +
+		"SAZuXPGUrfbcn5UA,not-a-timestamp\n" +
+		"5UAVanZf6UtGyKVS,2018-12-09T07:25:00+00:00\n"
+	filename := createTempCSVFile(t, dirtyCSV)
+
+	var cookies []string
+	var invalidLines []int
+	csvParser := parser.NewCSVParser(parser.WithSkipInvalidLines(func(lineNum int, err error) {
+		assert.Error(t, err, "skipped lines should come with their parse error")
+		invalidLines = append(invalidLines, lineNum)
+	}))
+	err := csvParser.StreamFile(filename, func(entry cookie.LogEntry) error {
+		cookies = append(cookies, entry.Cookie)
+		return nil
+	})
+	assert.NoError(t, err, "invalid lines should not abort the scan")
+	assert.Equal(t, []string{"AtY0laUfhglK3lC7", "5UAVanZf6UtGyKVS"}, cookies, "valid lines should still be parsed")
+	assert.Equal(t, []int{3, 4}, invalidLines, "each invalid line should be reported")
+
+	err = parser.NewCSVParser(parser.WithSkipInvalidLines(nil)).StreamFile(createTempCSVFile(t, "cookie,timestamp\ngarbage\n"), func(_ cookie.LogEntry) error {
+		return nil
+	})
+	assert.ErrorContains(t, err, "no valid entries found", "a file of only invalid lines should still fail")
+
+	err = parser.NewCSVParser().StreamFile(filename, func(_ cookie.LogEntry) error { return nil })
+	assert.ErrorContains(t, err, "error parsing line 3", "strict mode should stay the default")
+}
+
+func TestCSVParser_StreamFile_CommentPrefix(t *testing.T) {
+	tests := []struct {
+		name          string
+		csvContent    string
+		expected      []string
+		errorContains string
+	}{
+		{
+			name: "comments above and within the data",
+			csvContent: "# exported 2018-12-10\n" +
+				"  # source: edge-1\n" +
+				"cookie,timestamp\n" +
+				"AtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00\n" +
+				"# shard boundary\n" +
+				"SAZuXPGUrfbcn5UA,2018-12-09T10:13:00+00:00\n",
+			expected: []string{"AtY0laUfhglK3lC7", "SAZuXPGUrfbcn5UA"},
+		},
+		{
+			name:          "only comments after the header",
+			csvContent:    "cookie,timestamp\n# nothing exported\n",
+			errorContains: "no valid entries found",
+		},
+		{
+			name:          "only comments",
+			csvContent:    "# nothing exported\n# try again tomorrow\n",
+			errorContains: "no header row",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := createTempCSVFile(t, tt.csvContent)
+
+			var cookies []string
+			err := parser.NewCSVParser(parser.WithCommentPrefix('#')).StreamFile(filename, func(entry cookie.LogEntry) error {
+				cookies = append(cookies, entry.Cookie)
+				return nil
+			})
+
+			if tt.errorContains != "" {
+				assert.ErrorContains(t, err, tt.errorContains, "error should contain expected substring")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, cookies, "comments should be skipped")
+		})
+	}
+
+	filename := createTempCSVFile(t, "# exported 2018-12-10\ncookie,timestamp\nAtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00\n")
+	err := parser.NewCSVParser().StreamFile(filename, func(_ cookie.LogEntry) error { return nil })
+	assert.ErrorContains(t, err, "invalid header format", "comments should not be skipped without the option")
+}
+
+func TestCSVParser_StreamFile_WithoutHeader(t *testing.T) {
+	headerlessCSV := "\xEF\xBB\xBFAtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00\n" +
+		"SAZuXPGUrfbcn5UA,2018-12-09T10:13:00+00:00\n"
+	filename := createTempCSVFile(t, headerlessCSV)
+
+	var cookies []string
+	err := parser.NewCSVParser(parser.WithoutHeader()).StreamFile(filename, func(entry cookie.LogEntry) error {
+		cookies = append(cookies, entry.Cookie)
+		return nil
+	})
+	assert.NoError(t, err, "headerless file should parse")
+	assert.Equal(t, []string{"AtY0laUfhglK3lC7", "SAZuXPGUrfbcn5UA"}, cookies, "both rows should be parsed as data")
+
+	err = parser.NewCSVParser().StreamFile(filename, func(_ cookie.LogEntry) error { return nil })
+	assert.ErrorContains(t, err, "invalid header format", "the first row is a header by default")
+
+	err = parser.NewCSVParser(parser.WithoutHeader()).StreamFile(createTempCSVFile(t, "cookie,timestamp\nAtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00\n"), func(_ cookie.LogEntry) error { return nil })
+	assert.ErrorContains(t, err, "error parsing line 1", "a header row is not valid data")
+}
+
+func TestCSVParser_StreamFile_AutoDetectDelimiter(t *testing.T) {
+	tests := []struct {
+		name          string
+		csvContent    string
+		expectedCount int
+		errorContains string
+	}{
+		{
+			name:          "comma separated",
+			csvContent:    "cookie,timestamp\nAtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00",
+			expectedCount: 1,
+		},
+		{
+			name:          "tab separated",
+			csvContent:    "cookie\ttimestamp\nAtY0laUfhglK3lC7\t2018-12-09T14:19:00+00:00\nSAZuXPGUrfbcn5UA\t2018-12-09T10:13:00+00:00",
+			expectedCount: 2,
+		},
+		{
+			name:          "semicolon separated",
+			csvContent:    "cookie;timestamp\nAtY0laUfhglK3lC7;2018-12-09T14:19:00+00:00",
+			expectedCount: 1,
+		},
+		{
+			name:          "unrecognized delimiter falls back to comma",
+			csvContent:    "cookie|timestamp\nAtY0laUfhglK3lC7|2018-12-09T14:19:00+00:00",
+			errorContains: "invalid header format",
+		},
+	}
+
+	csvParser := parser.NewCSVParser(parser.WithAutoDetectDelimiter())
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := createTempCSVFile(t, tt.csvContent)
+
+			var entries []cookie.LogEntry
+			err := csvParser.StreamFile(filename, func(entry cookie.LogEntry) error {
+				entries = append(entries, entry)
+				return nil
+			})
+
+			if tt.errorContains != "" {
+				assert.ErrorContains(t, err, tt.errorContains, "error should contain expected substring")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expectedCount, len(entries), "entry count mismatch")
+		})
+	}
+}
+
+func TestCSVParser_StreamFile_Delimiter(t *testing.T) {
+	tests := []struct {
+		name          string
+		delimiter     byte
+		csvContent    string
+		expectedCount int
+		errorContains string
+	}{
+		{
+			name:          "tab separated",
+			delimiter:     '\t',
+			csvContent:    "cookie\ttimestamp\nAtY0laUfhglK3lC7\t2018-12-09T14:19:00+00:00",
+			expectedCount: 1,
+		},
+		{
+			name:          "pipe separated",
+			delimiter:     '|',
+			csvContent:    "cookie|timestamp\nAtY0laUfhglK3lC7|2018-12-09T14:19:00+00:00\nSAZuXPGUrfbcn5UA|2018-12-09T10:13:00+00:00",
+			expectedCount: 2,
+		},
+		{
+			name:          "comma separated file rejected",
+			delimiter:     ';',
+			csvContent:    "cookie,timestamp\nAtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00",
+			errorContains: "invalid header format",
+		},
+		{
+			name:          "extra column",
+			delimiter:     '\t',
+			csvContent:    "cookie\ttimestamp\nAtY0laUfhglK3lC7\t2018-12-09T14:19:00+00:00\textra",
+			errorContains: "expected 2 columns, got 3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := createTempCSVFile(t, tt.csvContent)
+			csvParser := parser.NewCSVParser(parser.WithDelimiter(tt.delimiter))
+
+			var entries []cookie.LogEntry
+			err := csvParser.StreamFile(filename, func(entry cookie.LogEntry) error {
+				entries = append(entries, entry)
+				return nil
+			})
+
+			if tt.errorContains != "" {
+				assert.ErrorContains(t, err, tt.errorContains, "error should contain expected substring")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expectedCount, len(entries), "entry count mismatch")
+		})
+	}
+}
+
+func TestCSVParser_StreamFile_TimestampFallback(t *testing.T) {
+	tests := []struct {
+		name               string
+		csvContent         string
+		opts               []parser.Option
+		expectedTimestamps []string
+		errorContains      string
+	}{
+		{
+			name:               "blank primary falls back",
+			csvContent:         "cookie,event_time,ingest_time\nA,2018-12-09T14:19:00+00:00,2018-12-09T14:20:00+00:00\nB,,2018-12-09T10:14:00+00:00\nC, ,2018-12-09T09:14:00+00:00",
+			expectedTimestamps: []string{"2018-12-09T14:19:00+00:00", "2018-12-09T10:14:00+00:00", "2018-12-09T09:14:00+00:00"},
+		},
+		{
+			name:               "fallback column missing from the header",
+			csvContent:         "cookie,event_time\nA,2018-12-09T14:19:00+00:00",
+			expectedTimestamps: []string{"2018-12-09T14:19:00+00:00"},
+		},
+		{
+			name:          "every column blank",
+			csvContent:    "cookie,event_time,ingest_time\nA,2018-12-09T14:19:00+00:00,\nB,,",
+			errorContains: "empty timestamp",
+		},
+		{
+			name:               "every column blank, skipped",
+			csvContent:         "cookie,event_time,ingest_time\nA,2018-12-09T14:19:00+00:00,\nB,,",
+			opts:               []parser.Option{parser.WithSkipInvalidLines(nil)},
+			expectedTimestamps: []string{"2018-12-09T14:19:00+00:00"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := createTempCSVFile(t, tt.csvContent)
+			opts := append([]parser.Option{
+				parser.WithAcceptedHeaders([]string{"cookie,event_time"}),
+				parser.WithTimestampFallback([]string{"event_time", "ingest_time"}),
+			}, tt.opts...)
+
+			var timestamps []string
+			err := parser.NewCSVParser(opts...).StreamFile(filename, func(entry cookie.LogEntry) error {
+				timestamps = append(timestamps, entry.Timestamp)
+				return nil
+			})
+
+			if tt.errorContains != "" {
+				assert.ErrorContains(t, err, tt.errorContains, "error should contain expected substring")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expectedTimestamps, timestamps, "timestamps mismatch")
+		})
+	}
+}
+
+func TestCSVParser_StreamFile_WeightColumn(t *testing.T) {
+	tests := []struct {
+		name            string
+		csvContent      string
+		expectedWeights []int
+		errorContains   string
+	}{
+		{
+			name:            "weights parsed",
+			csvContent:      "cookie,timestamp,weight\nA,2018-12-09T14:19:00+00:00,5\nB,2018-12-09T10:13:00+00:00,1",
+			expectedWeights: []int{5, 1},
+		},
+		{
+			name:            "missing and invalid weights count once",
+			csvContent:      "cookie,timestamp,weight\nA,2018-12-09T14:19:00+00:00\nB,2018-12-09T10:13:00+00:00,lots\nC,2018-12-09T09:13:00+00:00,-2",
+			expectedWeights: []int{0, 0, 0},
+		},
+		{
+			name:            "header without weight column",
+			csvContent:      "cookie,timestamp\nA,2018-12-09T14:19:00+00:00",
+			expectedWeights: []int{0},
+		},
+		{
+			name:          "too many columns",
+			csvContent:    "cookie,timestamp,weight\nA,2018-12-09T14:19:00+00:00,5,extra",
+			errorContains: "invalid CSV format",
+		},
+	}
+
+	csvParser := parser.NewCSVParser(parser.WithWeightColumn("weight"))
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := createTempCSVFile(t, tt.csvContent)
+
+			var weights []int
+			err := csvParser.StreamFile(filename, func(entry cookie.LogEntry) error {
+				weights = append(weights, entry.Weight)
+				return nil
+			})
+
+			if tt.errorContains != "" {
+				assert.ErrorContains(t, err, tt.errorContains, "error should contain expected substring")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expectedWeights, weights, "weights mismatch")
+		})
+	}
+}
+
+func TestCSVParser_StreamFile_RecordSeparator(t *testing.T) {
+	formFeedCSV := "cookie,timestamp\fAtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00\f\nSAZuXPGUrfbcn5UA,2018-12-09T10:13:00+00:00"
+	filename := createTempCSVFile(t, formFeedCSV)
+
+	var cookies []string
+	err := parser.NewCSVParser(parser.WithRecordSeparator('\f')).StreamFile(filename, func(entry cookie.LogEntry) error {
+		cookies = append(cookies, entry.Cookie)
+		return nil
+	})
+	assert.NoError(t, err, "unexpected error")
+	assert.Equal(t, []string{"AtY0laUfhglK3lC7", "SAZuXPGUrfbcn5UA"}, cookies, "records should be split on form feeds")
+
+	err = parser.NewCSVParser().StreamFile(filename, func(_ cookie.LogEntry) error { return nil })
+	assert.ErrorContains(t, err, "invalid header format", "form feeds are not record separators by default")
+}
+
+func TestCSVParser_StreamFile_ValidYearRange(t *testing.T) {
+	tests := []struct {
+		name           string
+		opts           []parser.Option
+		timestamp      string
+		errorContains  string
+		outOfRangeYear int
+	}{
+		{
+			name:      "year within default range",
+			timestamp: "2018-12-09T14:19:00+00:00",
+		},
+		{
+			name:           "truncated year",
+			timestamp:      "0018-12-09T14:19:00+00:00",
+			errorContains:  "year 18 outside the valid range 2000-2100",
+			outOfRangeYear: 1,
+		},
+		{
+			name:          "extra digit in year",
+			timestamp:     "20018-12-09T14:19:00+00:00",
+			errorContains: "invalid timestamp format '20018-12-09T14:19:00+00:00'",
+		},
+		{
+			name:           "epoch before range",
+			opts:           []parser.Option{parser.WithTimestampMode(parser.TimestampUnixSec)},
+			timestamp:      "86400",
+			errorContains:  "year 1970 outside the valid range 2000-2100",
+			outOfRangeYear: 1,
+		},
+		{
+			name:      "custom range",
+			opts:      []parser.Option{parser.WithValidYearRange(1990, 1999)},
+			timestamp: "1998-12-09T14:19:00+00:00",
+		},
+		{
+			name:      "check disabled",
+			opts:      []parser.Option{parser.WithValidYearRange(0, 0)},
+			timestamp: "0018-12-09T14:19:00+00:00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := createTempCSVFile(t, "cookie,timestamp\nAtY0laUfhglK3lC7,"+tt.timestamp)
+
+			stats, err := parser.NewCSVParser(tt.opts...).StreamFileStats(filename, func(_ cookie.LogEntry) error {
+				return nil
+			})
+
+			assert.Equal(t, tt.outOfRangeYear, stats.OutOfRangeYear, "out-of-range year count mismatch")
+			if tt.errorContains != "" {
+				assert.ErrorContains(t, err, tt.errorContains, "error should contain expected substring")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+		})
+	}
+}
+
+func TestCSVParser_StreamFileStats_SkippedOutOfRangeYears(t *testing.T) {
+	filename := createTempCSVFile(t, "cookie,timestamp\n"+
+		"AtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00\n"+
+		"SAZuXPGUrfbcn5UA,1999-12-09T10:13:00+00:00\n"+
+		"5UAVanZf6UtGyKVS,not-a-timestamp\n"+
+		"AtY0laUfhglK3lC7,2101-12-09T06:19:00+00:00\n")
+
+	stats, err := parser.NewCSVParser(parser.WithSkipInvalidLines(nil)).StreamFileStats(filename, func(_ cookie.LogEntry) error {
+		return nil
+	})
+
+	assert.NoError(t, err, "invalid lines should be skipped")
+	assert.Equal(t, 3, stats.Invalid, "every malformed line should be skipped")
+	assert.Equal(t, 2, stats.OutOfRangeYear, "only the years outside the range should be counted")
+}
+
+func TestCSVParser_StreamFile_StrictUTF8(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          []parser.Option
+		expected      []string
+		errorContains string
+	}{
+		{
+			name:     "lenient by default",
+			expected: []string{"\xFF\xFE\x00invalid"},
+		},
+		{
+			name:          "strict rejects the line",
+			opts:          []parser.Option{parser.WithStrictUTF8()},
+			errorContains: `error parsing line 2: cookie ID "\xff\xfe\x00invalid" is not valid UTF-8`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := createTempCSVFile(t, malformedUTF8CSV)
+			csvParser := parser.NewCSVParser(tt.opts...)
+
+			var cookies []string
+			err := csvParser.StreamFile(filename, func(entry cookie.LogEntry) error {
+				cookies = append(cookies, entry.Cookie)
+				return nil
+			})
+
+			if tt.errorContains != "" {
+				assert.ErrorContains(t, err, tt.errorContains, "error mismatch")
+				var parseErr *parser.ParseError
+				if assert.ErrorAs(t, err, &parseErr, "expected a ParseError") {
+					assert.Equal(t, 1, parseErr.Column, "the cookie column is at fault")
+				}
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, cookies, "cookies mismatch")
+		})
+	}
+
+	t.Run("strict accepts valid multi-byte IDs", func(t *testing.T) {
+		filename := createTempCSVFile(t, "cookie,timestamp\nкука☃,2018-12-09T14:19:00+00:00\n")
+		csvParser := parser.NewCSVParser(parser.WithStrictUTF8())
+
+		var cookies []string
+		err := csvParser.StreamFile(filename, func(entry cookie.LogEntry) error {
+			cookies = append(cookies, entry.Cookie)
+			return nil
+		})
+
+		assert.NoError(t, err, "unexpected error")
+		assert.Equal(t, []string{"кука☃"}, cookies, "cookies mismatch")
+	})
+}
+
+func TestCSVParser_StreamFile_StrictWeights(t *testing.T) {
+	tests := []struct {
+		name       string
+		csvContent string
+		column     int
+	}{
+		{
+			name:       "non-numeric weight",
+			csvContent: "cookie,timestamp,weight\nA,2018-12-09T14:19:00+00:00,5\nB,2018-12-09T10:13:00+00:00,lots",
+			column:     3,
+		},
+		{
+			name:       "non-positive weight in a reordered header",
+			csvContent: "weight,cookie,timestamp\n0,A,2018-12-09T14:19:00+00:00",
+			column:     1,
+		},
+	}
+
+	csvParser := parser.NewCSVParser(parser.WithWeightColumn("weight"), parser.WithStrictUTF8())
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := createTempCSVFile(t, tt.csvContent)
+
+			err := csvParser.StreamFile(filename, func(_ cookie.LogEntry) error { return nil })
+
+			assert.ErrorContains(t, err, "expected a positive integer", "error mismatch")
+			var parseErr *parser.ParseError
+			if assert.ErrorAs(t, err, &parseErr, "expected a ParseError") {
+				assert.Equal(t, tt.column, parseErr.Column, "the weight column is at fault")
+			}
+		})
+	}
+
+	t.Run("missing weights still count once", func(t *testing.T) {
+		filename := createTempCSVFile(t, "cookie,timestamp,weight\nA,2018-12-09T14:19:00+00:00\nB,2018-12-09T10:13:00+00:00,3")
+
+		var weights []int
+		err := csvParser.StreamFile(filename, func(entry cookie.LogEntry) error {
+			weights = append(weights, entry.Weight)
+			return nil
+		})
+
+		assert.NoError(t, err, "unexpected error")
+		assert.Equal(t, []int{0, 3}, weights, "weights mismatch")
+	})
+}
+
+func TestCSVParser_StreamFile_CaseInsensitiveCookies(t *testing.T) {
+	csv := "cookie,timestamp\n" +
+		"AtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00\n" +
+		"aty0laufhglk3lc7,2018-12-09T15:19:00+00:00\n" +
+		"ÀtY0,2018-12-09T16:19:00+00:00\n"
+	filename := createTempCSVFile(t, csv)
+
+	tests := []struct {
+		name     string
+		opts     []parser.Option
+		expected []string
+	}{
+		{
+			name:     "case kept by default",
+			expected: []string{"AtY0laUfhglK3lC7", "aty0laufhglk3lc7", "ÀtY0"},
+		},
+		{
+			name:     "folded to lower case",
+			opts:     []parser.Option{parser.WithCaseInsensitiveCookies()},
+			expected: []string{"aty0laufhglk3lc7", "aty0laufhglk3lc7", "àty0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			csvParser := parser.NewCSVParser(tt.opts...)
+
+			var cookies []string
+			err := csvParser.StreamFile(filename, func(entry cookie.LogEntry) error {
+				cookies = append(cookies, entry.Cookie)
+				return nil
+			})
+
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, cookies, "cookies mismatch")
+		})
+	}
+}
+
+func TestCSVParser_StreamFile_QuotedFields(t *testing.T) {
+	quotedCSV := "cookie,timestamp\n" +
+		"\"cookie,with,commas\",2018-12-09T14:19:00+00:00\n" +
+		"\"cookie\"\"with\"\"quotes\",\"2018-12-09T10:13:00+00:00\"\n" +
+		"plain,2018-12-09T07:25:00+00:00"
+	filename := createTempCSVFile(t, quotedCSV)
+
+	var cookies []string
+	err := parser.NewCSVParser().StreamFile(filename, func(entry cookie.LogEntry) error {
+		cookies = append(cookies, entry.Cookie)
+		return nil
+	})
+
+	assert.NoError(t, err, "unexpected error")
+	assert.Equal(t, []string{"cookie,with,commas", `cookie"with"quotes`, "plain"}, cookies, "quoted cookies should be unescaped")
+}
+
+func TestCSVParser_StreamReader(t *testing.T) {
+	csvParser := parser.NewCSVParser()
+
+	t.Run("valid input", func(t *testing.T) {
+		input := strings.NewReader("cookie,timestamp\nAtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00\nSAZuXPGUrfbcn5UA,2018-12-09T10:13:00+00:00")
+
+		var cookies []string
+		err := csvParser.StreamReader(input, func(entry cookie.LogEntry) error {
+			cookies = append(cookies, entry.Cookie)
+			return nil
+		})
+
+		assert.NoError(t, err, "unexpected error")
+		assert.Equal(t, []string{"AtY0laUfhglK3lC7", "SAZuXPGUrfbcn5UA"}, cookies, "entries mismatch")
+	})
+
+	t.Run("empty input", func(t *testing.T) {
+		err := csvParser.StreamReader(strings.NewReader(""), func(cookie.LogEntry) error { return nil })
+
+		assert.ErrorContains(t, err, "no header row in <reader>", "empty input should be reported")
+	})
+}
+
+func TestCSVParser_StreamFile_Gzip(t *testing.T) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err := gz.Write([]byte("cookie,timestamp\nAtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00\nSAZuXPGUrfbcn5UA,2018-12-09T10:13:00+00:00"))
+	assert.NoError(t, err, "failed to compress")
+	assert.NoError(t, gz.Close(), "failed to compress")
+
+	filename := createTempCSVFile(t, compressed.String())
+
+	var cookies []string
+	err = parser.NewCSVParser().StreamFile(filename, func(entry cookie.LogEntry) error {
+		cookies = append(cookies, entry.Cookie)
+		return nil
+	})
+
+	assert.NoError(t, err, "gzip input should be decompressed")
+	assert.Equal(t, []string{"AtY0laUfhglK3lC7", "SAZuXPGUrfbcn5UA"}, cookies, "entries mismatch")
+}
+
+func TestCSVParser_StreamFile_ConcatenatedGzip(t *testing.T) {
+	var compressed bytes.Buffer
+	for _, member := range []string{
+		"cookie,timestamp\nAtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00\n",
+		"SAZuXPGUrfbcn5UA,2018-12-09T10:13:00+00:00\n5UAVanZf6UtGyKVS,2018-12-09T07:25:00+00:00\n",
+	} {
+		gz := gzip.NewWriter(&compressed)
+		_, err := gz.Write([]byte(member))
+		assert.NoError(t, err, "failed to compress")
+		assert.NoError(t, gz.Close(), "failed to compress")
+	}
+
+	filename := createTempCSVFile(t, compressed.String())
+
+	var cookies []string
+	err := parser.NewCSVParser().StreamFile(filename, func(entry cookie.LogEntry) error {
+		cookies = append(cookies, entry.Cookie)
+		return nil
+	})
+
+	assert.NoError(t, err, "concatenated gzip input should be decompressed")
+	assert.Equal(t, []string{"AtY0laUfhglK3lC7", "SAZuXPGUrfbcn5UA", "5UAVanZf6UtGyKVS"}, cookies, "every member should be read")
+}
+
+func TestCSVParser_StreamFile_GzipWithoutFinalNewline(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{
+			name:    "LF line endings",
+			content: "cookie,timestamp\nAtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00\nSAZuXPGUrfbcn5UA,2018-12-09T10:13:00+00:00",
+		},
+		{
+			name:    "CRLF line endings",
+			content: "cookie,timestamp\r\nAtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00\r\nSAZuXPGUrfbcn5UA,2018-12-09T10:13:00+00:00",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var compressed bytes.Buffer
+			gz := gzip.NewWriter(&compressed)
+			_, err := gz.Write([]byte(tt.content))
+			assert.NoError(t, err, "failed to compress")
+			assert.NoError(t, gz.Close(), "failed to compress")
+
+			filename := createTempCSVFile(t, compressed.String())
+
+			var entries []cookie.LogEntry
+			err = parser.NewCSVParser().StreamFile(filename, func(entry cookie.LogEntry) error {
+				entries = append(entries, entry)
+				return nil
+			})
+
+			assert.NoError(t, err, "unexpected error")
+			assert.Len(t, entries, 2, "the last line should not be dropped")
+			assert.Equal(t, cookie.LogEntry{Cookie: "SAZuXPGUrfbcn5UA", Timestamp: "2018-12-09T10:13:00+00:00"}, entries[len(entries)-1], "the last line should be parsed in full")
+		})
+	}
+}
+
+func TestCSVParser_StreamFile_CorruptGzip(t *testing.T) {
+	filename := createTempCSVFile(t, "\x1f\x8bnot really gzip")
+
+	err := parser.NewCSVParser().StreamFile(filename, func(cookie.LogEntry) error { return nil })
+
+	assert.ErrorContains(t, err, "invalid gzip data", "corrupt gzip should be reported")
+}
+
+func TestCSVParser_StreamFileContext_Cancelled(t *testing.T) {
+	var content strings.Builder
+	content.WriteString("cookie,timestamp\n")
+	for range 10000 {
+		content.WriteString("AtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00\n")
+	}
+	filename := createTempCSVFile(t, content.String())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	processed := 0
+	err := parser.NewCSVParser().StreamFileContext(ctx, filename, func(cookie.LogEntry) error {
+		processed++
+		if processed == 10 {
+			cancel()
+		}
+		return nil
+	})
+
+	assert.ErrorIs(t, err, context.Canceled, "cancellation should be reported")
+	assert.Less(t, processed, 2048, "the scan should stop soon after cancellation")
+}
+
+func TestCSVParser_StreamFileStats(t *testing.T) {
+	csv := "# nightly export\n" +
+		"cookie,timestamp\n" +
+		"AtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00\n" +
+		"\n" +
+		"garbage\n" +
+		"SAZuXPGUrfbcn5UA,2018-12-09T10:13:00+00:00\n" +
+		"# shard boundary\n" +
+		"5UAVanZf6UtGyKVS,2018-12-10T07:25:00+00:00\n" +
+		"4sMM2LxV07bPJzwf,2018-12-10T06:25:00+00:00\n"
+	filename := createTempCSVFile(t, csv)
+	csvParser := parser.NewCSVParser(parser.WithCommentPrefix('#'), parser.WithSkipInvalidLines(nil))
+	invalidLine := cookie.Warning{
+		Code:    cookie.WarningInvalidLine,
+		Message: "skipped invalid line: error parsing line 5: invalid CSV format: expected 2 columns, got 1",
+	}
+
+	t.Run("full scan", func(t *testing.T) {
+		stats, err := csvParser.StreamFileStats(filename, func(entry cookie.LogEntry) error {
+			if entry.Cookie == "SAZuXPGUrfbcn5UA" {
+				return cookie.ErrSkipEntry
+			}
+			return nil
+		})
+
+		assert.NoError(t, err, "unexpected error")
+		assert.Equal(t, cookie.ScanStats{Lines: 9, Entries: 3, Blank: 1, Comments: 2, Skipped: 1, Invalid: 1, Warnings: []cookie.Warning{invalidLine}}, stats, "stats mismatch")
+	})
+
+	t.Run("stopped early", func(t *testing.T) {
+		stats, err := csvParser.StreamFileStats(filename, func(entry cookie.LogEntry) error {
+			if strings.HasPrefix(entry.Timestamp, "2018-12-10") {
+				return cookie.ErrPastTargetDate
+			}
+			return nil
+		})
+
+		assert.NoError(t, err, "unexpected error")
+		assert.Equal(t, cookie.ScanStats{Lines: 8, Entries: 2, Blank: 1, Comments: 2, Invalid: 1, StoppedEarly: true, Warnings: []cookie.Warning{
+			invalidLine,
+			{Code: cookie.WarningStoppedEarly, Message: "stopped reading at line 8, the first entry past the target date"},
+		}}, stats, "stats mismatch")
+	})
+}
+
+func TestCSVParser_StreamFileStats_ByteOrderMark(t *testing.T) {
+	csv := "\uFEFF" + "cookie,timestamp\nAtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00\n" +
+		"\uFEFF" + "cookie,timestamp\nSAZuXPGUrfbcn5UA,2018-12-09T10:13:00+00:00\n"
+	filename := createTempCSVFile(t, csv)
+
+	stats, err := parser.NewCSVParser(parser.WithSkipRepeatedHeaders()).StreamFileStats(filename, func(cookie.LogEntry) error { return nil })
+
+	assert.NoError(t, err, "unexpected error")
+	assert.Equal(t, []cookie.Warning{{Code: cookie.WarningByteOrderMark, Message: "stripped a UTF-8 byte order mark"}}, stats.Warnings, "the byte order mark should be reported once")
+}
+
+func TestCSVParser_StreamFileStats_MixedLineEndings(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected bool
+	}{
+		{
+			name:    "LF only",
+			content: "cookie,timestamp\nAtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00\nSAZuXPGUrfbcn5UA,2018-12-09T10:13:00+00:00",
+		},
+		{
+			name:    "CRLF only",
+			content: "cookie,timestamp\r\nAtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00\r\nSAZuXPGUrfbcn5UA,2018-12-09T10:13:00+00:00\r\n",
+		},
+		{
+			name:     "LF and CRLF",
+			content:  "cookie,timestamp\nAtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00\r\nSAZuXPGUrfbcn5UA,2018-12-09T10:13:00+00:00\n",
+			expected: true,
+		},
+		{
+			name:     "CR and LF",
+			content:  "cookie,timestamp\rAtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00\nSAZuXPGUrfbcn5UA,2018-12-09T10:13:00+00:00",
+			expected: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := createTempCSVFile(t, tt.content)
+
+			stats, err := parser.NewCSVParser().StreamFileStats(filename, func(cookie.LogEntry) error { return nil })
+
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, 2, stats.Entries, "every line should be read")
+			assert.Equal(t, tt.expected, stats.MixedLineEndings, "mixed line endings mismatch")
+			assert.Equal(t, tt.expected, slices.ContainsFunc(stats.Warnings, func(w cookie.Warning) bool {
+				return w.Code == cookie.WarningMixedLineEndings
+			}), "mixed line endings should be reported as a warning")
+		})
+	}
+}
+
+func TestCSVParser_StreamFileColumn(t *testing.T) {
+	csv := "Region,cookie,timestamp,agent\n" +
+		"eu,AtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00,curl\n" +
+		"\"us, east\",SAZuXPGUrfbcn5UA,2018-12-09T10:13:00+00:00,wget\n" +
+		"apac,5UAVanZf6UtGyKVS,2018-12-09T07:25:00+00:00\n"
+	filename := createTempCSVFile(t, csv)
+
+	t.Run("values of the column", func(t *testing.T) {
+		var values []string
+		err := parser.NewCSVParser().StreamFileColumn(filename, "region", func(_ cookie.LogEntry, value string) error {
+			values = append(values, value)
+			return nil
+		})
+
+		assert.NoError(t, err, "unexpected error")
+		assert.Equal(t, []string{"eu", "us, east", "apac"}, values, "values mismatch")
+	})
+
+	t.Run("short rows", func(t *testing.T) {
+		var values []string
+		err := parser.NewCSVParser().StreamFileColumn(filename, "agent", func(_ cookie.LogEntry, value string) error {
+			values = append(values, value)
+			return nil
+		})
+
+		assert.NoError(t, err, "unexpected error")
+		assert.Equal(t, []string{"curl", "wget", ""}, values, "a row without the column should have no value")
+	})
+
+	t.Run("missing column", func(t *testing.T) {
+		err := parser.NewCSVParser().StreamFileColumn(filename, "country", func(cookie.LogEntry, string) error { return nil })
+
+		assert.ErrorContains(t, err, `no column "country" in the header`, "a missing column should be reported")
+	})
+
+	t.Run("without header", func(t *testing.T) {
+		err := parser.NewCSVParser(parser.WithoutHeader()).StreamFileColumn(filename, "region", func(cookie.LogEntry, string) error { return nil })
+
+		assert.ErrorContains(t, err, "without a header row", "headerless files have no named columns")
+	})
+}
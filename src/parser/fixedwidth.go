@@ -0,0 +1,87 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mfenderov/most-active-cookie/src/cookie"
+)
+
+// Column is a 1-based, inclusive byte range within a fixed-width record,
+// matching how record layouts are usually documented ("cols 1-20").
+type Column struct {
+	Start int
+	End   int
+}
+
+// FixedWidthParser reads headerless records where the cookie and timestamp
+// occupy fixed column ranges instead of being delimited. Records are read like
+// CSVParser lines: gzip input, \r and \r\n line endings, the line size and
+// count limits, cancellation and *ParseError all behave the same.
+type FixedWidthParser struct {
+	cookieColumn    Column
+	timestampColumn Column
+	records         *CSVParser
+}
+
+// NewFixedWidthParser returns a parser for records with the cookie and
+// timestamp in the given columns. opts are CSVParser options, such as
+// WithMaxLines, WithSkipInvalidLines or WithTimestampLayouts; those about
+// headers, delimiters and extra columns have no effect.
+func NewFixedWidthParser(cookieColumn, timestampColumn Column, opts ...Option) (*FixedWidthParser, error) {
+	for _, c := range []Column{cookieColumn, timestampColumn} {
+		if c.Start < 1 || c.End < c.Start {
+			return nil, fmt.Errorf("invalid column range %d-%d", c.Start, c.End)
+		}
+	}
+
+	p := &FixedWidthParser{
+		cookieColumn:    cookieColumn,
+		timestampColumn: timestampColumn,
+		records:         NewCSVParser(append(opts, WithoutHeader())...),
+	}
+	p.records.weightColumn = ""
+	p.records.splitRecord = p.splitRecord
+	return p, nil
+}
+
+// StreamFile streams the records of a local file, URL or Stdin, like
+// CSVParser.StreamFile.
+func (p *FixedWidthParser) StreamFile(filename string, processor cookie.EntryProcessor) error {
+	return p.records.StreamFile(filename, processor)
+}
+
+// StreamFileContext is StreamFile stopping with ctx's error once ctx is
+// cancelled.
+func (p *FixedWidthParser) StreamFileContext(ctx context.Context, filename string, processor cookie.EntryProcessor) error {
+	return p.records.StreamFileContext(ctx, filename, processor)
+}
+
+// StreamFileStats is StreamFile also reporting what was read.
+func (p *FixedWidthParser) StreamFileStats(filename string, processor cookie.EntryProcessor) (cookie.ScanStats, error) {
+	return p.records.StreamFileStats(filename, processor)
+}
+
+// splitRecord is the recordSplitter of fixed-width records.
+func (p *FixedWidthParser) splitRecord(line string) (cookieID, timestamp string, field int, err error) {
+	cookieID = strings.TrimSpace(slice(line, p.cookieColumn))
+	if cookieID == "" {
+		return "", "", 1, fmt.Errorf("empty cookie ID in columns %d-%d", p.cookieColumn.Start, p.cookieColumn.End)
+	}
+
+	timestamp = strings.TrimSpace(slice(line, p.timestampColumn))
+	if timestamp == "" {
+		return "", "", 2, fmt.Errorf("empty timestamp in columns %d-%d", p.timestampColumn.Start, p.timestampColumn.End)
+	}
+	return cookieID, timestamp, 0, nil
+}
+
+// slice returns the part of line covered by c. Records shorter than the
+// column are treated as blank-padded.
+func slice(line string, c Column) string {
+	if c.Start > len(line) {
+		return ""
+	}
+	return line[c.Start-1 : min(c.End, len(line))]
+}
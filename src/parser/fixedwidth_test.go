@@ -0,0 +1,138 @@
+package parser_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mfenderov/most-active-cookie/src/cookie"
+	"github.com/mfenderov/most-active-cookie/src/parser"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFixedWidthParser_StreamFile(t *testing.T) {
+	validRecords := "" +
+		"AtY0laUfhglK3lC7     2018-12-09T14:19:00+00:00\n" +
+		"SAZuXPGUrfbcn5UA     2018-12-09T10:13:00+00:00\n" +
+		"\n" +
+		"5UAVanZf6UtGyKVS     2018-12-09T07:25:00+00:00\n"
+
+	tests := []struct {
+		name            string
+		content         string
+		opts            []parser.Option
+		expectedCookies []string
+		errorContains   string
+	}{
+		{
+			name:            "valid records",
+			content:         validRecords,
+			expectedCookies: []string{"AtY0laUfhglK3lC7", "SAZuXPGUrfbcn5UA", "5UAVanZf6UtGyKVS"},
+		},
+		{
+			name:            "cr and crlf line endings",
+			content:         strings.Replace(strings.ReplaceAll(validRecords, "\n", "\r"), "\r", "\r\n", 1),
+			expectedCookies: []string{"AtY0laUfhglK3lC7", "SAZuXPGUrfbcn5UA", "5UAVanZf6UtGyKVS"},
+		},
+		{
+			name:            "record longer than 64KB",
+			content:         "AtY0laUfhglK3lC7     2018-12-09T14:19:00+00:00" + strings.Repeat(" ", 70000) + "x\n",
+			expectedCookies: []string{"AtY0laUfhglK3lC7"},
+		},
+		{
+			name:          "line limit",
+			content:       validRecords,
+			opts:          []parser.Option{parser.WithMaxLines(2)},
+			errorContains: "exceeded maximum of 2 lines",
+		},
+		{
+			name:            "invalid records skipped",
+			content:         "AtY0laUfhglK3lC7\n" + validRecords,
+			opts:            []parser.Option{parser.WithSkipInvalidLines(nil)},
+			expectedCookies: []string{"AtY0laUfhglK3lC7", "SAZuXPGUrfbcn5UA", "5UAVanZf6UtGyKVS"},
+		},
+		{
+			name:          "record too short for timestamp",
+			content:       "AtY0laUfhglK3lC7\n",
+			errorContains: "empty timestamp in columns 22-46",
+		},
+		{
+			name:          "invalid timestamp",
+			content:       "AtY0laUfhglK3lC7     not-a-timestamp\n",
+			errorContains: "invalid timestamp format",
+		},
+		{
+			name:          "no records",
+			content:       "\n\n",
+			errorContains: "no valid entries found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fixedWidthParser, err := parser.NewFixedWidthParser(parser.Column{Start: 1, End: 20}, parser.Column{Start: 22, End: 46}, tt.opts...)
+			assert.NoError(t, err, "unexpected error creating parser")
+			filename := createTempCSVFile(t, tt.content)
+
+			var cookies []string
+			err = fixedWidthParser.StreamFile(filename, func(entry cookie.LogEntry) error {
+				cookies = append(cookies, entry.Cookie)
+				return nil
+			})
+
+			if tt.errorContains != "" {
+				assert.ErrorContains(t, err, tt.errorContains, "error should contain expected substring")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expectedCookies, cookies, "parsed cookies mismatch")
+		})
+	}
+}
+
+func TestFixedWidthParser_StreamFile_Gzip(t *testing.T) {
+	var compressed bytes.Buffer
+	gz := gzip.NewWriter(&compressed)
+	_, err := gz.Write([]byte("AtY0laUfhglK3lC7     2018-12-09T14:19:00+00:00\nSAZuXPGUrfbcn5UA     2018-12-09T10:13:00+00:00"))
+	assert.NoError(t, err, "failed to compress")
+	assert.NoError(t, gz.Close(), "failed to compress")
+	filename := filepath.Join(t.TempDir(), "records.txt.gz")
+	assert.NoError(t, os.WriteFile(filename, compressed.Bytes(), 0o600), "failed to write file")
+	fixedWidthParser, err := parser.NewFixedWidthParser(parser.Column{Start: 1, End: 20}, parser.Column{Start: 22, End: 46})
+	assert.NoError(t, err, "unexpected error creating parser")
+
+	var cookies []string
+	err = fixedWidthParser.StreamFile(filename, func(entry cookie.LogEntry) error {
+		cookies = append(cookies, entry.Cookie)
+		return nil
+	})
+
+	assert.NoError(t, err, "unexpected error")
+	assert.Equal(t, []string{"AtY0laUfhglK3lC7", "SAZuXPGUrfbcn5UA"}, cookies, "gzipped records should be read")
+}
+
+func TestFixedWidthParser_StreamFile_ParseError(t *testing.T) {
+	filename := createTempCSVFile(t, "AtY0laUfhglK3lC7     2018-12-09T14:19:00+00:00\nSAZuXPGUrfbcn5UA\n")
+	fixedWidthParser, err := parser.NewFixedWidthParser(parser.Column{Start: 1, End: 20}, parser.Column{Start: 22, End: 46})
+	assert.NoError(t, err, "unexpected error creating parser")
+
+	err = fixedWidthParser.StreamFile(filename, func(cookie.LogEntry) error { return nil })
+
+	var parseErr *parser.ParseError
+	assert.ErrorAs(t, err, &parseErr, "a bad record should be a *ParseError")
+	assert.Equal(t, 2, parseErr.Line, "line mismatch")
+	assert.Equal(t, 2, parseErr.Column, "the timestamp should be the field at fault")
+	assert.ErrorContains(t, err, "empty timestamp in columns 22-46", "error should name the columns")
+}
+
+func TestNewFixedWidthParser_InvalidColumns(t *testing.T) {
+	_, err := parser.NewFixedWidthParser(parser.Column{Start: 0, End: 20}, parser.Column{Start: 22, End: 46})
+	assert.ErrorContains(t, err, "invalid column range", "zero start column should be rejected")
+
+	_, err = parser.NewFixedWidthParser(parser.Column{Start: 1, End: 20}, parser.Column{Start: 46, End: 22})
+	assert.ErrorContains(t, err, "invalid column range", "inverted column range should be rejected")
+}
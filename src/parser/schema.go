@@ -0,0 +1,143 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mfenderov/most-active-cookie/src/cookie"
+)
+
+// Column roles reported by DetectSchema.
+const (
+	RoleCookie    = "cookie"
+	RoleTimestamp = "timestamp"
+	RoleWeight    = "weight"
+	RoleUnknown   = "unknown"
+)
+
+// Timestamp layouts reported by DetectSchema.
+const (
+	LayoutRFC3339      = "RFC3339"
+	LayoutLocal        = "RFC3339 without offset"
+	LayoutUnixSec      = "Unix seconds"
+	LayoutUnixMilli    = "Unix milliseconds"
+	LayoutMixed        = "mixed"
+	LayoutUnrecognized = "unrecognized"
+)
+
+// SchemaColumn is a header column and the role it maps to.
+type SchemaColumn struct {
+	Name string
+	Role string
+}
+
+// Schema describes the layout detected from the header and first rows of a
+// cookie log.
+type Schema struct {
+	Delimiter byte
+	HasBOM    bool
+	// Accepted reports whether StreamFile, with the parser's options, accepts
+	// the header. A byte order mark or another delimiter than the configured
+	// one makes it reject a header whose columns are otherwise recognized.
+	Accepted        bool
+	Columns         []SchemaColumn
+	TimestampLayout string
+	SampledRows     int
+}
+
+// DetectSchema reads the header and up to sampleRows data rows of filename and
+// reports the delimiter, columns, timestamp layout and whether the file starts
+// with a byte order mark. Columns get their roles from the accepted headers
+// and weight column, matched as StreamFile matches them, and Accepted tells
+// whether StreamFile would read the file at all. Comment lines above the
+// header are skipped. It does not validate or count the rest of the file.
+func (p *CSVParser) DetectSchema(filename string, sampleRows int) (Schema, error) {
+	file, err := open(context.Background(), filename)
+	if err != nil {
+		return Schema{}, fmt.Errorf("failed to open file %s: %w", filename, err)
+	}
+	defer file.Close()
+
+	r, err := decompress(file)
+	if err != nil {
+		return Schema{}, fmt.Errorf("error reading file %s: %w", filename, err)
+	}
+	scanner := p.newScanner(r, nil)
+	var stats cookie.ScanStats
+	header, err := p.scanHeader(scanner, filename, &stats)
+	if err != nil {
+		return Schema{}, err
+	}
+
+	schema := Schema{HasBOM: strings.HasPrefix(header, byteOrderMark)}
+	_, schema.Accepted = p.matchHeader(header, filename, &stats)
+	header = strings.TrimPrefix(header, byteOrderMark)
+	schema.Delimiter = p.sniffDelimiter(header)
+
+	layout, recognized := p.headerLayout(header, schema.Delimiter)
+	timestampColumn := -1
+	if recognized {
+		timestampColumn = layout.timestamp
+	}
+	for i, name := range strings.Split(header, string(schema.Delimiter)) {
+		role := RoleUnknown
+		switch {
+		case !recognized:
+		case i == layout.cookie:
+			role = RoleCookie
+		case i == layout.timestamp:
+			role = RoleTimestamp
+		case i == layout.weight:
+			role = RoleWeight
+		}
+		schema.Columns = append(schema.Columns, SchemaColumn{Name: strings.TrimSpace(name), Role: role})
+	}
+
+	for schema.SampledRows < sampleRows && scanner.Scan() {
+		schema.SampledRows++
+		if timestampColumn < 0 {
+			continue
+		}
+		fields := strings.Split(scanner.Text(), string(schema.Delimiter))
+		layout := LayoutUnrecognized
+		if timestampColumn < len(fields) {
+			layout = timestampLayoutOf(strings.TrimSpace(fields[timestampColumn]))
+		}
+		switch schema.TimestampLayout {
+		case "":
+			schema.TimestampLayout = layout
+		case layout:
+		default:
+			schema.TimestampLayout = LayoutMixed
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return Schema{}, fmt.Errorf("error reading file %s: %w", filename, err)
+	}
+	if schema.TimestampLayout == "" {
+		schema.TimestampLayout = LayoutUnrecognized
+	}
+
+	return schema, nil
+}
+
+// timestampLayoutOf names the layout of a single timestamp value.
+func timestampLayoutOf(value string) string {
+	if _, err := time.Parse(time.RFC3339, value); err == nil {
+		return LayoutRFC3339
+	}
+	if _, err := time.Parse(localTimestampLayout, value); err == nil {
+		return LayoutLocal
+	}
+	if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+		// Seconds stay at 10 digits until 2286; milliseconds have 13.
+		if len(value) > 11 {
+			return LayoutUnixMilli
+		}
+		return LayoutUnixSec
+	}
+	return LayoutUnrecognized
+}
@@ -0,0 +1,112 @@
+package parser_test
+
+import (
+	"testing"
+
+	"github.com/mfenderov/most-active-cookie/src/parser"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCSVParser_DetectSchema(t *testing.T) {
+	tests := []struct {
+		name          string
+		csvContent    string
+		opts          []parser.Option
+		expected      parser.Schema
+		errorContains string
+	}{
+		{
+			name:       "comma separated RFC3339",
+			csvContent: "cookie,timestamp\nAtY0laUfhglK3lC7,2018-12-09T14:19:00+00:00\nSAZuXPGUrfbcn5UA,2018-12-09T10:13:00+00:00",
+			expected: parser.Schema{
+				Delimiter:       ',',
+				Accepted:        true,
+				Columns:         []parser.SchemaColumn{{Name: "cookie", Role: parser.RoleCookie}, {Name: "timestamp", Role: parser.RoleTimestamp}},
+				TimestampLayout: parser.LayoutRFC3339,
+				SampledRows:     2,
+			},
+		},
+		{
+			name:       "tab separated with BOM, not accepted by default",
+			csvContent: "\xEF\xBB\xBFcookie\ttimestamp\nAtY0laUfhglK3lC7\t1544365140000",
+			expected: parser.Schema{
+				Delimiter:       '\t',
+				HasBOM:          true,
+				Columns:         []parser.SchemaColumn{{Name: "cookie", Role: parser.RoleCookie}, {Name: "timestamp", Role: parser.RoleTimestamp}},
+				TimestampLayout: parser.LayoutUnixMilli,
+				SampledRows:     1,
+			},
+		},
+		{
+			name:       "BOM and tabs accepted with matching options",
+			csvContent: "\xEF\xBB\xBFcookie\ttimestamp\nAtY0laUfhglK3lC7\t1544365140000",
+			opts:       []parser.Option{parser.WithSkipRepeatedHeaders(), parser.WithAutoDetectDelimiter()},
+			expected: parser.Schema{
+				Delimiter:       '\t',
+				HasBOM:          true,
+				Accepted:        true,
+				Columns:         []parser.SchemaColumn{{Name: "cookie", Role: parser.RoleCookie}, {Name: "timestamp", Role: parser.RoleTimestamp}},
+				TimestampLayout: parser.LayoutUnixMilli,
+				SampledRows:     1,
+			},
+		},
+		{
+			name:       "configured header, weight column and comments",
+			csvContent: "# exported nightly\nid,when,hits\nA,2018-12-09T14:19:00+00:00,3",
+			opts: []parser.Option{
+				parser.WithAcceptedHeaders([]string{"id,when"}),
+				parser.WithWeightColumn("hits"),
+				parser.WithCommentPrefix('#'),
+			},
+			expected: parser.Schema{
+				Delimiter:       ',',
+				Accepted:        true,
+				Columns:         []parser.SchemaColumn{{Name: "id", Role: parser.RoleCookie}, {Name: "when", Role: parser.RoleTimestamp}, {Name: "hits", Role: parser.RoleWeight}},
+				TimestampLayout: parser.LayoutRFC3339,
+				SampledRows:     1,
+			},
+		},
+		{
+			name:       "mixed layouts and sample limit",
+			csvContent: "cookie,timestamp\nA,2018-12-09T14:19:00\nB,1544365140\nC,2018-12-09T14:19:00+00:00\nD,2018-12-09T14:19:00+00:00",
+			expected: parser.Schema{
+				Delimiter:       ',',
+				Accepted:        true,
+				Columns:         []parser.SchemaColumn{{Name: "cookie", Role: parser.RoleCookie}, {Name: "timestamp", Role: parser.RoleTimestamp}},
+				TimestampLayout: parser.LayoutMixed,
+				SampledRows:     3,
+			},
+		},
+		{
+			name:       "unknown columns",
+			csvContent: "id,when\nA,2018-12-09T14:19:00+00:00",
+			expected: parser.Schema{
+				Delimiter:       ',',
+				Columns:         []parser.SchemaColumn{{Name: "id", Role: parser.RoleUnknown}, {Name: "when", Role: parser.RoleUnknown}},
+				TimestampLayout: parser.LayoutUnrecognized,
+				SampledRows:     1,
+			},
+		},
+		{
+			name:          "empty file",
+			csvContent:    "",
+			errorContains: "is empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			filename := createTempCSVFile(t, tt.csvContent)
+
+			schema, err := parser.NewCSVParser(tt.opts...).DetectSchema(filename, 3)
+
+			if tt.errorContains != "" {
+				assert.ErrorContains(t, err, tt.errorContains, "error should contain expected substring")
+				return
+			}
+			assert.NoError(t, err, "unexpected error")
+			assert.Equal(t, tt.expected, schema, "schema mismatch")
+		})
+	}
+}